@@ -0,0 +1,46 @@
+package seekinghttp
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Kinds of the server anomalies reported to OnAnomaly.
+const (
+	// AnomalyFullResponse is a full (200) response to a request for a range
+	// of the object, downloading more than requested.
+	AnomalyFullResponse = "full-response"
+	// AnomalyCompressedRange is a partial response with a Content-Encoding,
+	// whose range addresses the encoded bytes.
+	AnomalyCompressedRange = "compressed-range"
+	// AnomalyRangeMismatch is a partial response for a range other than the
+	// requested one.
+	AnomalyRangeMismatch = "range-mismatch"
+	// AnomalyLongBody is a body longer than its headers promised.
+	AnomalyLongBody = "long-body"
+)
+
+// Anomaly is an unexpected response of the server, see OnAnomaly.
+type Anomaly struct {
+	// Kind is one of the Anomaly constants.
+	Kind string
+	// Off and Length are the requested range.
+	Off, Length int64
+	// Status is the response status.
+	Status int
+	// Detail describes the anomaly.
+	Detail string
+}
+
+// anomaly counts and reports an anomaly of the response to the request for
+// length bytes at off.
+func (s *SeekingHTTP) anomaly(resp *http.Response, off, length int64, kind, format string, args ...any) {
+	s.stats.anomalies.Add(1)
+	detail := fmt.Sprintf(format, args...)
+	if s.Logger != nil {
+		s.Logger.Debugf("server anomaly %s: %s", kind, detail)
+	}
+	if s.OnAnomaly != nil {
+		s.OnAnomaly(Anomaly{Kind: kind, Off: off, Length: length, Status: resp.StatusCode, Detail: detail})
+	}
+}
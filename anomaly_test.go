@@ -0,0 +1,60 @@
+package seekinghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnAnomaly(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var mode string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch mode {
+		case "full":
+			_, _ = w.Write([]byte(body))
+		case "compressed":
+			w.Header().Set("Content-Encoding", "br")
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+		case "mismatch":
+			w.Header().Set("Content-Range", "bytes 0-9/100")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(body[:10]))
+		}
+	}))
+	defer srv.Close()
+
+	var got []Anomaly
+	read := func(m string) {
+		mode = m
+		s := New(srv.URL)
+		s.MinFetch = 10
+		s.MaxRetries = 0
+		s.OnAnomaly = func(a Anomaly) { got = append(got, a) }
+		_, _ = s.ReadAt(make([]byte, 10), 20)
+		assert.EqualValues(t, 1, s.Stats().Anomalies, m)
+	}
+
+	read("full")
+	read("compressed")
+	read("mismatch")
+	if assert.Len(t, got, 3) {
+		assert.Equal(t, AnomalyFullResponse, got[0].Kind)
+		assert.Equal(t, http.StatusOK, got[0].Status)
+		assert.Equal(t, AnomalyCompressedRange, got[1].Kind)
+		assert.Equal(t, AnomalyRangeMismatch, got[2].Kind)
+		assert.EqualValues(t, 20, got[2].Off)
+		assert.EqualValues(t, 10, got[2].Length)
+	}
+
+	// a full response to a read of the whole object is expected
+	mode = "full"
+	s := New(srv.URL)
+	s.OnAnomaly = func(a Anomaly) { t.Errorf("unexpected anomaly %v", a) }
+	_, err := s.ReadAt(make([]byte, 100), 0)
+	assert.NoError(t, err)
+}
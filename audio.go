@@ -0,0 +1,149 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// audioHeadFetch and audioTailFetch are the lengths pinned at the head
+	// and the tail by PrepareAudio, holding the typical tags.
+	audioHeadFetch = 64 << 10
+	audioTailFetch = 8 << 10
+	// id3v1Size is the size of an ID3v1 tag and apeFooterSize the size of the
+	// footer and the header of an APEv2 tag.
+	id3v1Size     = 128
+	apeFooterSize = 32
+)
+
+// AudioTags is the location of the metadata of an audio file found by
+// PrepareAudio. The sizes are zero for missing tags.
+type AudioTags struct {
+	// ID3v2Size is the size of the ID3v2 tag at the start.
+	ID3v2Size int64
+	// FLACOff and FLACSize locate the fLaC marker and the metadata blocks
+	// following it.
+	FLACOff, FLACSize int64
+	// APEOff and APESize locate the APEv2 tag near the end.
+	APEOff, APESize int64
+	// ID3v1 is set if the last 128 bytes are an ID3v1 tag.
+	ID3v1 bool
+}
+
+// PrepareAudio prefetches and pins the metadata of an audio file in the
+// cache, for tag scanners reading many remote files: the ID3v2 tag and FLAC
+// metadata blocks at the head and the APEv2 and ID3v1 tags at the tail. The
+// first 64 KiB and the last 8 KiB are loaded by one request each, larger
+// tags need another. The size of the object is learned from the first
+// response.
+func (s *SeekingHTTP) PrepareAudio(ctx context.Context) (AudioTags, error) {
+	var t AudioTags
+	if err := s.Pin(ctx, 0, audioHeadFetch); err != nil && err != io.EOF {
+		return t, err
+	}
+	size, err := s.SizeContext(ctx)
+	if err != nil {
+		return t, err
+	}
+
+	// read reads n bytes at off, pinning them first.
+	read := func(off, n int64) ([]byte, error) {
+		if off < 0 || off+n > size {
+			return nil, nil
+		}
+		if err := s.Pin(ctx, off, n); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		if _, err := s.ReadAtContext(ctx, buf, off); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+
+	var off int64
+	hdr, err := read(0, 10)
+	if err != nil {
+		return t, err
+	}
+	if bytes.HasPrefix(hdr, []byte("ID3")) {
+		n := int64(hdr[6]&0x7f)<<21 | int64(hdr[7]&0x7f)<<14 | int64(hdr[8]&0x7f)<<7 | int64(hdr[9]&0x7f)
+		t.ID3v2Size = 10 + n
+		if hdr[5]&0x10 != 0 {
+			// footer present
+			t.ID3v2Size += 10
+		}
+		t.ID3v2Size = min(t.ID3v2Size, size)
+		// with the marker of FLAC following it
+		if err := s.Pin(ctx, 0, min(t.ID3v2Size+4, size)); err != nil {
+			return t, err
+		}
+		off = t.ID3v2Size
+	}
+
+	if hdr, err := read(off, 4); err != nil {
+		return t, err
+	} else if bytes.Equal(hdr, []byte("fLaC")) {
+		t.FLACOff = off
+		for block := off + 4; ; {
+			hdr, err := read(block, 4)
+			if err != nil {
+				return t, err
+			}
+			if hdr == nil {
+				break
+			}
+			// pin the block with the header of the next one, loading the
+			// following blocks too if it is not held yet
+			n := 4 + int64(binary.BigEndian.Uint32(hdr)&0xffffff)
+			if !s.pinHeld(block, min(n+4, size-block)) {
+				if err := s.Pin(ctx, block, min(n+audioHeadFetch, size-block)); err != nil {
+					return t, err
+				}
+			}
+			block += n
+			t.FLACSize = min(block, size) - off
+			if hdr[0]&0x80 != 0 {
+				// the last block
+				break
+			}
+		}
+	}
+
+	if size > off+t.FLACSize {
+		tail := max(size-audioTailFetch, off+t.FLACSize)
+		if err := s.Pin(ctx, tail, size-tail); err != nil {
+			return t, err
+		}
+	}
+	end := size
+	if tag, err := read(size-id3v1Size, 3); err != nil {
+		return t, err
+	} else if bytes.Equal(tag, []byte("TAG")) {
+		t.ID3v1 = true
+		end -= id3v1Size
+	}
+	footer, err := read(end-apeFooterSize, apeFooterSize)
+	if err != nil {
+		return t, err
+	}
+	if bytes.HasPrefix(footer, []byte("APETAGEX")) {
+		// the size includes the footer but not the header
+		n := int64(binary.LittleEndian.Uint32(footer[12:16]))
+		if binary.LittleEndian.Uint32(footer[20:24])&(1<<31) != 0 {
+			n += apeFooterSize
+		}
+		if n <= end {
+			t.APEOff, t.APESize = end-n, n
+			if err := s.Pin(ctx, t.APEOff, t.APESize); err != nil {
+				return t, err
+			}
+		}
+	}
+	if s.Logger != nil {
+		s.Logger.Debugf("audio tags: %+v", t)
+	}
+	return t, nil
+}
@@ -0,0 +1,99 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// id3v2Tag returns an ID3v2 tag with a body of n bytes.
+func id3v2Tag(n int) []byte {
+	b := []byte{'I', 'D', '3', 4, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+	return append(b, make([]byte, n)...)
+}
+
+// apeTag returns an APEv2 tag with header and items of n bytes.
+func apeTag(n int) []byte {
+	hdr := func(flags uint32) []byte {
+		b := append([]byte("APETAGEX"), 0xd0, 0x07, 0, 0)
+		b = binary.LittleEndian.AppendUint32(b, uint32(n+apeFooterSize))
+		b = binary.LittleEndian.AppendUint32(b, 1)
+		b = binary.LittleEndian.AppendUint32(b, flags)
+		return append(b, make([]byte, 8)...)
+	}
+	b := hdr(1<<31 | 1<<29)
+	b = append(b, bytes.Repeat([]byte("a"), n)...)
+	return append(b, hdr(1<<31)...)
+}
+
+// flacBlock returns a FLAC metadata block of type typ with n bytes.
+func flacBlock(typ byte, n int, last bool) []byte {
+	if last {
+		typ |= 0x80
+	}
+	b := []byte{typ, byte(n >> 16), byte(n >> 8), byte(n)}
+	return append(b, bytes.Repeat([]byte{'f'}, n)...)
+}
+
+func TestPrepareAudio(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xff}, 200<<10)
+	id3v1 := append([]byte("TAG"), make([]byte, id3v1Size-3)...)
+	files := map[string][]byte{
+		"/mp3":  bytes.Join([][]byte{id3v2Tag(1000), audio, apeTag(500), id3v1}, nil),
+		"/art":  bytes.Join([][]byte{id3v2Tag(100 << 10), audio, id3v1}, nil),
+		"/flac": bytes.Join([][]byte{[]byte("fLaC"), flacBlock(0, 34, false), flacBlock(6, 100<<10, false), flacBlock(4, 200, true), audio}, nil),
+		"/tiny": bytes.Join([][]byte{id3v2Tag(10), audio[:1000], id3v1}, nil),
+	}
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(files[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "/mp3")
+	tags, err := s.PrepareAudio(context.Background())
+	assert.NoError(t, err)
+	size := int64(len(files["/mp3"]))
+	assert.Equal(t, AudioTags{ID3v2Size: 1010, APEOff: size - id3v1Size - 564, APESize: 564, ID3v1: true}, tags)
+	assert.Equal(t, []string{"bytes=0-65535", fmt.Sprintf("bytes=%d-%d", size-audioTailFetch, size-1)}, ranges)
+
+	// the tags are read from the cache, even after other reads
+	ranges = nil
+	buf := make([]byte, 1010)
+	_, err = s.ReadAt(buf[:16], 100<<10)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(buf[:564], tags.APEOff)
+	assert.NoError(t, err)
+	assert.Equal(t, "APETAGEX", string(buf[:8]))
+	assert.Len(t, ranges, 1)
+
+	// a large tag needs another request
+	ranges = nil
+	tags, err = New(srv.URL + "/art").PrepareAudio(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, AudioTags{ID3v2Size: 10 + 100<<10, ID3v1: true}, tags)
+	assert.Len(t, ranges, 3)
+
+	ranges = nil
+	tags, err = New(srv.URL + "/flac").PrepareAudio(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, AudioTags{FLACSize: 4 + 38 + 4 + 100<<10 + 204}, tags)
+	assert.Equal(t, "bytes=42-167981", ranges[1])
+	assert.Len(t, ranges, 3)
+
+	ranges = nil
+	tags, err = New(srv.URL + "/tiny").PrepareAudio(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, AudioTags{ID3v2Size: 20, ID3v1: true}, tags)
+	assert.Len(t, ranges, 1)
+}
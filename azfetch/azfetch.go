@@ -0,0 +1,132 @@
+// Package azfetch implements a seekinghttp.RangeFetcher reading blobs from
+// Azure Blob Storage with ranged downloads, so azblob:// URLs can be read
+// with SAS tokens or Azure AD credentials.
+package azfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/paralin/seekinghttp"
+)
+
+// API is the part of the blob client used by the Fetcher, implemented by
+// *blob.Client.
+type API interface {
+	DownloadStream(ctx context.Context, o *blob.DownloadStreamOptions) (blob.DownloadStreamResponse, error)
+}
+
+// Fetcher fetches ranges of a blob.
+type Fetcher struct {
+	Client API
+}
+
+// _ is a type assertion
+var _ seekinghttp.RangeFetcher = (*Fetcher)(nil)
+
+// New initializes a Fetcher for the blob of client.
+func New(client API) *Fetcher {
+	return &Fetcher{Client: client}
+}
+
+// ParseURL converts a URL of the form
+// azblob://account/container/blob[?sas] to the https URL of the blob.
+func ParseURL(rawURL string) (blobURL string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	container, name, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Scheme != "azblob" || u.Host == "" || container == "" || name == "" {
+		return "", fmt.Errorf("invalid azblob url %q: expected azblob://account/container/blob", rawURL)
+	}
+	u.Scheme = "https"
+	u.Host += ".blob.core.windows.net"
+	return u.String(), nil
+}
+
+// Open returns a reader for the azblob://account/container/blob URL. A SAS
+// token in the query of the URL authorizes the requests, otherwise the
+// default Azure credential, such as Azure AD from the environment or a
+// managed identity, is used.
+func Open(ctx context.Context, rawURL string) (*seekinghttp.SeekingHTTP, error) {
+	blobURL, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var client *blob.Client
+	if strings.Contains(blobURL, "?") {
+		client, err = blob.NewClientWithNoCredential(blobURL, nil)
+	} else {
+		var cred azcore.TokenCredential
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+		if err == nil {
+			client, err = blob.NewClient(blobURL, cred, nil)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return seekinghttp.NewFromFetcher(rawURL, New(client)), nil
+}
+
+// FetchRange fetches a range of the blob with DownloadStream.
+func (f *Fetcher) FetchRange(ctx context.Context, off, length int64) ([]byte, int64, string, error) {
+	resp, err := f.Client.DownloadStream(ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{Offset: off, Count: max(length, 1)},
+	})
+	if err != nil {
+		return nil, -1, "", mapErr(err)
+	}
+	defer resp.Body.Close()
+
+	size := int64(-1)
+	if resp.ContentRange != nil {
+		// the form is "bytes start-end/size"
+		_, sizeStr, _ := strings.Cut(*resp.ContentRange, "/")
+		if n, err := strconv.ParseInt(sizeStr, 10, 64); err == nil {
+			size = n
+		}
+	}
+	var validator string
+	if resp.ETag != nil {
+		validator = string(*resp.ETag)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		// keep what arrived, the rest is retried
+		return data, size, validator, fmt.Errorf("read %d bytes: %v: %w", len(data), err, io.ErrUnexpectedEOF)
+	}
+	if resp.ContentLength != nil && int64(len(data)) < *resp.ContentLength {
+		return data, size, validator, fmt.Errorf("read %d bytes of %d: %w", len(data), *resp.ContentLength, io.ErrUnexpectedEOF)
+	}
+	return data, size, validator, nil
+}
+
+// mapErr maps the errors of the service to the errors of seekinghttp.
+func mapErr(err error) error {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return err
+	}
+	switch respErr.StatusCode {
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the range starts at or past the end of the blob
+		return io.EOF
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %w", err, seekinghttp.ErrNotFound)
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %w", err, seekinghttp.ErrForbidden)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package azfetch
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/paralin/seekinghttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetcher(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/container/dir/blob" || r.URL.Query().Get("sig") != "s" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// the SDK sends the range in x-ms-range
+		if rng := r.Header.Get("X-Ms-Range"); rng != "" {
+			r.Header.Set("Range", rng)
+		}
+		w.Header().Set("ETag", `"0x1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	newClient := func(path string) *blob.Client {
+		opts := &blob.ClientOptions{ClientOptions: policy.ClientOptions{Retry: policy.RetryOptions{MaxRetries: -1}}}
+		client, err := blob.NewClientWithNoCredential(srv.URL+path+"?sig=s", opts)
+		assert.NoError(t, err)
+		return client
+	}
+
+	s := seekinghttp.NewFromFetcher("azblob://account/container/dir/blob", New(newClient("/container/dir/blob")))
+	s.MinFetch = 0
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, "45678", string(buf[:n]))
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+	assert.Equal(t, `"0x1"`, s.ETag())
+	_, err = s.Seek(98, io.SeekStart)
+	assert.NoError(t, err)
+	all, err := io.ReadAll(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "89", string(all))
+
+	_, err = seekinghttp.NewFromFetcher("azblob://account/container/missing", New(newClient("/container/missing"))).ReadAt(buf, 0)
+	assert.ErrorIs(t, err, seekinghttp.ErrNotFound)
+	assert.Equal(t, io.EOF, mapErr(&azcore.ResponseError{StatusCode: http.StatusRequestedRangeNotSatisfiable}))
+
+	blobURL, err := ParseURL("azblob://account/container/dir/blob?sv=1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://account.blob.core.windows.net/container/dir/blob?sv=1", blobURL)
+	_, err = ParseURL("azblob://account/container")
+	assert.Error(t, err)
+}
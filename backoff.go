@@ -0,0 +1,142 @@
+package seekinghttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BackoffPolicy decides whether and when a failed range request is retried,
+// see SeekingHTTP.Backoff. The reader runs the retries, waiting for the delay
+// unless the context is done or the reader is closed.
+type BackoffPolicy interface {
+	// NextDelay returns the delay before retry number attempt, starting
+	// at 1, of a request that failed with err, or a negative delay to stop
+	// retrying. resp is the unsuccessful response if the server sent one; its
+	// body is already closed.
+	NextDelay(attempt int, err error, resp *http.Response) time.Duration
+}
+
+// BackoffFunc is a BackoffPolicy calling the function.
+type BackoffFunc func(attempt int, err error, resp *http.Response) time.Duration
+
+// NextDelay calls f.
+func (f BackoffFunc) NextDelay(attempt int, err error, resp *http.Response) time.Duration {
+	return f(attempt, err, resp)
+}
+
+// ExponentialBackoff is a BackoffPolicy retrying truncated and timed out
+// requests and responses with the status 429, 502, 503 or 504. The delay
+// starts at Initial and doubles up to Max, if set. A Retry-After header of
+// the response in seconds takes precedence.
+type ExponentialBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// _ is a type assertion
+var _ BackoffPolicy = ExponentialBackoff{}
+
+// NextDelay returns the delay before the retry.
+func (b ExponentialBackoff) NextDelay(attempt int, err error, resp *http.Response) time.Duration {
+	if resp == nil {
+		if !retryable(context.Background(), err) {
+			return -1
+		}
+	} else {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return -1
+		}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+
+	d := b.Initial
+	for i := 1; i < attempt && (b.Max <= 0 || d < b.Max); i++ {
+		d *= 2
+	}
+	if b.Max > 0 {
+		d = min(d, b.Max)
+	}
+	return d
+}
+
+// retryDelay returns the delay before retry number attempt of a request that
+// failed with err and whether to retry at all.
+func (s *SeekingHTTP) retryDelay(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	if s.Backoff == nil {
+		return 0, retryable(ctx, err)
+	}
+	if ctx.Err() != nil || s.context().Err() != nil {
+		return 0, false
+	}
+	var resp *http.Response
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		resp = statusErr.resp
+	}
+	d := s.Backoff.NextDelay(attempt, err, resp)
+	return d, d >= 0
+}
+
+// sleep waits for d, unless ctx is done or s is closed before.
+func (s *SeekingHTTP) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.context().Done():
+		return s.context().Err()
+	}
+}
+
+// RetryBudget limits the retries of many readers together, such as all
+// readers opened by a Factory, so an outage of the origin does not multiply
+// the load by the retries of every reader. It is a token bucket: each retry
+// takes a token and the tokens refill at a steady rate. A RetryBudget is
+// safe for concurrent use.
+type RetryBudget struct {
+	// mu guards the fields below
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRetryBudget creates a budget allowing burst retries at once and refilling
+// perSecond retries every second.
+func NewRetryBudget(perSecond float64, burst int) *RetryBudget {
+	return &RetryBudget{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take takes a token for a retry, returning false if none is left. A nil
+// budget allows every retry.
+func (b *RetryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
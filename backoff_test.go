@@ -0,0 +1,119 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if numReq%2 == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	// status errors are not retried by default
+	buf := make([]byte, 5)
+	s := New(srv.URL)
+	_, err := s.ReadAt(buf, 0)
+	assert.ErrorContains(t, err, "503")
+
+	// the policy retries them
+	numReq = 0
+	var attempts []int
+	var statuses []int
+	s = New(srv.URL)
+	s.Backoff = BackoffFunc(func(attempt int, err error, resp *http.Response) time.Duration {
+		attempts = append(attempts, attempt)
+		statuses = append(statuses, resp.StatusCode)
+		return ExponentialBackoff{Initial: time.Millisecond}.NextDelay(attempt, err, resp)
+	})
+	n, err := s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, body[:n], string(buf))
+	assert.Equal(t, 2, numReq)
+	assert.Equal(t, []int{1}, attempts)
+	assert.Equal(t, []int{http.StatusServiceUnavailable}, statuses)
+	assert.Equal(t, int64(1), s.Stats().Retries)
+
+	// or give up
+	numReq = 0
+	s = New(srv.URL + "/missing")
+	s.Backoff = ExponentialBackoff{Initial: time.Millisecond}
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, numReq)
+
+	// the wait ends with the context
+	s = New(srv.URL)
+	s.Backoff = BackoffFunc(func(int, error, *http.Response) time.Duration { return time.Hour })
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	numReq = 0
+	_, err = s.ReadAtContext(ctx, buf, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff{Initial: time.Second, Max: 3 * time.Second}
+	var delays []time.Duration
+	for attempt := 1; attempt <= 4; attempt++ {
+		delays = append(delays, b.NextDelay(attempt, io.ErrUnexpectedEOF, nil))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}, delays)
+
+	assert.Negative(t, b.NextDelay(1, io.EOF, nil))
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}
+	assert.Equal(t, 7*time.Second, b.NextDelay(1, nil, resp))
+	resp = &http.Response{StatusCode: http.StatusInternalServerError}
+	assert.Negative(t, b.NextDelay(1, nil, resp))
+}
+
+func TestRetryBudget(t *testing.T) {
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// the readers of a factory share the budget
+	f := NewFactory()
+	f.MaxRetries = 3
+	f.Backoff = ExponentialBackoff{}
+	f.RetryBudget = NewRetryBudget(0, 2)
+	buf := make([]byte, 5)
+	_, err := f.Open(srv.URL).ReadAt(buf, 0)
+	assert.ErrorContains(t, err, "503")
+	assert.Equal(t, 3, numReq)
+
+	numReq = 0
+	_, err = f.Open(srv.URL).ReadAt(buf, 0)
+	assert.ErrorContains(t, err, "503")
+	assert.Equal(t, 1, numReq)
+
+	// and it refills
+	b := NewRetryBudget(1000, 1)
+	assert.True(t, b.take())
+	assert.Eventually(t, b.take, time.Second, time.Millisecond)
+	var nilBudget *RetryBudget
+	assert.True(t, nilBudget.take())
+}
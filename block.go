@@ -0,0 +1,92 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// readBlocks reads want bytes at off block by block in block mode.
+func (s *SeekingHTTP) readBlocks(ctx context.Context, cache *Cache, buf []byte, off, want int64) (n int, err error) {
+	want = min(want, int64(len(buf)))
+	b := s.BlockSize
+	for int64(n) < want {
+		pos := off + int64(n)
+		from := pos - pos%b
+		part := min(want-int64(n), from+b-pos)
+		dst := buf[n : int64(n)+part]
+		if cache.readAt(s.cacheKey(), dst, pos, part) {
+			s.hit(pos, part, part)
+			n += int(part)
+			continue
+		}
+		s.miss(pos, part)
+
+		length := b
+		if s.KnownSize != nil {
+			length = min(length, *s.KnownSize-from)
+			if length <= pos-from {
+				return n, io.EOF
+			}
+		}
+		sp, err := s.loadShared(ctx, cache, from, length, length)
+		if sp == nil {
+			return n, err
+		}
+		got := copy(dst, sp.data.Bytes()[pos-sp.off:])
+		s.served(int64(got), false)
+		n += got
+		if err != nil {
+			return n, err
+		}
+		if int64(got) < part {
+			// the last block of the object
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// block returns the block of length bytes at off, cut at the end of the
+// object, out of the loaded sp after checking it with VerifyBlock.
+func (s *SeekingHTTP) block(sp *span, off, length int64) (*span, error) {
+	if s.KnownSize != nil {
+		length = min(length, *s.KnownSize-off)
+	}
+	start := off - sp.off
+	if start < 0 || int64(sp.data.Len())-start < length {
+		return nil, fmt.Errorf("short block at %d: %w", off, io.ErrUnexpectedEOF)
+	}
+	data := sp.data.Bytes()[start : start+length]
+	if s.VerifyBlock != nil {
+		if err := s.VerifyBlock(off/s.BlockSize, data); err != nil {
+			return nil, err
+		}
+	}
+	if start == 0 && int64(sp.data.Len()) == length {
+		return sp, nil
+	}
+	// the server sent more, such as the whole object
+	b := &span{off: off}
+	b.data.Write(data)
+	return b, nil
+}
+
+// VerifyBlockHashes returns a VerifyBlock function checking every block
+// against its sum computed by newHash, such as sha256.New. Blocks without a
+// sum and mismatching blocks fail with ErrBlockHash.
+func VerifyBlockHashes(newHash func() hash.Hash, sums [][]byte) func(index int64, data []byte) error {
+	return func(index int64, data []byte) error {
+		if index < 0 || index >= int64(len(sums)) {
+			return fmt.Errorf("no hash of block %d: %w", index, ErrBlockHash)
+		}
+		h := newHash()
+		_, _ = h.Write(data)
+		if !bytes.Equal(h.Sum(nil), sums[index]) {
+			return fmt.Errorf("block %d: %w", index, ErrBlockHash)
+		}
+		return nil
+	}
+}
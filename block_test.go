@@ -0,0 +1,60 @@
+package seekinghttp
+
+import (
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockSize(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var ranges []string
+	corrupt := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		data := body
+		if corrupt {
+			data = strings.Repeat("x", 100)
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(data))
+	}))
+	defer srv.Close()
+
+	var sums [][]byte
+	for i := 0; i < len(body); i += 32 {
+		sum := sha256.Sum256([]byte(body[i:min(i+32, len(body))]))
+		sums = append(sums, sum[:])
+	}
+
+	s := New(srv.URL)
+	s.BlockSize = 32
+	s.VerifyBlock = VerifyBlockHashes(sha256.New, sums)
+	s.Cache = NewCache(1024)
+	buf := make([]byte, 40)
+	_, err := s.ReadAt(buf, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, body[20:60], string(buf))
+	// only the missing block is loaded
+	_, err = s.ReadAt(buf, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, body[50:90], string(buf))
+	// the last block is cut at the end of the object
+	n, err := s.ReadAt(buf, 90)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, body[90:], string(buf[:n]))
+	assert.Equal(t, []string{"bytes=0-31", "bytes=32-63", "bytes=64-95", "bytes=96-99"}, ranges)
+	assert.EqualValues(t, 100, s.Stats().CacheSize)
+
+	// mismatching blocks fail and are not cached
+	corrupt = true
+	s.Cache.Clear()
+	_, err = s.ReadAt(buf[:4], 0)
+	assert.ErrorIs(t, err, ErrBlockHash)
+	assert.EqualValues(t, 0, s.Cache.Size())
+}
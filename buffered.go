@@ -0,0 +1,108 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+)
+
+// defaultBufferSize is the buffer size of NewBuffered if none is given.
+const defaultBufferSize = 4096
+
+// Buffered is a SeekingHTTP whose Read absorbs small sequential reads in a
+// local buffer, like bufio.Reader, so they do not each look up the cache and
+// log. Seek and Discard move within the buffer if possible and drop it
+// otherwise.
+//
+// ReadAt and the other methods of the embedded SeekingHTTP bypass the buffer;
+// reading or seeking with the embedded SeekingHTTP directly is not supported.
+type Buffered struct {
+	*SeekingHTTP
+
+	buf []byte
+	// buf[r:w] is unread, buf[:w] ends at the offset of the SeekingHTTP
+	r, w int
+}
+
+// _ is a type assertion
+var _ io.ReadSeeker = (*Buffered)(nil)
+
+// NewBuffered returns a reader for url with a read buffer of bufSize bytes,
+// or 4096 if bufSize is not positive.
+func NewBuffered(url string, bufSize int) *Buffered {
+	if bufSize <= 0 {
+		bufSize = defaultBufferSize
+	}
+	return &Buffered{SeekingHTTP: New(url), buf: make([]byte, bufSize)}
+}
+
+// Read reads up to len(buf) bytes, from the buffer if it holds any.
+func (b *Buffered) Read(buf []byte) (int, error) {
+	return b.ReadContext(context.Background(), buf)
+}
+
+// ReadContext is like Read but aborts the underlying requests when ctx is
+// done.
+func (b *Buffered) ReadContext(ctx context.Context, buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if b.r == b.w {
+		if len(buf) >= len(b.buf) {
+			// large reads skip the buffer
+			b.r, b.w = 0, 0
+			return b.SeekingHTTP.ReadContext(ctx, buf)
+		}
+		n, err := b.SeekingHTTP.ReadContext(ctx, b.buf)
+		b.r, b.w = 0, n
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(buf, b.buf[b.r:b.w])
+	b.r += n
+	return n, nil
+}
+
+// Seek sets the offset for the next Read.
+func (b *Buffered) Seek(offset int64, whence int) (int64, error) {
+	return b.SeekContext(context.Background(), offset, whence)
+}
+
+// SeekContext is like Seek but aborts the HEAD request issued to find the
+// size, if any, when ctx is done.
+func (b *Buffered) SeekContext(ctx context.Context, offset int64, whence int) (int64, error) {
+	start := b.SeekingHTTP.offset - int64(b.w)
+	if whence == io.SeekCurrent {
+		target, err := addOffset(start+int64(b.r), offset)
+		if err != nil {
+			return 0, err
+		}
+		offset, whence = target, io.SeekStart
+	}
+	if whence == io.SeekStart && offset >= start && offset <= b.SeekingHTTP.offset {
+		b.r = int(offset - start)
+		return offset, nil
+	}
+
+	target, err := b.SeekingHTTP.SeekContext(ctx, offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	b.r, b.w = 0, 0
+	return target, nil
+}
+
+// Discard skips the next n bytes like SeekingHTTP.Discard, consuming the
+// buffer first.
+func (b *Buffered) Discard(n int64) (int64, error) {
+	if buffered := int64(b.w - b.r); n > buffered {
+		b.r, b.w = 0, 0
+		skipped, err := b.SeekingHTTP.Discard(n - buffered)
+		return buffered + skipped, err
+	}
+	if n < 0 {
+		return b.SeekingHTTP.Discard(n)
+	}
+	b.r += int(n)
+	return n, nil
+}
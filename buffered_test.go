@@ -0,0 +1,75 @@
+package seekinghttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffered(t *testing.T) {
+	body := strings.Repeat("0123456789abcdef", 64)
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	b := NewBuffered(srv.URL, 64)
+	b.MinFetch = 64
+	var cacheReads int
+	b.Cache = NewCache(0)
+	b.Cache.SetEventHook(func(CacheEvent) { cacheReads++ })
+
+	// small reads are served by the buffer
+	buf := make([]byte, 4)
+	for i := range 16 {
+		n, err := b.Read(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, body[i*4:i*4+4], string(buf))
+	}
+	assert.Equal(t, 1, numReq)
+	assert.Equal(t, 2, cacheReads) // miss and fill
+
+	// seeks within the buffer keep it
+	off, err := b.Seek(-10, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(54), off)
+	_, err = b.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, body[54:58], string(buf))
+	n, err := b.Discard(2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	_, err = b.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, body[60:64], string(buf))
+	assert.Equal(t, 2, cacheReads)
+
+	// seeks elsewhere drop it
+	off, err = b.Seek(500, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500), off)
+	_, err = b.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, body[500:504], string(buf))
+	n, err = b.Discard(100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), n)
+	_, err = b.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, body[604:608], string(buf))
+
+	// reads through the end
+	_, err = b.Seek(-6, io.SeekEnd)
+	assert.NoError(t, err)
+	rest, err := io.ReadAll(b)
+	assert.NoError(t, err)
+	assert.Equal(t, body[len(body)-6:], string(rest))
+}
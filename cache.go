@@ -0,0 +1,435 @@
+package seekinghttp
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache holds ranges of objects loaded by range requests, so they can be read
+// again without another request. A Cache is safe for concurrent use and may
+// be shared by several readers, see SeekingHTTP.Clone.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	// segments are in least to most recently used order.
+	segments []*segment
+
+	// flights deduplicates concurrent loads of the same range.
+	flights flightGroup
+	// vary are the headers named by the Vary header of the objects by URL,
+	// so readers new to an object use the keys of the readers before them.
+	vary map[string][]string
+
+	// hook is called with the events, see SetEventHook.
+	hook func(CacheEvent)
+
+	// policy chooses the segments evicted, see SetEvictionPolicy.
+	policy EvictionPolicy
+	// encoder compresses the data of new segments if set, see
+	// SetCompression.
+	encoder *encoder
+
+	// fromCache and fromNet count the bytes of reads, see Stats.
+	fromCache, fromNet atomic.Int64
+
+	// objectQuota and tagQuotas limit the bytes of the segments of each key
+	// and of each tag, see SetObjectQuota and SetTagQuota.
+	objectQuota int64
+	tagQuotas   map[string]int64
+}
+
+// CacheEventType is the type of a CacheEvent.
+type CacheEventType int
+
+const (
+	// CacheHit is a read served from the cache.
+	CacheHit CacheEventType = iota
+	// CacheMiss is a read not held by the cache, which is loaded.
+	CacheMiss
+	// CacheFill is a loaded range stored in the cache.
+	CacheFill
+	// CacheEvict is a range evicted to make room for another.
+	CacheEvict
+)
+
+func (t CacheEventType) String() string {
+	switch t {
+	case CacheHit:
+		return "hit"
+	case CacheMiss:
+		return "miss"
+	case CacheFill:
+		return "fill"
+	case CacheEvict:
+		return "evict"
+	}
+	return "unknown"
+}
+
+// CacheEvent is an event of a cache affecting the range of Length bytes at
+// Off of the object Key, the URL of the reader followed by a hash of its
+// credentials and the headers named by Vary, if it sends any.
+type CacheEvent struct {
+	Type   CacheEventType
+	Key    string
+	Off    int64
+	Length int64
+}
+
+// segment is a contiguous range of an object.
+type segment struct {
+	key  string
+	off  int64
+	data []byte
+	// packed holds the data instead if the cache compresses, see
+	// SetCompression.
+	packed *packed
+	// entry is the segment as seen by the eviction policy.
+	entry *CacheEntry
+	// tag is the CacheTag of the reader that loaded it.
+	tag string
+	// pinned segments are not evicted, see pin.
+	pinned bool
+	validity
+}
+
+// validity is the version of the object the data of a segment was loaded
+// from and how long it may be served.
+type validity struct {
+	// etag is the ETag of the object, if known.
+	etag string
+	// expires is the time the data expires, never if zero.
+	expires time.Time
+}
+
+// stale reports whether the data expired at now.
+func (v validity) stale(now time.Time) bool {
+	return !v.expires.IsZero() && !now.Before(v.expires)
+}
+
+// end returns the offset after the last byte of the segment.
+func (g *segment) end() int64 {
+	return g.off + g.len()
+}
+
+// NewCache creates a cache holding up to maxBytes of data. The most recently
+// loaded range is always kept, even if it is larger than maxBytes, which
+// means a maxBytes of zero keeps only the last range. Pinned ranges, see
+// SeekingHTTP.Pin, are kept as well.
+func NewCache(maxBytes int64) *Cache {
+	return &Cache{maxBytes: maxBytes, policy: NewLRUPolicy()}
+}
+
+// SetEvictionPolicy sets the policy choosing the ranges evicted, the
+// least recently used by default, see NewLRUPolicy. The ranges held are
+// added to the policy in least to most recently used order.
+func (c *Cache) SetEvictionPolicy(policy EvictionPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = policy
+	for _, g := range c.segments {
+		policy.Add(g.entry)
+	}
+}
+
+// Size returns the number of bytes held by the cache.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// SetEventHook sets the function called with the events of the cache, such
+// as to build heatmaps of the access patterns, or clears it if nil. The hook
+// is called synchronously by the reading goroutine without holding the lock
+// of the cache; it must be safe for concurrent use if the cache is shared.
+func (c *Cache) SetEventHook(hook func(CacheEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hook = hook
+}
+
+// SetObjectQuota limits the bytes held of each object to maxBytes, or lifts
+// the limit if zero, so one huge object read through a cache shared by many
+// readers cannot evict the hot ranges of all others. Objects are told apart
+// by the Key of CacheEvent. The least recently used ranges of the object are
+// evicted first; its most recently loaded range and pinned ranges are kept.
+func (c *Cache) SetObjectQuota(maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objectQuota = maxBytes
+}
+
+// SetTagQuota limits the bytes held of the objects loaded by the readers
+// with the CacheTag tag to maxBytes, or lifts the limit if zero, such as for
+// each tenant of a service. It evicts like SetObjectQuota.
+func (c *Cache) SetTagQuota(tag string, maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if maxBytes == 0 {
+		delete(c.tagQuotas, tag)
+		return
+	}
+	if c.tagQuotas == nil {
+		c.tagQuotas = make(map[string]int64)
+	}
+	c.tagQuotas[tag] = maxBytes
+}
+
+// SetCompression compresses the ranges stored from now on with codec, at
+// level for CodecZstd, such as 1 for the fastest and 19 for the smallest or
+// zero for the default of 3, to hold more ranges in the same maxBytes at the
+// cost of decoding every read. The bytes are compressed in blocks of 64 KiB,
+// so a read decodes only the blocks it needs, and every block records its
+// codec, so ranges stored before keep their encoding. Quotas and maxBytes
+// count the compressed bytes.
+func (c *Cache) SetCompression(codec Codec, level int) error {
+	enc, err := newEncoder(codec, level)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.encoder = enc
+	return nil
+}
+
+// Clear drops all data held by the cache, including the pinned ranges. No
+// events are fired.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, g := range c.segments {
+		c.policy.Remove(g.entry, false)
+	}
+	c.segments = nil
+	c.size = 0
+}
+
+// readAt copies the range of length bytes at off of the object key into buf
+// if a single segment holds all of it. Returns false on a cache miss.
+func (c *Cache) readAt(key string, buf []byte, off, length int64) bool {
+	return c.read(key, buf, off, length, true)
+}
+
+// peek is readAt reporting only hits to the hook, for reads loading the
+// range on a miss elsewhere.
+func (c *Cache) peek(key string, buf []byte, off, length int64) bool {
+	return c.read(key, buf, off, length, false)
+}
+
+// read is readAt, reporting misses to the hook if reportMiss is set.
+func (c *Cache) read(key string, buf []byte, off, length int64, reportMiss bool) bool {
+	c.mu.Lock()
+	hit := c.lookup(key, buf, off, length)
+	hook := c.hook
+	c.mu.Unlock()
+
+	if hook != nil && (hit || reportMiss) {
+		ev := CacheEvent{Type: CacheMiss, Key: key, Off: off, Length: length}
+		if hit {
+			ev.Type = CacheHit
+		}
+		hook(ev)
+	}
+	return hit
+}
+
+// lookup is readAt with c.mu held. Expired segments are skipped.
+func (c *Cache) lookup(key string, buf []byte, off, length int64) bool {
+	now := time.Now()
+	for i := len(c.segments) - 1; i >= 0; i-- {
+		g := c.segments[i]
+		if g.key != key || off < g.off || off+length > g.end() || g.stale(now) {
+			continue
+		}
+		if !g.readAt(buf, off, length) {
+			continue
+		}
+		c.policy.Hit(g.entry)
+
+		// move to the most recently used position
+		copy(c.segments[i:], c.segments[i+1:])
+		c.segments[len(c.segments)-1] = g
+		return true
+	}
+	return false
+}
+
+// put stores data of the object key loaded at off, valid as v. Segments contained in the
+// new one are dropped, the new one is pinned in place of those pinned. Then the
+// least recently used unpinned segments are evicted until the cache fits in
+// maxBytes.
+func (c *Cache) put(key, tag string, off int64, data []byte, v validity) {
+	if len(data) == 0 {
+		return
+	}
+
+	g := &segment{key: key, tag: tag, off: off, data: data, validity: v}
+	g.entry = &CacheEntry{Key: key, Off: off, Length: int64(len(data)), g: g}
+	c.mu.Lock()
+	enc := c.encoder
+	c.mu.Unlock()
+	if enc != nil {
+		// compress without holding the lock
+		g.data, g.packed = nil, enc.pack(data)
+	}
+
+	c.mu.Lock()
+	hook := c.hook
+	events := []CacheEvent{{Type: CacheFill, Key: key, Off: off, Length: int64(len(data))}}
+	segments := c.segments[:0]
+	for _, old := range c.segments {
+		if old.key == key && old.off >= g.off && old.end() <= g.end() {
+			c.size -= old.stored()
+			c.policy.Remove(old.entry, false)
+			g.pinned = g.pinned || old.pinned
+			continue
+		}
+		segments = append(segments, old)
+	}
+	clear(c.segments[len(segments):])
+	c.segments = append(segments, g)
+	c.size += g.stored()
+	c.policy.Add(g.entry)
+
+	if c.objectQuota > 0 {
+		events = c.evict(events, g, c.objectQuota, func(old *segment) bool { return old.key == key })
+	}
+	if quota := c.tagQuotas[tag]; quota > 0 {
+		events = c.evict(events, g, quota, func(old *segment) bool { return old.tag == tag })
+	}
+	events = c.evict(events, g, c.maxBytes, func(*segment) bool { return true })
+	c.mu.Unlock()
+
+	if hook != nil {
+		for _, ev := range events {
+			hook(ev)
+		}
+	}
+}
+
+// evict evicts the unpinned segments matching match other than keep, in the
+// order of the eviction policy, until the matching segments hold at most
+// limit bytes, appending the events to events. c.mu must be held.
+func (c *Cache) evict(events []CacheEvent, keep *segment, limit int64, match func(g *segment) bool) []CacheEvent {
+	var used int64
+	for _, g := range c.segments {
+		if match(g) {
+			used += g.stored()
+		}
+	}
+	for used > limit {
+		var victim *segment
+		for e := range c.policy.Victims() {
+			if g := e.g; g != keep && !g.pinned && match(g) {
+				victim = g
+				break
+			}
+		}
+		if victim == nil {
+			break
+		}
+		used -= victim.stored()
+		c.remove(victim)
+		c.policy.Remove(victim.entry, true)
+		events = append(events, CacheEvent{Type: CacheEvict, Key: victim.key, Off: victim.off, Length: victim.len()})
+	}
+	return events
+}
+
+// remove removes the segment g. c.mu must be held.
+func (c *Cache) remove(g *segment) {
+	c.size -= g.stored()
+	c.segments = slices.DeleteFunc(c.segments, func(old *segment) bool { return old == g })
+}
+
+// pin pins the segments of the object key overlapping the range of length
+// bytes at off, so they are not evicted. Returns whether a single segment
+// holds all of the range.
+func (c *Cache) pin(key string, off, length int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var held bool
+	for _, g := range c.segments {
+		if g.key != key || g.end() <= off || g.off >= off+length {
+			continue
+		}
+		g.pinned = true
+		held = held || off >= g.off && off+length <= g.end()
+	}
+	return held
+}
+
+// unpin unpins all segments of the object key, making them evictable again.
+func (c *Cache) unpin(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, g := range c.segments {
+		if g.key == key {
+			g.pinned = false
+		}
+	}
+}
+
+// ranges returns the ranges of the object key held, sorted by offset.
+func (c *Cache) ranges(key string) []MetadataRange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ranges []MetadataRange
+	for _, g := range c.segments {
+		if g.key == key {
+			ranges = append(ranges, MetadataRange{Off: g.off, Length: g.len()})
+		}
+	}
+	slices.SortFunc(ranges, func(a, b MetadataRange) int { return cmp.Compare(a.Off, b.Off) })
+	return ranges
+}
+
+// expired returns a copy of an expired segment of the object key holding the
+// range of length bytes at off, to revalidate it.
+func (c *Cache) expired(key string, off, length int64) (segment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, g := range c.segments {
+		if g.key == key && off >= g.off && off+length <= g.end() && g.stale(now) {
+			return g.unpacked()
+		}
+	}
+	return segment{}, false
+}
+
+// tagged returns copies of the segments of the object key, to revalidate
+// them.
+func (c *Cache) tagged(key string) []segment {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var segments []segment
+	for _, g := range c.segments {
+		if g.key == key {
+			if u, ok := g.unpacked(); ok {
+				segments = append(segments, u)
+			}
+		}
+	}
+	return segments
+}
+
+// drop drops the segment of the object key at off holding length bytes.
+func (c *Cache) drop(key string, off, length int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, g := range c.segments {
+		if g.key == key && g.off == off && g.len() == length {
+			c.size -= g.stored()
+			c.segments = slices.Delete(c.segments, i, i+1)
+			c.policy.Remove(g.entry, false)
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package seekinghttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache(10)
+	buf := make([]byte, 4)
+
+	c.put("a", "", 0, []byte("0123456789"), validity{})
+	assert.Equal(t, int64(10), c.Size())
+	assert.True(t, c.readAt("a", buf, 2, 4))
+	assert.Equal(t, "2345", string(buf))
+	assert.False(t, c.readAt("a", buf, 8, 4))
+	assert.False(t, c.readAt("b", buf, 2, 4))
+
+	// evicts the least recently used segment
+	c.put("b", "", 0, []byte("abcd"), validity{})
+	assert.Equal(t, int64(4), c.Size())
+	assert.False(t, c.readAt("a", buf, 2, 4))
+	assert.True(t, c.readAt("b", buf, 0, 4))
+
+	c.put("b", "", 10, []byte("klm"), validity{})
+	c.put("b", "", 4, []byte("efg"), validity{})
+	assert.Equal(t, int64(10), c.Size())
+	assert.True(t, c.readAt("b", buf, 10, 3))
+	assert.Equal(t, "klm", string(buf[:3]))
+
+	// replaces the segments it contains, then evicts
+	c.put("b", "", 0, []byte("abcdefghij"), validity{})
+	assert.Equal(t, int64(10), c.Size())
+	assert.False(t, c.readAt("b", buf, 10, 3))
+	c.put("b", "", 0, []byte("abcdefghijklm"), validity{})
+	assert.Equal(t, int64(13), c.Size())
+	assert.True(t, c.readAt("b", buf, 9, 4))
+	assert.Equal(t, "jklm", string(buf))
+
+	c.Clear()
+	assert.Equal(t, int64(0), c.Size())
+	assert.False(t, c.readAt("b", buf, 0, 4))
+}
+
+func TestCacheEventHook(t *testing.T) {
+	c := NewCache(10)
+	var events []CacheEvent
+	c.SetEventHook(func(ev CacheEvent) {
+		events = append(events, ev)
+	})
+	buf := make([]byte, 4)
+
+	c.put("a", "", 0, []byte("0123456789"), validity{})
+	c.readAt("a", buf, 2, 4)
+	c.readAt("a", buf, 8, 4)
+	c.put("b", "", 20, []byte("abcd"), validity{})
+	assert.Equal(t, []CacheEvent{
+		{Type: CacheFill, Key: "a", Off: 0, Length: 10},
+		{Type: CacheHit, Key: "a", Off: 2, Length: 4},
+		{Type: CacheMiss, Key: "a", Off: 8, Length: 4},
+		{Type: CacheFill, Key: "b", Off: 20, Length: 4},
+		{Type: CacheEvict, Key: "a", Off: 0, Length: 10},
+	}, events)
+	assert.Equal(t, "evict", CacheEvict.String())
+
+	c.SetEventHook(nil)
+	c.readAt("b", buf, 20, 4)
+	assert.Len(t, events, 5)
+}
+
+func TestCacheQuota(t *testing.T) {
+	c := NewCache(100)
+	c.SetObjectQuota(10)
+	c.SetTagQuota("t", 12)
+	buf := make([]byte, 4)
+
+	// the huge object only evicts its own ranges
+	c.put("small", "", 0, []byte("keep"), validity{})
+	c.put("huge", "", 0, []byte("0123456"), validity{})
+	c.put("huge", "", 10, []byte("abcdefg"), validity{})
+	assert.Equal(t, int64(11), c.Size())
+	assert.True(t, c.readAt("small", buf, 0, 4))
+	assert.False(t, c.readAt("huge", buf, 0, 4))
+	assert.True(t, c.readAt("huge", buf, 10, 4))
+
+	// as does the tenant
+	c.put("x", "t", 0, []byte("xxxxxx"), validity{})
+	c.put("y", "t", 0, []byte("yyyyyy"), validity{})
+	c.put("z", "t", 0, []byte("zzzz"), validity{})
+	assert.False(t, c.readAt("x", buf, 0, 4))
+	assert.True(t, c.readAt("y", buf, 0, 4))
+	assert.True(t, c.readAt("small", buf, 0, 4))
+	assert.Equal(t, int64(21), c.Size())
+
+	// lifted quotas
+	c.SetObjectQuota(0)
+	c.SetTagQuota("t", 0)
+	c.put("x", "t", 0, []byte("xxxxxx"), validity{})
+	c.put("huge", "", 0, []byte("0123456"), validity{})
+	assert.Equal(t, int64(34), c.Size())
+}
@@ -0,0 +1,44 @@
+// Command seekinghttp-nbd exports a remote URL as a read-only NBD device.
+//
+//	seekinghttp-nbd -listen localhost:10809 https://example.com/disk.img
+//	nbd-client -N disk localhost 10809 /dev/nbd0 -readonly
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/paralin/seekinghttp/nbd"
+)
+
+var (
+	listen    = flag.String("listen", "localhost:10809", "address to listen on")
+	name      = flag.String("name", "disk", "name of the export")
+	blockSize = flag.Int64("block-size", 1<<20, "size of the blocks fetched from the URL")
+	cacheSize = flag.Int64("cache", 64<<20, "bytes of blocks cached in memory")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: seekinghttp-nbd [flags] URL")
+	}
+
+	s := seekinghttp.New(flag.Arg(0))
+	s.BlockSize = *blockSize
+	s.Cache = seekinghttp.NewCache(*cacheSize)
+	srv, err := nbd.New(context.Background(), s, *name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("exporting %s (%d bytes) as %q on %s", s.URL, srv.Size, *name, l.Addr())
+	log.Fatal(srv.Serve(l))
+}
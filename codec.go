@@ -0,0 +1,209 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression of the ranges held by a Cache, see
+// Cache.SetCompression.
+type Codec byte
+
+const (
+	// CodecNone stores the bytes as loaded.
+	CodecNone Codec = iota
+	// CodecSnappy compresses with Snappy, which is fast but compresses
+	// less.
+	CodecSnappy
+	// CodecZstd compresses with Zstandard at the level of SetCompression.
+	CodecZstd
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecSnappy:
+		return "snappy"
+	case CodecZstd:
+		return "zstd"
+	}
+	return "unknown"
+}
+
+// codecBlockSize is the number of bytes compressed into every block, so a
+// read decodes only the blocks it needs.
+const codecBlockSize = 64 << 10
+
+// The blocks of a segment are stored back to back, each with a header of the
+// codec byte and the uvarint lengths of the decoded and the stored bytes, so
+// blocks of different codecs coexist after the codec is changed.
+
+// errCorrupt is returned for blocks that cannot be decoded.
+var errCorrupt = errors.New("corrupt cache block")
+
+// packed is the data of a segment encoded in blocks.
+type packed struct {
+	buf []byte
+	// blocks are the offsets of the blocks in buf and length the number of
+	// bytes they decode to.
+	blocks []int
+	length int64
+}
+
+// encoder encodes the blocks of a codec.
+type encoder struct {
+	codec Codec
+	zstd  *zstd.Encoder
+}
+
+// newEncoder returns the encoder of codec at level, nil for CodecNone.
+func newEncoder(codec Codec, level int) (*encoder, error) {
+	switch codec {
+	case CodecNone:
+		return nil, nil
+	case CodecSnappy:
+		return &encoder{codec: codec}, nil
+	case CodecZstd:
+		if level == 0 {
+			level = 3
+		}
+		zw, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &encoder{codec: codec, zstd: zw}, nil
+	}
+	return nil, fmt.Errorf("codec %d: %w", codec, os.ErrInvalid)
+}
+
+// pack encodes data in blocks. Blocks that do not shrink are stored as
+// CodecNone.
+func (e *encoder) pack(data []byte) *packed {
+	p := &packed{length: int64(len(data))}
+	var enc []byte
+	for off := 0; off < len(data); off += codecBlockSize {
+		block := data[off:min(off+codecBlockSize, len(data))]
+		switch e.codec {
+		case CodecSnappy:
+			enc = snappy.Encode(enc[:cap(enc)], block)
+		case CodecZstd:
+			enc = e.zstd.EncodeAll(block, enc[:0])
+		}
+		codec := e.codec
+		if len(enc) >= len(block) {
+			codec, enc = CodecNone, block
+		}
+		p.blocks = append(p.blocks, len(p.buf))
+		p.buf = append(p.buf, byte(codec))
+		p.buf = binary.AppendUvarint(p.buf, uint64(len(block)))
+		p.buf = binary.AppendUvarint(p.buf, uint64(len(enc)))
+		p.buf = append(p.buf, enc...)
+		if codec == CodecNone {
+			enc = nil
+		}
+	}
+	// drop the spare capacity, which would be held with the cache
+	p.buf = bytes.Clone(p.buf)
+	return p
+}
+
+// zstdDecoder decodes the zstd blocks of all caches.
+var zstdDecoder = sync.OnceValue(func() *zstd.Decoder {
+	d, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	return d
+})
+
+// block decodes block i, reusing dst if large enough.
+func (p *packed) block(i int, dst []byte) ([]byte, error) {
+	b := p.buf[p.blocks[i]:]
+	codec := Codec(b[0])
+	n, l1 := binary.Uvarint(b[1:])
+	if l1 <= 0 {
+		return nil, errCorrupt
+	}
+	size, l2 := binary.Uvarint(b[1+l1:])
+	if l2 <= 0 || uint64(len(b)-1-l1-l2) < size {
+		return nil, errCorrupt
+	}
+	enc := b[1+l1+l2:][:size]
+
+	var out []byte
+	var err error
+	switch codec {
+	case CodecNone:
+		out = enc
+	case CodecSnappy:
+		out, err = snappy.Decode(dst[:cap(dst)], enc)
+	case CodecZstd:
+		out, err = zstdDecoder().DecodeAll(enc, dst[:0])
+	default:
+		err = errCorrupt
+	}
+	if err == nil && uint64(len(out)) != n {
+		err = errCorrupt
+	}
+	return out, err
+}
+
+// readAt decodes len(buf) bytes at off into buf.
+func (p *packed) readAt(buf []byte, off int64) error {
+	var scratch []byte
+	for len(buf) != 0 {
+		i := int(off / codecBlockSize)
+		block, err := p.block(i, scratch)
+		if err != nil {
+			return err
+		}
+		n := copy(buf, block[off-int64(i)*codecBlockSize:])
+		buf, off = buf[n:], off+int64(n)
+		if Codec(p.buf[p.blocks[i]]) != CodecNone {
+			scratch = block
+		}
+	}
+	return nil
+}
+
+// len returns the number of bytes of the segment.
+func (g *segment) len() int64 {
+	if g.packed != nil {
+		return g.packed.length
+	}
+	return int64(len(g.data))
+}
+
+// stored returns the number of bytes the segment holds in memory.
+func (g *segment) stored() int64 {
+	if g.packed != nil {
+		return int64(len(g.packed.buf))
+	}
+	return int64(len(g.data))
+}
+
+// readAt copies up to length bytes at off of the object into buf, reporting
+// whether it could. The segment must hold the range.
+func (g *segment) readAt(buf []byte, off, length int64) bool {
+	buf = buf[:min(int64(len(buf)), length)]
+	if g.packed == nil {
+		copy(buf, g.data[off-g.off:])
+		return true
+	}
+	return g.packed.readAt(buf, off-g.off) == nil
+}
+
+// unpacked returns a copy of the segment with its data decoded.
+func (g *segment) unpacked() (segment, bool) {
+	c := *g
+	if g.packed == nil {
+		return c, true
+	}
+	c.data, c.packed = make([]byte, g.packed.length), nil
+	return c, g.packed.readAt(c.data, 0) == nil
+}
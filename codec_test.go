@@ -0,0 +1,51 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheCompression(t *testing.T) {
+	text := bytes.Repeat([]byte("compressible text "), 20000)
+	random := make([]byte, 100<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+	c := NewCache(1 << 30)
+	buf := make([]byte, 1000)
+
+	assert.NoError(t, c.SetCompression(CodecZstd, 0))
+	c.put("zstd", "", 0, text, validity{})
+	assert.Less(t, c.Size(), int64(len(text))/10)
+	// across the boundary of two blocks
+	assert.True(t, c.readAt("zstd", buf, codecBlockSize-500, 1000))
+	assert.Equal(t, text[codecBlockSize-500:codecBlockSize+500], buf)
+
+	// the ranges stored before keep their codec
+	assert.NoError(t, c.SetCompression(CodecSnappy, 0))
+	c.put("snappy", "", 10, text, validity{})
+	assert.True(t, c.readAt("snappy", buf, 10+2*codecBlockSize, 1000))
+	assert.Equal(t, text[2*codecBlockSize:2*codecBlockSize+1000], buf)
+	assert.True(t, c.readAt("zstd", buf, 0, 1000))
+	assert.Equal(t, text[:1000], buf)
+
+	// incompressible blocks are stored as they are
+	size := c.Size()
+	c.put("random", "", 0, random, validity{})
+	assert.Less(t, c.Size()-size, int64(len(random))+20)
+	assert.True(t, c.readAt("random", buf, int64(len(random))-1000, 1000))
+	assert.Equal(t, random[len(random)-1000:], buf)
+	g, ok := c.segments[len(c.segments)-1].unpacked()
+	assert.True(t, ok)
+	assert.Equal(t, random, g.data)
+
+	assert.NoError(t, c.SetCompression(CodecNone, 0))
+	size = c.Size()
+	c.put("none", "", 0, text, validity{})
+	assert.Equal(t, size+int64(len(text)), c.Size())
+
+	assert.ErrorIs(t, c.SetCompression(Codec(9), 0), os.ErrInvalid)
+	assert.Equal(t, "zstd", CodecZstd.String())
+}
@@ -0,0 +1,350 @@
+// Package delta updates local copies of remote objects like zsync: the local
+// file is compared to an index of block checksums of the remote object, and
+// only the blocks missing locally are fetched with range requests.
+//
+// The index is built from the object by BuildIndex, typically next to it on
+// the server when it is published, and stored with WriteTo.
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/paralin/seekinghttp"
+)
+
+var (
+	// ErrMismatch is returned by Update when the result does not match the
+	// index, such as when the remote object changed since it was indexed.
+	ErrMismatch = errors.New("delta: result does not match the index")
+	// ErrInvalidIndex is returned by ReadIndex for malformed indexes.
+	ErrInvalidIndex = errors.New("delta: invalid index")
+)
+
+// DefaultBlockSize is the block size of BuildIndex if zero.
+const DefaultBlockSize = 4096
+
+// MaxBlockSize is the largest block size of an index, bounding the memory
+// of Update for indexes read from the network.
+const MaxBlockSize = 64 << 20
+
+// maxRun is the most bytes of consecutive missing blocks fetched by one
+// request.
+const maxRun = 4 << 20
+
+// Block is the checksums of a block of the object.
+type Block struct {
+	// Weak is the rolling checksum, Strong the SHA-256 of the block.
+	Weak   uint32
+	Strong [sha256.Size]byte
+}
+
+// Index holds the checksums of the blocks of an object, the last block may
+// be short.
+type Index struct {
+	Size      int64
+	BlockSize int
+	Blocks    []Block
+	// SHA256 is the hash of the whole object.
+	SHA256 [sha256.Size]byte
+}
+
+// BuildIndex reads the object from r and returns its index with blocks of
+// blockSize bytes, DefaultBlockSize if zero.
+func BuildIndex(r io.Reader, blockSize int) (*Index, error) {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	if blockSize > MaxBlockSize {
+		return nil, fmt.Errorf("block size %d larger than %d: %w", blockSize, MaxBlockSize, os.ErrInvalid)
+	}
+	idx := &Index{BlockSize: blockSize}
+	h := sha256.New()
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n != 0 {
+			idx.Blocks = append(idx.Blocks, Block{Weak: weakSum(buf[:n]), Strong: sha256.Sum256(buf[:n])})
+			idx.Size += int64(n)
+			h.Write(buf[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.Sum(idx.SHA256[:0])
+	return idx, nil
+}
+
+// indexMagic starts a stored index.
+const indexMagic = "SHDELTA1"
+
+// WriteTo writes the index to w in a binary format read by ReadIndex.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	b.WriteString(indexMagic)
+	_ = binary.Write(&b, binary.BigEndian, []uint64{uint64(idx.Size), uint64(idx.BlockSize), uint64(len(idx.Blocks))})
+	b.Write(idx.SHA256[:])
+	for _, blk := range idx.Blocks {
+		_ = binary.Write(&b, binary.BigEndian, blk.Weak)
+		b.Write(blk.Strong[:])
+	}
+	return b.WriteTo(w)
+}
+
+// ReadIndex reads an index written by WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != indexMagic {
+		return nil, ErrInvalidIndex
+	}
+	var hdr [3]uint64
+	if err := binary.Read(br, binary.BigEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("read header: %w", ErrInvalidIndex)
+	}
+	if hdr[1] > MaxBlockSize {
+		return nil, fmt.Errorf("block size %d larger than %d: %w", hdr[1], MaxBlockSize, os.ErrInvalid)
+	}
+	idx := &Index{Size: int64(hdr[0]), BlockSize: int(hdr[1])}
+	if idx.Size < 0 || idx.BlockSize <= 0 || hdr[2] != uint64((idx.Size+int64(idx.BlockSize)-1)/int64(idx.BlockSize)) {
+		return nil, fmt.Errorf("inconsistent header: %w", ErrInvalidIndex)
+	}
+	if _, err := io.ReadFull(br, idx.SHA256[:]); err != nil {
+		return nil, fmt.Errorf("read hash: %w", ErrInvalidIndex)
+	}
+	// grown as the blocks are read, so a forged count cannot allocate more
+	// than the input holds
+	for i := uint64(0); i < hdr[2]; i++ {
+		var blk Block
+		if err := binary.Read(br, binary.BigEndian, &blk.Weak); err != nil {
+			return nil, fmt.Errorf("read block %d: %w", i, ErrInvalidIndex)
+		}
+		if _, err := io.ReadFull(br, blk.Strong[:]); err != nil {
+			return nil, fmt.Errorf("read block %d: %w", i, ErrInvalidIndex)
+		}
+		idx.Blocks = append(idx.Blocks, blk)
+	}
+	return idx, nil
+}
+
+// blockLen returns the length of block i.
+func (idx *Index) blockLen(i int) int64 {
+	return min(int64(idx.BlockSize), idx.Size-int64(i)*int64(idx.BlockSize))
+}
+
+// weakSum returns the rolling checksum of rsync of block.
+func weakSum(block []byte) uint32 {
+	var a, b uint32
+	for i, c := range block {
+		a += uint32(c)
+		b += uint32(len(block)-i) * uint32(c)
+	}
+	return a&0xffff | b<<16
+}
+
+// Result counts the bytes of an update.
+type Result struct {
+	// Reused is the number of bytes copied from the local file and Fetched
+	// the number of bytes fetched from the remote object.
+	Reused, Fetched int64
+}
+
+// Update makes the file at path match the object of idx read by s. The
+// blocks found anywhere in the old file are copied from it, the others are
+// fetched by range requests of consecutive missing blocks, independent of
+// the fetch lengths of s. A missing file is created. The result is written
+// to a temporary file next to path, verified against the hash of the index
+// and renamed over path, which is left unchanged on errors.
+func Update(ctx context.Context, s *seekinghttp.SeekingHTTP, idx *Index, path string) (Result, error) {
+	var res Result
+	local, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return res, err
+	}
+	var found []int64
+	if local != nil {
+		defer local.Close()
+		if found, err = match(idx, local); err != nil {
+			return res, fmt.Errorf("scan %s: %w", path, err)
+		}
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return res, err
+	}
+	defer func() {
+		if out != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	// the clone loads exactly the ranges read
+	c := s.Clone(false)
+	c.MinFetch, c.SequentialFetch, c.AutoFetch, c.DoubleBuffer = 0, 0, false, false
+	h := sha256.New()
+	w := io.MultiWriter(out, h)
+	buf := make([]byte, max(idx.BlockSize, maxRun))
+	for i := 0; i < len(idx.Blocks); {
+		off := int64(i) * int64(idx.BlockSize)
+		if found != nil && found[i] >= 0 {
+			n := idx.blockLen(i)
+			if _, err := local.ReadAt(buf[:n], found[i]); err != nil {
+				return res, err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return res, err
+			}
+			res.Reused += n
+			i++
+			continue
+		}
+
+		// fetch the run of missing blocks
+		var n int64
+		for ; i < len(idx.Blocks) && (found == nil || found[i] < 0) && n+idx.blockLen(i) <= int64(len(buf)); i++ {
+			n += idx.blockLen(i)
+		}
+		if _, err := c.ReadAtContext(ctx, buf[:n], off); err != nil {
+			return res, fmt.Errorf("fetch range (%d-%d): %w", off, off+n, err)
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return res, err
+		}
+		res.Fetched += n
+	}
+	if !bytes.Equal(h.Sum(nil), idx.SHA256[:]) {
+		return res, ErrMismatch
+	}
+
+	if local != nil {
+		// keep the mode of the file, not the 0600 of the temporary file
+		info, err := local.Stat()
+		if err != nil {
+			return res, err
+		}
+		if err := out.Chmod(info.Mode().Perm()); err != nil {
+			return res, err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return res, err
+	}
+	name := out.Name()
+	out = nil
+	if err := os.Rename(name, path); err != nil {
+		os.Remove(name)
+		return res, err
+	}
+	return res, nil
+}
+
+// match scans r with the rolling checksum for the blocks of idx, returning
+// the offset in r of every block found or -1. The last block of the index is
+// only matched at the end of r if it is short.
+func match(idx *Index, r io.ReaderAt) ([]int64, error) {
+	bs := idx.BlockSize
+	weak := make(map[uint32][]int, len(idx.Blocks))
+	for i, blk := range idx.Blocks {
+		if idx.blockLen(i) == int64(bs) {
+			weak[blk.Weak] = append(weak[blk.Weak], i)
+		}
+	}
+	found := make([]int64, len(idx.Blocks))
+	for i := range found {
+		found[i] = -1
+	}
+
+	// check records the blocks matching the window at off.
+	check := func(window []byte, off int64, candidates []int) bool {
+		strong := sha256.Sum256(window)
+		var ok bool
+		for _, i := range candidates {
+			if idx.Blocks[i].Strong == strong {
+				ok = true
+				if found[i] < 0 {
+					found[i] = off
+				}
+			}
+		}
+		return ok
+	}
+
+	sr := io.NewSectionReader(r, 0, 1<<63-1)
+	br := bufio.NewReaderSize(sr, 1<<20)
+	// data holds the window at off followed by read ahead bytes.
+	data := make([]byte, 0, 2*bs)
+	var off int64
+	var a, b uint32
+	fill := func() (bool, error) {
+		for len(data) < bs {
+			c, err := br.ReadByte()
+			if err == io.EOF {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			data = append(data, c)
+		}
+		sum := weakSum(data[:bs])
+		a, b = sum&0xffff, sum>>16
+		return true, nil
+	}
+
+	ok, err := fill()
+	for ok && err == nil {
+		if cands := weak[a&0xffff|b<<16]; len(cands) != 0 && check(data[:bs], off, cands) {
+			// continue after the matched block
+			off += int64(bs)
+			data = data[:0]
+			ok, err = fill()
+			continue
+		}
+		c, rerr := br.ReadByte()
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+		out := uint32(data[0])
+		data = append(data[1:], c)
+		if cap(data) < bs+1 {
+			data = append(make([]byte, 0, 2*bs), data...)
+		}
+		off++
+		a = (a - out + uint32(c)) & 0xffff
+		b = (b - uint32(bs)*out + a) & 0xffff
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a short last block can only be at the end
+	if last := len(idx.Blocks) - 1; last >= 0 && found[last] < 0 && idx.blockLen(last) < int64(bs) {
+		n := idx.blockLen(last)
+		size := off + int64(len(data))
+		if size >= n {
+			tail := make([]byte, n)
+			if _, err := r.ReadAt(tail, size-n); err != nil {
+				return nil, err
+			}
+			check(tail, size-n, []int{last})
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,105 @@
+package delta
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdate(t *testing.T) {
+	remote := make([]byte, 1<<20+1000)
+	rand.New(rand.NewSource(1)).Read(remote)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(remote))
+	}))
+	defer srv.Close()
+
+	idx, err := BuildIndex(bytes.NewReader(remote), 0)
+	assert.NoError(t, err)
+	assert.Len(t, idx.Blocks, 257)
+	var stored bytes.Buffer
+	_, err = idx.WriteTo(&stored)
+	assert.NoError(t, err)
+	idx, err = ReadIndex(&stored)
+	assert.NoError(t, err)
+
+	// the old version has a prefix inserted, a changed block and lacks the
+	// end
+	old := append([]byte("new header"), remote[:1<<20]...)
+	copy(old[10+100*4096:], "changed")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(path, old, 0o644))
+	assert.NoError(t, os.Chmod(path, 0o755))
+
+	s := seekinghttp.New(srv.URL)
+	res, err := Update(context.Background(), s, idx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Reused: 255 * 4096, Fetched: 4096 + 1000}, res)
+	assert.Equal(t, []string{"bytes=409600-413695", "bytes=1048576-1049575"}, ranges)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(remote, data))
+	// the mode of the file is kept
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	// an up to date copy is not fetched at all
+	ranges = nil
+	res, err = Update(context.Background(), s, idx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Reused: int64(len(remote))}, res)
+	assert.Empty(t, ranges)
+
+	// a missing file is fetched in large ranges
+	ranges = nil
+	path = filepath.Join(dir, "missing")
+	res, err = Update(context.Background(), s, idx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Fetched: int64(len(remote))}, res)
+	assert.Len(t, ranges, 1)
+
+	// a changed object is detected and the file kept
+	remote[100*4096+5] ^= 0xff
+	path = filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(path, old, 0o644))
+	_, err = Update(context.Background(), s, idx, path)
+	assert.ErrorIs(t, err, ErrMismatch)
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(old, data))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = ReadIndex(bytes.NewReader([]byte("garbage")))
+	assert.ErrorIs(t, err, ErrInvalidIndex)
+
+	// a header claiming more blocks than follow
+	var forged bytes.Buffer
+	_, _ = (&Index{Size: 1 << 50, BlockSize: 1}).WriteTo(&forged)
+	b := forged.Bytes()
+	binary.BigEndian.PutUint64(b[len(indexMagic)+16:], 1<<50)
+	_, err = ReadIndex(bytes.NewReader(b))
+	assert.ErrorIs(t, err, ErrInvalidIndex)
+
+	// a block size too large to allocate
+	binary.BigEndian.PutUint64(b[len(indexMagic)+8:], 1<<62)
+	_, err = ReadIndex(bytes.NewReader(b))
+	assert.ErrorIs(t, err, os.ErrInvalid)
+	_, err = BuildIndex(bytes.NewReader(nil), MaxBlockSize+1)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+}
@@ -0,0 +1,72 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+)
+
+// roundTrip issues req with c, dumping it and its response to DebugDump.
+func (s *SeekingHTTP) roundTrip(c HttpClient, req *http.Request) (*http.Response, error) {
+	w := s.DebugDump
+	if w == nil {
+		return c.Do(req)
+	}
+
+	if dump, err := httputil.DumpRequestOut(req, s.DebugDumpBody > 0); err == nil {
+		_, _ = w.Write(truncateDump(dump, s.DebugDumpBody))
+	} else {
+		fmt.Fprintf(w, "dump request: %v\n\n", err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		fmt.Fprintf(w, "request failed: %v\n\n", err)
+		return nil, err
+	}
+	if dump, err := httputil.DumpResponse(resp, false); err == nil {
+		_, _ = w.Write(dump)
+	}
+	if s.DebugDumpBody > 0 {
+		resp.Body = &dumpBody{ReadCloser: resp.Body, w: w, remain: s.DebugDumpBody}
+	}
+	return resp, nil
+}
+
+// truncateDump truncates the body of a request dump to n bytes.
+func truncateDump(dump []byte, n int) []byte {
+	if i := bytes.Index(dump, []byte("\r\n\r\n")); i >= 0 && len(dump)-i-4 > n {
+		dump = append(dump[:i+4+n:i+4+n], "\n[truncated]"...)
+	}
+	return append(dump, "\n\n"...)
+}
+
+// dumpBody writes the first bytes read from a response body to w.
+type dumpBody struct {
+	io.ReadCloser
+	w      io.Writer
+	remain int
+	// read is set once anything was written
+	read bool
+}
+
+func (b *dumpBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if d := min(n, b.remain); d > 0 {
+		_, _ = b.w.Write(p[:d])
+		b.remain -= d
+		b.read = true
+	}
+	return n, err
+}
+
+func (b *dumpBody) Close() error {
+	if b.read {
+		if b.remain == 0 {
+			_, _ = io.WriteString(b.w, "\n[truncated]")
+		}
+		_, _ = io.WriteString(b.w, "\n\n")
+	}
+	return b.ReadCloser.Close()
+}
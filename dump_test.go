@@ -0,0 +1,47 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugDump(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	var dump bytes.Buffer
+	s := New(srv.URL)
+	s.MinFetch = 0
+	s.DebugDump = &dump
+	_, err := s.ReadAt(make([]byte, 20), 10)
+	assert.NoError(t, err)
+	out := dump.String()
+	assert.Contains(t, out, "GET / HTTP/1.1\r\n")
+	assert.Contains(t, out, "Range: bytes=10-29\r\n")
+	assert.Contains(t, out, "HTTP/1.1 206 Partial Content\r\n")
+	assert.Contains(t, out, "Content-Range: bytes 10-29/100\r\n")
+	assert.NotContains(t, out, "0123456789")
+
+	// the bodies are truncated
+	dump.Reset()
+	s.DebugDumpBody = 5
+	_, err = s.ReadAt(make([]byte, 20), 40)
+	assert.NoError(t, err)
+	assert.Contains(t, dump.String(), "\r\n\r\n01234\n[truncated]\n\n")
+
+	// the dump is gated at runtime
+	dump.Reset()
+	s.DebugDump = nil
+	_, err = s.ReadAt(make([]byte, 20), 70)
+	assert.NoError(t, err)
+	assert.Zero(t, dump.Len())
+}
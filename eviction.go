@@ -0,0 +1,248 @@
+package seekinghttp
+
+import (
+	"cmp"
+	"container/list"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// CacheEntry is a range held by a Cache, as seen by its EvictionPolicy. The
+// entry of a range is the same from when it is added until it is removed.
+type CacheEntry struct {
+	// Key is the object and Off and Length the range, see CacheEvent.
+	Key    string
+	Off    int64
+	Length int64
+
+	g *segment
+}
+
+// EvictionPolicy chooses the ranges evicted when a Cache exceeds its size or
+// a quota. Its methods are called with the lock of the cache held, so they
+// need no locking of their own but must not use the cache.
+type EvictionPolicy interface {
+	// Add is called when a range is stored.
+	Add(e *CacheEntry)
+	// Hit is called when a read is served from a range.
+	Hit(e *CacheEntry)
+	// Remove is called when a range is dropped, such as when a new range
+	// contains it, with evicted set if it was evicted.
+	Remove(e *CacheEntry, evicted bool)
+	// Victims yields the ranges in the order they should be evicted. The
+	// cache skips the ranges it must keep, such as pinned ones, and stops
+	// the iteration once it found a range to evict.
+	Victims() iter.Seq[*CacheEntry]
+}
+
+// NewLRUPolicy returns the default EvictionPolicy, which evicts the least
+// recently used range first. It suits sequential reads returning to the
+// ranges just read, such as media streaming with short seeks back.
+func NewLRUPolicy() EvictionPolicy {
+	return &lruPolicy{elems: make(map[*CacheEntry]*list.Element)}
+}
+
+// lruPolicy keeps the entries in least to most recently used order.
+type lruPolicy struct {
+	order list.List
+	elems map[*CacheEntry]*list.Element
+}
+
+func (p *lruPolicy) Add(e *CacheEntry) {
+	p.elems[e] = p.order.PushBack(e)
+}
+
+func (p *lruPolicy) Hit(e *CacheEntry) {
+	if el := p.elems[e]; el != nil {
+		p.order.MoveToBack(el)
+	}
+}
+
+func (p *lruPolicy) Remove(e *CacheEntry, evicted bool) {
+	if el := p.elems[e]; el != nil {
+		p.order.Remove(el)
+		delete(p.elems, e)
+	}
+}
+
+func (p *lruPolicy) Victims() iter.Seq[*CacheEntry] {
+	return func(yield func(*CacheEntry) bool) {
+		for el := p.order.Front(); el != nil; el = el.Next() {
+			if !yield(el.Value.(*CacheEntry)) {
+				return
+			}
+		}
+	}
+}
+
+// NewLFUPolicy returns an EvictionPolicy evicting the least frequently read
+// range first, the least recently used of equally frequent ones. It suits
+// random access returning to hot ranges, such as the central directory of
+// zip archives.
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{uses: make(map[*CacheEntry]lfuUse)}
+}
+
+// lfuPolicy counts the reads of every entry.
+type lfuPolicy struct {
+	uses map[*CacheEntry]lfuUse
+	tick int64
+}
+
+// lfuUse is the number of reads of an entry and the tick of the last.
+type lfuUse struct {
+	count, last int64
+}
+
+func (p *lfuPolicy) Add(e *CacheEntry) {
+	p.tick++
+	p.uses[e] = lfuUse{last: p.tick}
+}
+
+func (p *lfuPolicy) Hit(e *CacheEntry) {
+	if u, ok := p.uses[e]; ok {
+		p.tick++
+		p.uses[e] = lfuUse{count: u.count + 1, last: p.tick}
+	}
+}
+
+func (p *lfuPolicy) Remove(e *CacheEntry, evicted bool) {
+	delete(p.uses, e)
+}
+
+func (p *lfuPolicy) Victims() iter.Seq[*CacheEntry] {
+	return func(yield func(*CacheEntry) bool) {
+		entries := slices.Collect(maps.Keys(p.uses))
+		slices.SortFunc(entries, func(a, b *CacheEntry) int {
+			ua, ub := p.uses[a], p.uses[b]
+			return cmp.Or(cmp.Compare(ua.count, ub.count), cmp.Compare(ua.last, ub.last))
+		})
+		for _, e := range entries {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// NewARCPolicy returns an EvictionPolicy implementing the adaptive
+// replacement cache, which balances between recency and frequency by
+// remembering the ranges evicted recently and adapts to mixed workloads,
+// such as scans of media interleaved with reads of an index. Sizes are
+// counted in bytes, with the bytes held by the cache as its capacity.
+func NewARCPolicy() EvictionPolicy {
+	return &arcPolicy{elems: make(map[*CacheEntry]*list.Element), ghosts: make(map[arcGhost]*list.Element)}
+}
+
+// arcPolicy holds the entries read once in t1 and those read again in t2,
+// and the ranges evicted from them in the ghost lists b1 and b2, all in
+// least to most recently used order.
+type arcPolicy struct {
+	t1, t2, b1, b2 arcList
+	elems          map[*CacheEntry]*list.Element
+	ghosts         map[arcGhost]*list.Element
+	// target is the number of bytes t1 should hold.
+	target int64
+}
+
+// arcList is a list of the policy with the sum of the lengths.
+type arcList struct {
+	list.List
+	bytes int64
+}
+
+// arcGhost identifies an evicted range.
+type arcGhost struct {
+	key         string
+	off, length int64
+}
+
+// arcElem is the value of the elements of the lists.
+type arcElem struct {
+	e     *CacheEntry
+	ghost arcGhost
+	in    *arcList
+}
+
+func (p *arcPolicy) push(l *arcList, v *arcElem) *list.Element {
+	v.in = l
+	l.bytes += v.ghost.length
+	return l.PushBack(v)
+}
+
+func (p *arcPolicy) unlink(el *list.Element) *arcElem {
+	v := el.Value.(*arcElem)
+	v.in.Remove(el)
+	v.in.bytes -= v.ghost.length
+	return v
+}
+
+func (p *arcPolicy) Add(e *CacheEntry) {
+	ghost := arcGhost{key: e.Key, off: e.Off, length: e.Length}
+	v := &arcElem{e: e, ghost: ghost}
+	el := p.ghosts[ghost]
+	if el == nil {
+		p.elems[e] = p.push(&p.t1, v)
+		return
+	}
+
+	// a range evicted too early, adapt towards its list
+	delete(p.ghosts, ghost)
+	capacity := p.t1.bytes + p.t2.bytes
+	if old := p.unlink(el); old.in == &p.b1 {
+		p.target = min(p.target+max(p.b2.bytes/max(p.b1.bytes, 1), 1)*e.Length, capacity)
+	} else {
+		p.target = max(p.target-max(p.b1.bytes/max(p.b2.bytes, 1), 1)*e.Length, 0)
+	}
+	p.elems[e] = p.push(&p.t2, v)
+}
+
+func (p *arcPolicy) Hit(e *CacheEntry) {
+	if el := p.elems[e]; el != nil {
+		p.elems[e] = p.push(&p.t2, p.unlink(el))
+	}
+}
+
+func (p *arcPolicy) Remove(e *CacheEntry, evicted bool) {
+	el := p.elems[e]
+	if el == nil {
+		return
+	}
+	delete(p.elems, e)
+	v := p.unlink(el)
+	if !evicted {
+		return
+	}
+
+	// remember the range, up to as many bytes as are held
+	ghosts := &p.b1
+	if v.in == &p.t2 {
+		ghosts = &p.b2
+	}
+	v.e = nil
+	p.ghosts[v.ghost] = p.push(ghosts, v)
+	for p.b1.bytes+p.b2.bytes > p.t1.bytes+p.t2.bytes {
+		l := &p.b1
+		if p.b2.bytes > p.b1.bytes {
+			l = &p.b2
+		}
+		delete(p.ghosts, p.unlink(l.Front()).ghost)
+	}
+}
+
+func (p *arcPolicy) Victims() iter.Seq[*CacheEntry] {
+	return func(yield func(*CacheEntry) bool) {
+		first, second := &p.t2, &p.t1
+		if p.t1.bytes > p.target {
+			first, second = &p.t1, &p.t2
+		}
+		for _, l := range []*arcList{first, second} {
+			for el := l.Front(); el != nil; el = el.Next() {
+				if !yield(el.Value.(*arcElem).e) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package seekinghttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionPolicy(t *testing.T) {
+	buf := make([]byte, 4)
+	held := func(c *Cache, keys ...string) []string {
+		var got []string
+		for _, key := range keys {
+			if c.readAt(key, buf, 0, 4) {
+				got = append(got, key)
+			}
+		}
+		return got
+	}
+
+	// the least frequently read range is evicted
+	c := NewCache(12)
+	c.SetEvictionPolicy(NewLFUPolicy())
+	c.put("a", "", 0, []byte("aaaa"), validity{})
+	c.put("b", "", 0, []byte("bbbb"), validity{})
+	c.put("c", "", 0, []byte("cccc"), validity{})
+	c.readAt("a", buf, 0, 4)
+	c.readAt("a", buf, 0, 4)
+	c.readAt("b", buf, 0, 4)
+	c.put("d", "", 0, []byte("dddd"), validity{})
+	c.put("e", "", 0, []byte("eeee"), validity{})
+	assert.Equal(t, []string{"a", "b", "e"}, held(c, "a", "b", "c", "d", "e"))
+
+	// a scan evicts a range read again with LRU, but not with ARC
+	scan := func(c *Cache) []string {
+		c.put("hot", "", 0, []byte("hhhh"), validity{})
+		c.readAt("hot", buf, 0, 4)
+		for _, key := range []string{"s1", "s2", "s3", "s4"} {
+			c.put(key, "", 0, []byte("ssss"), validity{})
+		}
+		return held(c, "hot")
+	}
+	assert.Empty(t, scan(NewCache(12)))
+	c = NewCache(12)
+	p := NewARCPolicy().(*arcPolicy)
+	c.SetEvictionPolicy(p)
+	assert.Equal(t, []string{"hot"}, scan(c))
+
+	// a range loaded again after its eviction adapts ARC towards recency
+	c.put("s1", "", 0, []byte("ssss"), validity{})
+	assert.Equal(t, int64(4), p.target)
+	assert.Equal(t, int64(12), c.Size())
+}
@@ -0,0 +1,110 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Factory opens readers sharing the same configuration, so the defaults for
+// many URLs are set in one place. The fields have the same meaning as the
+// fields of SeekingHTTP and are copied to every reader opened; changing them
+// affects only the readers opened afterwards.
+type Factory struct {
+	Client      HttpClient
+	Logger      Logger
+	MinFetch    int64
+	Header      http.Header
+	UserAgent   string
+	MaxRetries  int
+	ReadTimeout time.Duration
+	StrictSeek  bool
+
+	RangeStrategy RangeStrategy
+	DoubleBuffer  bool
+
+	// CacheControl and Accept set the headers of every request, and
+	// ErrorBodyLimit and TrustBodyLength how responses are read.
+	CacheControl    string
+	Accept          string
+	ErrorBodyLimit  int
+	TrustBodyLength bool
+
+	// Prefetch runs the background loads of all readers opened by the
+	// factory, bounding the connections they open to the origins.
+	Prefetch *PrefetchPool
+
+	// Backoff decides the retries of the readers. RetryBudget bounds the
+	// retries of all readers opened by the factory together.
+	Backoff     BackoffPolicy
+	RetryBudget *RetryBudget
+
+	// Cache is shared by all readers opened by the factory. The ranges are
+	// keyed by URL. If nil, every reader uses a private cache.
+	Cache *Cache
+}
+
+// NewFactory returns a Factory with the defaults of New.
+func NewFactory() *Factory {
+	return NewFactoryWithClient(http.DefaultClient)
+}
+
+// NewFactoryWithClient returns a Factory with the defaults of NewWithClient.
+func NewFactoryWithClient(client HttpClient) *Factory {
+	s := NewWithClient("", client)
+	return &Factory{Client: s.Client, MinFetch: s.MinFetch, MaxRetries: s.MaxRetries}
+}
+
+// Open returns a reader for url configured by the factory. Like New, it
+// reads file:, data: and http+unix: URLs with their own clients, the Client
+// of the factory is used for the others.
+func (f *Factory) Open(url string) *SeekingHTTP {
+	s := New(url)
+	if s.Client == http.DefaultClient {
+		s.Client = f.Client
+	}
+	s.Logger = f.Logger
+	s.MinFetch = f.MinFetch
+	s.Header = f.Header.Clone()
+	s.UserAgent = f.UserAgent
+	s.MaxRetries = f.MaxRetries
+	s.ReadTimeout = f.ReadTimeout
+	s.StrictSeek = f.StrictSeek
+	s.Cache = f.Cache
+
+	s.RangeStrategy = f.RangeStrategy
+	s.DoubleBuffer = f.DoubleBuffer
+	s.CacheControl = f.CacheControl
+	s.Accept = f.Accept
+	s.ErrorBodyLimit = f.ErrorBodyLimit
+	s.TrustBodyLength = f.TrustBodyLength
+	s.Prefetch = f.Prefetch
+	s.Backoff = f.Backoff
+	s.RetryBudget = f.RetryBudget
+	return s
+}
+
+// NewRequest returns a request to url with the headers of the readers opened
+// by the factory, for requests other than reads such as listing directories.
+// Send it with the Client of the factory.
+func (f *Factory) NewRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	requestHeader{f.Header, f.UserAgent, f.Accept, f.CacheControl}.set(req.Header)
+	return req, nil
+}
+
+// SetBasicAuth sets the Authorization header of the readers opened to use
+// HTTP Basic Authentication with the provided username and password.
+func (f *Factory) SetBasicAuth(username, password string) {
+	f.Header = setBasicAuth(f.Header, username, password)
+}
+
+// SetRequesterPays sets the x-amz-request-payer header of the readers opened
+// for reading an S3 requester pays bucket.
+func (f *Factory) SetRequesterPays() {
+	f.Header = setRequesterPays(f.Header)
+}
@@ -0,0 +1,73 @@
+package seekinghttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactory(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var numReq atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq.Add(1)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	f := NewFactory()
+	f.MinFetch = 20
+	f.Header = http.Header{"Authorization": {"Bearer token"}}
+	f.Cache = NewCache(1024)
+
+	a := f.Open(srv.URL + "/a")
+	assert.Equal(t, int64(20), a.MinFetch)
+	assert.Equal(t, 2, a.MaxRetries)
+	buf := make([]byte, 5)
+	n, err := a.ReadAt(buf, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "01234", string(buf[:n]))
+
+	// the cache is shared but keyed by URL
+	b := f.Open(srv.URL + "/b")
+	_, err = b.ReadAt(buf, 10)
+	assert.NoError(t, err)
+	_, err = f.Open(srv.URL+"/a").ReadAt(buf, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), numReq.Load())
+
+	// the header is copied, changing it does not affect open readers
+	f.Header.Set("Authorization", "wrong")
+	_, err = f.Open(srv.URL+"/c").ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = b.ReadAt(buf, 50)
+	assert.NoError(t, err)
+
+	// other requests carry the headers of the readers
+	f.UserAgent = "agent"
+	f.Accept = "text/plain"
+	req, err := f.NewRequest(context.Background(), "PROPFIND", srv.URL+"/d", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "PROPFIND", req.Method)
+	assert.Equal(t, "wrong", req.Header.Get("Authorization"))
+	assert.Equal(t, "agent", req.Header.Get("User-Agent"))
+	assert.Equal(t, "text/plain", req.Header.Get("Accept"))
+	assert.Equal(t, "text/plain", f.Open(srv.URL).Accept)
+
+	// URLs with their own clients are read with them
+	d := f.Open("data:,hello")
+	n, err = d.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+	assert.Equal(t, int64(20), d.MinFetch)
+}
@@ -0,0 +1,38 @@
+//go:build js
+
+package seekinghttp
+
+import "net/http"
+
+// FetchClient is a HttpClient for browsers issuing the requests with the
+// fetch API, which the net/http client uses under GOOS=js. The server must
+// allow the Range header for cross-origin requests with CORS.
+type FetchClient struct {
+	// Client issues the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// Mode is the fetch mode, such as "cors" or "same-origin". The default
+	// of the browser is used if empty.
+	Mode string
+	// Credentials is the fetch credentials mode, such as "include" to send
+	// cookies with cross-origin requests. The default of the browser is used
+	// if empty.
+	Credentials string
+}
+
+// _ is a type assertion
+var _ HttpClient = (*FetchClient)(nil)
+
+// Do sets the fetch options of req and issues it.
+func (c *FetchClient) Do(req *http.Request) (*http.Response, error) {
+	if c.Mode != "" {
+		req.Header.Set("js.fetch:mode", c.Mode)
+	}
+	if c.Credentials != "" {
+		req.Header.Set("js.fetch:credentials", c.Credentials)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,42 @@
+//go:build js
+
+package seekinghttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripFunc is a http.RoundTripper calling itself.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestFetchClient(t *testing.T) {
+	c := &FetchClient{Mode: "cors", Credentials: "include", Client: &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "cors", req.Header.Get("js.fetch:mode"))
+			assert.Equal(t, "include", req.Header.Get("js.fetch:credentials"))
+			assert.Empty(t, req.Header.Get("User-Agent"))
+			assert.Equal(t, "bytes=2-5", req.Header.Get("Range"))
+			return &http.Response{
+				StatusCode:    http.StatusPartialContent,
+				Header:        http.Header{"Content-Range": {"bytes 2-5/10"}},
+				ContentLength: 4,
+				Body:          io.NopCloser(strings.NewReader("2345")),
+			}, nil
+		}),
+	}}
+	s := NewWithClient("https://example.com/obj", c)
+	s.MinFetch = 0
+	buf := make([]byte, 4)
+	n, err := s.ReadAt(buf, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "2345", string(buf[:n]))
+}
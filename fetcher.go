@@ -0,0 +1,55 @@
+package seekinghttp
+
+import (
+	"context"
+	"fmt"
+)
+
+// RangeFetcher fetches ranges of an object from a backend other than an HTTP
+// server, reusing the caching, retry and seek logic of SeekingHTTP.
+type RangeFetcher interface {
+	// FetchRange returns up to length bytes of the object starting at off.
+	// Less data than length may be returned, the rest is fetched by another
+	// call. size is the size of the object or -1 if unknown, and validator
+	// identifies the version of the object, such as an ETag, or is empty if
+	// unknown. A range starting at or past the end returns io.EOF. If an
+	// error is returned with data, the data is valid and kept; fetches
+	// failing with io.ErrUnexpectedEOF are retried.
+	FetchRange(ctx context.Context, off, length int64) (data []byte, size int64, validator string, err error)
+}
+
+// fetchRange fetches length bytes at off with the Fetcher and appends them to
+// sp.
+func (s *SeekingHTTP) fetchRange(ctx context.Context, sp *span, off, length int64) (partial bool, err error) {
+	release, err := s.limiter.acquire(ctx, s.background)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if s.Logger != nil {
+		s.Logger.Infof("Start fetch of range (%v-%v)", off, off+length)
+	}
+	data, size, validator, err := s.Fetcher.FetchRange(ctx, off, length)
+	if validator != "" {
+		if s.etag == "" {
+			s.etag = validator
+		} else if validator != s.etag {
+			return false, fmt.Errorf("validator %s changed to %s: %w", s.etag, validator, ErrChanged)
+		}
+	}
+	if size >= 0 && s.KnownSize == nil {
+		s.KnownSize = &size
+	}
+	if int64(len(data)) > length {
+		// keep the extra data like an over-long response
+		if s.Logger != nil {
+			s.Logger.Debugf("fetched %d bytes but requested %d, keeping all", len(data), length)
+		}
+	}
+	_, _ = sp.data.Write(data)
+	if s.Logger != nil {
+		s.Logger.Debugf("loaded %d bytes", len(data))
+	}
+	return true, err
+}
@@ -0,0 +1,64 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFetcher serves at most maxLen bytes of body per fetch and truncates
+// every fetch with an error while truncate is set.
+type testFetcher struct {
+	body      string
+	maxLen    int64
+	validator string
+	truncate  atomic.Int32
+	numFetch  atomic.Int32
+}
+
+func (f *testFetcher) FetchRange(ctx context.Context, off, length int64) ([]byte, int64, string, error) {
+	f.numFetch.Add(1)
+	if off >= int64(len(f.body)) {
+		return nil, int64(len(f.body)), f.validator, io.EOF
+	}
+	end := min(off+min(length, f.maxLen), int64(len(f.body)))
+	if f.truncate.Add(-1) >= 0 {
+		return []byte(f.body[off : off+(end-off)/2]), -1, f.validator, io.ErrUnexpectedEOF
+	}
+	return []byte(f.body[off:end]), int64(len(f.body)), f.validator, nil
+}
+
+func TestFetcher(t *testing.T) {
+	f := &testFetcher{body: strings.Repeat("0123456789", 10), maxLen: 8, validator: "v1"}
+	s := NewFromFetcher("test://obj", f)
+	s.MinFetch = 20
+
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+	assert.Equal(t, "v1", s.ETag())
+
+	// short fetches are continued and truncated fetches resumed
+	f.numFetch.Store(0)
+	f.truncate.Store(1)
+	buf := make([]byte, 15)
+	n, err := s.ReadAt(buf, 40)
+	assert.NoError(t, err)
+	assert.Equal(t, f.body[40:55], string(buf[:n]))
+	assert.Equal(t, int32(3), f.numFetch.Load())
+
+	_, err = s.Seek(95, io.SeekStart)
+	assert.NoError(t, err)
+	all, err := io.ReadAll(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "56789", string(all))
+
+	f.validator = "v2"
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrChanged)
+	assert.NoError(t, s.Close())
+}
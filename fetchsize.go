@@ -0,0 +1,119 @@
+package seekinghttp
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Bounds of the fetch length chosen by AutoFetch.
+const (
+	minAutoFetch = 64 * 1024
+	maxAutoFetch = 64 * 1024 * 1024
+)
+
+// fetchTuner tracks the access pattern to choose the fetch length, see
+// AutoFetch and SequentialFetch.
+type fetchTuner struct {
+	// size is the current fetch length, zero before the first miss.
+	size int64
+	// off and end bound the last loaded range and used counts the bytes of
+	// it served to reads.
+	off, end, used int64
+	// bdp is the measured bandwidth-delay product of the requests.
+	bdp int64
+}
+
+// SetMinFetch sets MinFetch after an in-flight read completed. It returns
+// os.ErrInvalid if n is negative or above MaxFetch. The cached ranges are
+// kept. A Cache should hold at least one range of MinFetch bytes, else the
+// loads are evicted by the next one.
+func (s *SeekingHTTP) SetMinFetch(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 0 || (s.MaxFetch > 0 && n > s.MaxFetch) {
+		return fmt.Errorf("min fetch %d outside of 0-%d: %w", n, s.MaxFetch, os.ErrInvalid)
+	}
+	s.MinFetch = n
+	return nil
+}
+
+// SetMaxFetch sets MaxFetch after an in-flight read completed, zero removes
+// the limit. It returns os.ErrInvalid if n is negative or below MinFetch.
+// The cached ranges are kept, including ranges longer than n.
+func (s *SeekingHTTP) SetMaxFetch(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 0 || (n > 0 && n < s.MinFetch) {
+		return fmt.Errorf("max fetch %d below min fetch %d: %w", n, s.MinFetch, os.ErrInvalid)
+	}
+	s.MaxFetch = n
+	return nil
+}
+
+// fetchLength returns the length to load on a miss of want bytes at off,
+// which may take several requests of MaxFetch bytes.
+func (s *SeekingHTTP) fetchLength(off, want int64) int64 {
+	var n int64
+	switch {
+	case s.AutoFetch:
+		n = s.tuner.next(off, s.MinFetch)
+	case s.SequentialFetch > 0 && s.tuner.sequential(off):
+		n = s.SequentialFetch
+	default:
+		n = s.MinFetch
+	}
+	if s.MaxFetch > 0 {
+		n = min(n, s.MaxFetch)
+	}
+	return max(want, n)
+}
+
+// sequential returns whether a miss at off continues the last load.
+func (t *fetchTuner) sequential(off int64) bool {
+	return t.end > 0 && off >= t.off && off <= t.end
+}
+
+// next returns the fetch length for a miss at off.
+func (t *fetchTuner) next(off, initial int64) int64 {
+	switch {
+	case t.size == 0:
+		t.size = initial
+		if t.size <= 0 {
+			t.size = 1024 * 1024
+		}
+	case t.sequential(off):
+		t.size = min(2*t.size, maxAutoFetch)
+	case t.used*2 < t.end-t.off:
+		// a seek away from a load that was mostly unread
+		t.size = min(t.size, max(t.size/2, minAutoFetch, t.bdp))
+	}
+	return t.size
+}
+
+// loaded records the range loaded for a read of want bytes.
+func (t *fetchTuner) loaded(sp *span, want int64) {
+	t.off, t.end, t.used = sp.off, sp.end(), want
+}
+
+// hit records a read of n bytes at off served from the cache.
+func (t *fetchTuner) hit(off, n int64) {
+	if off >= t.off && off < t.end {
+		t.used += n
+	}
+}
+
+// observe records a response of n bytes whose headers arrived after latency
+// and whose body took transfer.
+func (t *fetchTuner) observe(n int64, latency, transfer time.Duration) {
+	if n < minAutoFetch || transfer <= 0 {
+		// too short to measure the bandwidth
+		return
+	}
+	bdp := int64(float64(n) * float64(latency) / float64(transfer))
+	if t.bdp == 0 {
+		t.bdp = bdp
+	} else {
+		t.bdp = (3*t.bdp + bdp) / 4
+	}
+}
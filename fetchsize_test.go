@@ -0,0 +1,191 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rangeServer serves size bytes and records the lengths of the requested
+// ranges.
+func rangeServer(t *testing.T, size int) (*httptest.Server, *[]int64) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	var lengths []int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rng, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes="); ok {
+			from, to, _ := strings.Cut(rng, "-")
+			start, _ := strconv.ParseInt(from, 10, 64)
+			end, _ := strconv.ParseInt(to, 10, 64)
+			lengths = append(lengths, end-start+1)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &lengths
+}
+
+func TestAutoFetch(t *testing.T) {
+	srv, lengths := rangeServer(t, 4<<20)
+
+	// sequential reads grow the fetch length
+	s := New(srv.URL)
+	s.MinFetch = 1024
+	s.AutoFetch = true
+	buf := make([]byte, 512)
+	for off := int64(0); off < 31*1024; off += 512 {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []int64{1024, 2048, 4096, 8192, 16384}, *lengths)
+
+	// far seeks away from unread loads shrink it, but not below the minimum
+	// and the measured bandwidth-delay product
+	*lengths = nil
+	s = New(srv.URL)
+	s.MinFetch = 256 * 1024
+	s.AutoFetch = true
+	for i := int64(0); i < 5; i++ {
+		_, err := s.ReadAt(buf[:10], i*(512*1024))
+		assert.NoError(t, err)
+	}
+	if assert.Len(t, *lengths, 5) {
+		assert.EqualValues(t, 256<<10, (*lengths)[0])
+		for i := 1; i < 5; i++ {
+			assert.LessOrEqual(t, (*lengths)[i], (*lengths)[i-1])
+			assert.GreaterOrEqual(t, (*lengths)[i], int64(minAutoFetch))
+		}
+	}
+
+	// without AutoFetch the length stays MinFetch
+	*lengths = nil
+	s = New(srv.URL)
+	s.MinFetch = 1024
+	for off := int64(0); off < 4096; off += 512 {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []int64{1024, 1024, 1024, 1024}, *lengths)
+}
+
+func TestFetchTuner(t *testing.T) {
+	var tn fetchTuner
+	assert.EqualValues(t, 1<<20, tn.next(0, 0))
+	sp := &span{}
+	sp.data.Write(make([]byte, 1<<20))
+	tn.loaded(sp, 10)
+
+	// a far seek halves the length, down to the minimum
+	assert.EqualValues(t, 512<<10, tn.next(8<<20, 0))
+	tn.off, tn.end, tn.used = 8<<20, 8<<20+512<<10, 10
+	assert.EqualValues(t, 256<<10, tn.next(1<<30, 0))
+	tn.size = 100 << 10
+	assert.EqualValues(t, minAutoFetch, tn.next(1<<30, 0))
+
+	// or the bandwidth-delay product
+	tn.size = 1 << 20
+	tn.observe(1<<20, 10*time.Millisecond, 40*time.Millisecond)
+	assert.EqualValues(t, 256<<10, tn.bdp)
+	assert.EqualValues(t, 512<<10, tn.next(1<<30, 0))
+	assert.EqualValues(t, 256<<10, tn.next(1<<30, 0))
+	assert.EqualValues(t, 256<<10, tn.next(1<<30, 0))
+
+	// loads that were read do not shrink it
+	tn.used = tn.end - tn.off
+	assert.EqualValues(t, 256<<10, tn.next(1<<31, 0))
+
+	// sequential reads double it, up to the maximum
+	assert.EqualValues(t, 512<<10, tn.next(tn.end, 0))
+	tn.size = maxAutoFetch
+	assert.EqualValues(t, maxAutoFetch, tn.next(tn.end, 0))
+}
+
+func TestSequentialFetch(t *testing.T) {
+	srv, lengths := rangeServer(t, 1<<20)
+
+	s := New(srv.URL)
+	s.MinFetch = 1024
+	s.SequentialFetch = 64 * 1024
+	buf := make([]byte, 512)
+	// an index probe at the end, then a scan from the start
+	_, err := s.ReadAt(buf, 900*1024)
+	assert.NoError(t, err)
+	for off := int64(0); off < 100*1024; off += 512 {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+	// and another probe
+	_, err = s.ReadAt(buf, 500*1024)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1024, 1024, 64 * 1024, 64 * 1024, 1024}, *lengths)
+}
+
+func TestSetFetchLengths(t *testing.T) {
+	srv, lengths := rangeServer(t, 1<<20)
+
+	s := New(srv.URL)
+	assert.NoError(t, s.SetMinFetch(1024))
+	assert.NoError(t, s.SetMaxFetch(2048))
+	assert.ErrorIs(t, s.SetMinFetch(4096), os.ErrInvalid)
+	assert.ErrorIs(t, s.SetMinFetch(-1), os.ErrInvalid)
+	assert.ErrorIs(t, s.SetMaxFetch(512), os.ErrInvalid)
+	assert.ErrorIs(t, s.SetMaxFetch(-1), os.ErrInvalid)
+	assert.EqualValues(t, 1024, s.MinFetch)
+	assert.EqualValues(t, 2048, s.MaxFetch)
+
+	// long reads are split into requests of MaxFetch bytes
+	buf := make([]byte, 5000)
+	n, err := s.ReadAt(buf, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 5000, n)
+	assert.Equal(t, []int64{2048, 2048, 904}, *lengths)
+
+	// MaxFetch caps the other lengths
+	*lengths = nil
+	s.SequentialFetch = 64 * 1024
+	_, err = s.ReadAt(buf[:10], 5100)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2048}, *lengths)
+
+	// zero removes the limit
+	assert.NoError(t, s.SetMaxFetch(0))
+	assert.NoError(t, s.SetMinFetch(4096))
+}
+
+func TestAlignFetch(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data[:1000000]))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 1000
+	s.AlignFetch = 4096
+	s.Cache = NewCache(1 << 20)
+	buf := make([]byte, 100)
+	for _, off := range []int64{5000, 8150, 6000, 500000} {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+		assert.Equal(t, string(data[off:off+100]), string(buf))
+	}
+	// the last block is cut at the end of the object
+	n, err := s.ReadAt(buf, 999950)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, string(data[999950:1000000]), string(buf[:n]))
+	assert.Equal(t, []string{
+		"bytes=4096-8191",
+		"bytes=4096-12287",
+		"bytes=499712-503807",
+		"bytes=999424-999999",
+	}, ranges)
+}
@@ -0,0 +1,90 @@
+package seekinghttp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// File is an fs.File reading a remote file with a SeekingHTTP, for fs.FS
+// implementations backed by remote servers. It also implements io.Seeker
+// and io.ReaderAt.
+type File struct {
+	*SeekingHTTP
+	info fs.FileInfo
+}
+
+// NewFile returns a File reading with s and described by info. The size of
+// info is used as the KnownSize of s if it is not known yet.
+func NewFile(s *SeekingHTTP, info fs.FileInfo) *File {
+	if s.KnownSize == nil && info.Mode().IsRegular() {
+		size := info.Size()
+		s.KnownSize = &size
+	}
+	return &File{SeekingHTTP: s, info: info}
+}
+
+// _ is a type assertion
+var _ fs.File = (*File)(nil)
+
+// Stat returns the info of the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Dir is an open directory of an fs.FS whose entries were listed when it was
+// opened, the counterpart of File.
+type Dir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+// NewDir returns a Dir described by info listing entries.
+func NewDir(info fs.FileInfo, entries []fs.DirEntry) *Dir {
+	return &Dir{info: info, entries: entries}
+}
+
+// _ is a type assertion
+var _ fs.ReadDirFile = (*Dir)(nil)
+
+// Stat returns the info of the directory.
+func (d *Dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+// Close does nothing.
+func (d *Dir) Close() error { return nil }
+
+// Read fails, directories cannot be read.
+func (d *Dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the next n entries, or all remaining if n <= 0.
+func (d *Dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// FileURL returns the URL of the named file of an fs.FS served below root,
+// with a trailing slash for directories.
+func FileURL(root *url.URL, name string, dir bool) *url.URL {
+	p := strings.TrimSuffix(root.Path, "/") + "/"
+	if name != "." {
+		p += name
+		if dir {
+			p += "/"
+		}
+	}
+	return root.ResolveReference(&url.URL{Path: p})
+}
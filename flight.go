@@ -0,0 +1,51 @@
+package seekinghttp
+
+import "sync"
+
+// flightKey identifies a load of a range of an object.
+type flightKey struct {
+	key               string
+	off, length, want int64
+}
+
+// flightCall is a load in flight. The fields are set before done is closed.
+type flightCall struct {
+	done chan struct{}
+	sp   *span
+	err  error
+	// size is the size of the object learned by the load or -1.
+	size int64
+	// abandoned is set if the load failed because it was canceled.
+	abandoned bool
+}
+
+// flightGroup deduplicates identical loads in flight at the same time.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[flightKey]*flightCall
+}
+
+// join returns the call in flight for key. If there is none, a new call is
+// started and leader is set: the caller must load the range and call done,
+// deferred so the joiners are woken up even if the load panics.
+func (g *flightGroup) join(key flightKey) (call *flightCall, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[flightKey]*flightCall)
+	}
+	call = &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// done completes the call for key and wakes up the waiting joiners.
+func (g *flightGroup) done(key flightKey, call *flightCall) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,132 @@
+// Package gcsfetch implements a seekinghttp.RangeFetcher reading objects from
+// Google Cloud Storage with range readers, so gs://bucket/object URLs can be
+// read without signed URLs.
+package gcsfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/paralin/seekinghttp"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Fetcher fetches ranges of a GCS object. The generation of the object is
+// pinned by the first fetch, so all ranges are read from the same version
+// even if the object is overwritten; reads of a replaced object then return
+// seekinghttp.ErrChanged.
+type Fetcher struct {
+	Object *storage.ObjectHandle
+
+	mu         sync.Mutex
+	generation int64
+}
+
+// _ is a type assertion
+var _ seekinghttp.RangeFetcher = (*Fetcher)(nil)
+
+// New initializes a Fetcher for the object. If generation is not zero, that
+// generation is read instead of the one current at the first fetch.
+func New(obj *storage.ObjectHandle, generation int64) *Fetcher {
+	return &Fetcher{Object: obj, generation: generation}
+}
+
+// ParseURL parses a URL of the form gs://bucket/object.
+func ParseURL(rawURL string) (bucket, object string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	object = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "gs" || u.Host == "" || object == "" {
+		return "", "", fmt.Errorf("invalid gcs url %q: expected gs://bucket/object", rawURL)
+	}
+	return u.Host, object, nil
+}
+
+// Open returns a reader for the gs://bucket/object URL using a new client
+// with the given options, using the default credentials if none are given.
+func Open(ctx context.Context, rawURL string, opts ...option.ClientOption) (*seekinghttp.SeekingHTTP, error) {
+	bucket, object, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return seekinghttp.NewFromFetcher(rawURL, New(client.Bucket(bucket).Object(object), 0)), nil
+}
+
+// Generation returns the pinned generation, or zero before the first fetch.
+func (f *Fetcher) Generation() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.generation
+}
+
+// FetchRange fetches a range of the pinned generation of the object.
+func (f *Fetcher) FetchRange(ctx context.Context, off, length int64) ([]byte, int64, string, error) {
+	gen := f.Generation()
+	obj := f.Object
+	if gen != 0 {
+		obj = obj.Generation(gen)
+	}
+
+	r, err := obj.NewRangeReader(ctx, off, max(length, 1))
+	if err != nil {
+		return nil, -1, "", mapErr(err, gen)
+	}
+	defer r.Close()
+
+	f.mu.Lock()
+	if f.generation == 0 {
+		f.generation = r.Attrs.Generation
+	}
+	f.mu.Unlock()
+	size := r.Attrs.Size
+	validator := strconv.FormatInt(r.Attrs.Generation, 10)
+	if off >= size {
+		return nil, size, validator, io.EOF
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		// keep what arrived, the rest is retried
+		return data, size, validator, fmt.Errorf("read %d bytes: %v: %w", len(data), err, io.ErrUnexpectedEOF)
+	}
+	if remain := r.Remain(); remain > 0 {
+		return data, size, validator, fmt.Errorf("read %d bytes, %d remaining: %w", len(data), remain, io.ErrUnexpectedEOF)
+	}
+	return data, size, validator, nil
+}
+
+// mapErr maps the errors of the client to the errors of seekinghttp.
+func mapErr(err error, gen int64) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		if gen != 0 {
+			// the pinned generation was replaced or deleted
+			return fmt.Errorf("generation %d: %v: %w", gen, err, seekinghttp.ErrChanged)
+		}
+		return fmt.Errorf("%w: %w", err, seekinghttp.ErrNotFound)
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusRequestedRangeNotSatisfiable:
+			return io.EOF
+		case http.StatusForbidden:
+			return fmt.Errorf("%w: %w", err, seekinghttp.ErrForbidden)
+		}
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package gcsfetch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/paralin/seekinghttp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+)
+
+func TestFetcher(t *testing.T) {
+	// the server keeps generation 7 and the latest generation
+	bodies := map[string]string{"7": strings.Repeat("0123456789", 10), "8": strings.Repeat("abcdefghij", 10)}
+	var latest atomic.Value
+	latest.Store("7")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gen := r.URL.Query().Get("generation")
+		if gen == "" {
+			gen = latest.Load().(string)
+		}
+		body, ok := bodies[gen]
+		if r.URL.Path != "/bucket/dir/obj" || !ok || gen > latest.Load().(string) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Goog-Generation", gen)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	assert.NoError(t, err)
+	defer client.Close()
+
+	bucket, object, err := ParseURL("gs://bucket/dir/obj")
+	assert.NoError(t, err)
+	f := New(client.Bucket(bucket).Object(object), 0)
+	s := seekinghttp.NewFromFetcher("gs://bucket/dir/obj", f)
+	s.MinFetch = 0
+
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 21)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", string(buf[:n]))
+	assert.Equal(t, int64(7), f.Generation())
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+
+	// the object is overwritten, the pinned generation is still read
+	latest.Store("8")
+	n, err = s.ReadAt(buf, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, "01234", string(buf[:n]))
+
+	_, err = seekinghttp.NewFromFetcher("gs://bucket/missing", New(client.Bucket(bucket).Object("missing"), 0)).ReadAt(buf, 0)
+	assert.ErrorIs(t, err, seekinghttp.ErrNotFound)
+	_, err = seekinghttp.NewFromFetcher("gs://bucket/dir/obj", New(client.Bucket(bucket).Object(object), 3)).ReadAt(buf, 0)
+	assert.ErrorIs(t, err, seekinghttp.ErrChanged)
+
+	_, _, err = ParseURL("gs://bucket/")
+	assert.Error(t, err)
+	all, err := io.ReadAll(io.NewSectionReader(seekinghttp.NewFromFetcher("gs://bucket/dir/obj", f), 95, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, "56789", string(all))
+}
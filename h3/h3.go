@@ -0,0 +1,53 @@
+//go:build !js
+
+// Package h3 provides seekinghttp readers using HTTP/3 over QUIC, which saves
+// round trips setting up connections and avoids head-of-line blocking between
+// concurrent range requests on high latency links. It is not available under
+// GOOS=js, where the browser picks the protocol.
+package h3
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// NewTransport returns a HTTP/3 transport tuned for many small range requests
+// to the same host. The connection is kept alive between bursts of reads and
+// TLS sessions are cached to resume connections quickly. cfg may be nil, it
+// is cloned.
+func NewTransport(cfg *tls.Config) *http3.Transport {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+	if cfg.ClientSessionCache == nil {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(64)
+	}
+	return &http3.Transport{
+		TLSClientConfig: cfg,
+		QUICConfig: &quic.Config{
+			MaxIdleTimeout:  time.Minute,
+			KeepAlivePeriod: 15 * time.Second,
+			// the responses are mostly small, but allow a full MinFetch
+			// in flight without waiting for window updates
+			InitialStreamReceiveWindow:     2 << 20,
+			InitialConnectionReceiveWindow: 8 << 20,
+		},
+	}
+}
+
+// NewClient returns a client using NewTransport.
+func NewClient(cfg *tls.Config) *http.Client {
+	return &http.Client{Transport: NewTransport(cfg)}
+}
+
+// New initializes a SeekingHTTP for the https URL using HTTP/3.
+func New(url string, cfg *tls.Config) *seekinghttp.SeekingHTTP {
+	return seekinghttp.NewWithClient(url, NewClient(cfg))
+}
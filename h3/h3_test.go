@@ -0,0 +1,49 @@
+//go:build !js
+
+package h3
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 3, r.ProtoMajor)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	})
+
+	// borrow the certificate of a TLS test server
+	tlsSrv := httptest.NewTLSServer(handler)
+	defer tlsSrv.Close()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp unavailable: %v", err)
+	}
+	srv := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(tlsSrv.TLS.Clone())}
+	go func() { _ = srv.Serve(conn) }()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(tlsSrv.Certificate())
+	s := New("https://"+conn.LocalAddr().String()+"/obj", &tls.Config{RootCAs: pool, ServerName: "example.com"})
+	s.MinFetch = 0
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(buf[:n]))
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+	assert.NoError(t, s.Close())
+}
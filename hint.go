@@ -0,0 +1,71 @@
+package seekinghttp
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+)
+
+// hint is a range declared by Hint.
+type hint struct {
+	off, end int64
+}
+
+// Hint declares that the range of length bytes at off will be read, such as
+// a column chunk located by the metadata of a parquet file. A miss in a
+// hinted range loads the rest of it, or the aligned part of HintFetch bytes
+// if set, instead of the fetch length, so the small fetches suiting random
+// metadata reads do not split large chunks into many requests. The hints are
+// kept until ClearHints.
+func (s *SeekingHTTP) Hint(off, length int64) error {
+	if off < 0 || length <= 0 || length > math.MaxInt64-off {
+		return fmt.Errorf("invalid hint (%d-%d): %w", off, off+length, os.ErrInvalid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := hint{off: off, end: off + length}
+	i, _ := slices.BinarySearchFunc(s.hints, h, func(a, b hint) int {
+		return cmp.Compare(a.off, b.off)
+	})
+	s.hints = slices.Insert(s.hints, i, h)
+	return nil
+}
+
+// ClearHints drops the ranges declared by Hint.
+func (s *SeekingHTTP) ClearHints() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hints = nil
+}
+
+// hinted returns the last hinted range starting at or before off that holds
+// off.
+func (s *SeekingHTTP) hinted(off int64) (hint, bool) {
+	i, _ := slices.BinarySearchFunc(s.hints, off+1, func(h hint, off int64) int {
+		return cmp.Compare(h.off, off)
+	})
+	for i--; i >= 0; i-- {
+		if h := s.hints[i]; off < h.end {
+			return h, true
+		}
+	}
+	return hint{}, false
+}
+
+// hintRange returns the range to load on a miss of want bytes at off in the
+// hinted range h.
+func (s *SeekingHTTP) hintRange(h hint, off, want int64) (from, length int64) {
+	from, end := off, h.end
+	if a := s.HintFetch; a > 0 {
+		from = max(h.off, off-off%a)
+		end = off + want
+		if r := end % a; r != 0 && end <= math.MaxInt64-(a-r) {
+			end += a - r
+		}
+		end = min(end, h.end)
+	}
+	end = max(end, off+want)
+	return from, end - from
+}
@@ -0,0 +1,278 @@
+// Package indexfs implements an fs.FS for plain HTTP file servers, listing
+// directories by parsing their index pages and reading files with seekable
+// ranged GETs, so fs.WalkDir works over them.
+//
+// The index formats understood are the HTML and JSON formats of the nginx
+// autoindex module and the HTML of Apache and its fancy indexes. Generic HTML
+// listings with relative links to the entries mostly work too.
+package indexfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paralin/seekinghttp"
+	"golang.org/x/net/html"
+)
+
+// FS is a HTTP file server. The files are opened by the Factory, so they
+// share its client, headers and cache.
+type FS struct {
+	// Root is the URL of the root directory.
+	Root    *url.URL
+	Factory *seekinghttp.Factory
+}
+
+// _ is a type assertion
+var (
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New initializes a FS for the server directory at root with a default
+// Factory.
+func New(root string) (*FS, error) {
+	u, err := url.Parse(root)
+	if err != nil {
+		return nil, err
+	}
+	return &FS{Root: u, Factory: seekinghttp.NewFactory()}, nil
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := f.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return seekinghttp.NewDir(info, entries), nil
+	}
+
+	s := f.Factory.Open(seekinghttp.FileURL(f.Root, name, false).String())
+	if fi := info.(*fileInfo); fi.size < 0 {
+		// the listing has no size, ask the server
+		size, err := s.Size()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		fi.size = size
+	}
+	return seekinghttp.NewFile(s, info), nil
+}
+
+// Stat returns the info of the named file from the listing of its directory.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &fileInfo{name: ".", dir: true}, nil
+	}
+	infos, err := f.list(path.Dir(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	for _, info := range infos {
+		if info.name == path.Base(name) {
+			return info, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir lists the named directory, sorted by file name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := f.list(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// maxIndex is the largest index page parsed.
+const maxIndex = 64 << 20
+
+// list fetches and parses the index of the named directory.
+func (f *FS) list(name string) ([]*fileInfo, error) {
+	req, err := f.Factory.NewRequest(context.Background(), "GET", seekinghttp.FileURL(f.Root, name, true).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.Factory.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fs.ErrNotExist
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return nil, fs.ErrPermission
+	default:
+		return nil, &seekinghttp.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIndex))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") ||
+		bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		return parseJSON(body)
+	}
+	return parseHTML(body), nil
+}
+
+// jsonEntry is an entry of the nginx JSON index.
+type jsonEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	MTime string `json:"mtime"`
+	Size  *int64 `json:"size"`
+}
+
+// parseJSON parses an index in the JSON format of nginx autoindex.
+func parseJSON(body []byte) ([]*fileInfo, error) {
+	var entries []jsonEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("invalid json index: %w", err)
+	}
+	infos := make([]*fileInfo, 0, len(entries))
+	for _, e := range entries {
+		if !validName(e.Name) {
+			continue
+		}
+		info := &fileInfo{name: e.Name, dir: e.Type == "directory", size: -1}
+		if e.Size != nil {
+			info.size = *e.Size
+		}
+		if t, err := http.ParseTime(e.MTime); err == nil {
+			info.modTime = t
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// parseHTML parses an index page, taking the links to the entries of the
+// directory. The size is taken from the text following a link as written by
+// nginx, if present.
+func parseHTML(body []byte) []*fileInfo {
+	var infos []*fileInfo
+	seen := make(map[string]bool)
+	var last *fileInfo
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return infos
+		case html.TextToken:
+			if last != nil && !last.dir {
+				last.size = parseSize(string(z.Text()), last.size)
+			}
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "a" {
+				if string(name) != "td" {
+					last = nil
+				}
+				continue
+			}
+			last = nil
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) != "href" {
+					continue
+				}
+				if info := parseHref(string(val)); info != nil && !seen[info.name] {
+					seen[info.name] = true
+					infos = append(infos, info)
+					last = info
+				}
+			}
+		}
+	}
+}
+
+// parseHref returns the entry a link points to, or nil if it does not point
+// to an entry of the directory, such as links to the parent or to sort the
+// listing.
+func parseHref(href string) *fileInfo {
+	href = strings.TrimPrefix(href, "./")
+	if href == "" || strings.ContainsAny(href, "?#:") || strings.HasPrefix(href, "/") || strings.HasPrefix(href, "../") {
+		return nil
+	}
+	dir := strings.HasSuffix(href, "/")
+	name, err := url.PathUnescape(strings.TrimSuffix(href, "/"))
+	if err != nil || !validName(name) {
+		return nil
+	}
+	return &fileInfo{name: name, dir: dir, size: -1}
+}
+
+// parseSize parses the size at the end of the text after a link in an nginx
+// listing, such as "  08-Oct-2023 10:12    1234", returning size if there
+// is none.
+func parseSize(text string, size int64) int64 {
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		return size
+	}
+	if n, err := strconv.ParseInt(fields[2], 10, 64); err == nil && n >= 0 {
+		return n
+	}
+	return size
+}
+
+// validName reports whether name is a valid name of an entry.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+// fileInfo is the info of a file from a listing. size is -1 if unknown.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return max(i.size, 0) }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+func (i *fileInfo) Sys() any           { return nil }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
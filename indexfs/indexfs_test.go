@@ -0,0 +1,91 @@
+package indexfs
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileServer(t *testing.T) {
+	root := t.TempDir()
+	body := strings.Repeat("0123456789", 10)
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "dir", "sub"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "dir", "a b.txt"), []byte(body), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "dir", "sub", "c.txt"), []byte("c"), 0o644))
+	srv := httptest.NewServer(http.StripPrefix("/files", http.FileServer(http.Dir(root))))
+	defer srv.Close()
+
+	fsys, err := New(srv.URL + "/files/")
+	assert.NoError(t, err)
+	fsys.Factory.MinFetch = 0
+
+	var walked []string
+	assert.NoError(t, fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	}))
+	assert.Equal(t, []string{".", "dir", "dir/a b.txt", "dir/sub", "dir/sub/c.txt"}, walked)
+
+	f, err := fsys.Open("dir/a b.txt")
+	assert.NoError(t, err)
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), info.Size())
+	buf := make([]byte, 5)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(buf[:n]))
+	assert.NoError(t, f.Close())
+
+	_, err = fsys.Open("dir/missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.ReadDir("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestParseIndex(t *testing.T) {
+	nginx := `<html><head><title>Index of /pub/</title></head><body>
+<h1>Index of /pub/</h1><hr><pre><a href="../">../</a>
+<a href="docs/">docs/</a>                                              08-Oct-2023 10:12                   -
+<a href="archive.tar.gz">archive.tar.gz</a>                                     08-Oct-2023 10:12             1048576
+</pre><hr></body></html>`
+	infos := parseHTML([]byte(nginx))
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "docs", infos[0].name)
+		assert.True(t, infos[0].dir)
+		assert.Equal(t, "archive.tar.gz", infos[1].name)
+		assert.Equal(t, int64(1048576), infos[1].size)
+	}
+
+	apache := `<table id="list"><thead><tr>
+<th><a href="?C=N&amp;O=A">File Name</a></th><th><a href="?C=S&amp;O=A">File Size</a></th></tr></thead>
+<tbody><tr><td><a href="../">Parent directory/</a></td><td>-</td></tr>
+<tr><td><a href="sub%20dir/" title="sub dir">sub dir/</a></td><td>-</td><td>2023-Oct-08 10:12</td></tr>
+<tr><td><a href="file.bin" title="file.bin">file.bin</a></td><td>1.2 KiB</td><td>2023-Oct-08 10:12</td></tr>
+</tbody></table>`
+	infos = parseHTML([]byte(apache))
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "sub dir", infos[0].name)
+		assert.True(t, infos[0].dir)
+		assert.Equal(t, "file.bin", infos[1].name)
+		assert.Equal(t, int64(-1), infos[1].size)
+	}
+
+	infos, err := parseJSON([]byte(`[
+{ "name":"docs", "type":"directory", "mtime":"Sun, 08 Oct 2023 10:12:13 GMT" },
+{ "name":"archive.tar.gz", "type":"file", "mtime":"Sun, 08 Oct 2023 10:12:13 GMT", "size":1048576 }
+]`))
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 2) {
+		assert.True(t, infos[0].dir)
+		assert.Equal(t, int64(1048576), infos[1].size)
+		assert.Equal(t, 2023, infos[1].modTime.Year())
+	}
+}
@@ -0,0 +1,372 @@
+// Package isofs implements an fs.FS for remote ISO 9660 images, such as
+// installer media, reading the volume descriptors, the path table and the
+// directories with range requests, so single files are read out of large
+// images without downloading them. Joliet names are used if present.
+package isofs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/paralin/seekinghttp"
+)
+
+// ErrNotISO is returned by New when the object is not an ISO 9660 image.
+var ErrNotISO = errors.New("isofs: not an ISO 9660 image")
+
+const (
+	// sectorSize is the size of the sectors holding the descriptors.
+	sectorSize = 2048
+	// maxPathTable is the largest path table read.
+	maxPathTable = 64 << 20
+)
+
+// FS is an ISO 9660 image. The files are read with s.ReaderAt, so they share
+// the cache of s and may be read concurrently.
+type FS struct {
+	r         io.ReaderAt
+	blockSize int64
+	joliet    bool
+	// dirs holds the extents of the directories from the path table.
+	dirs map[string]int64
+
+	// mu guards listed
+	mu sync.Mutex
+	// listed holds the directories read, by name.
+	listed map[string][]*fileInfo
+}
+
+// _ is a type assertion
+var (
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New reads the volume descriptors and the path table of the image read by
+// s, preferring a Joliet supplementary volume over the primary volume. The
+// directories are read when first accessed.
+func New(ctx context.Context, s *seekinghttp.SeekingHTTP) (*FS, error) {
+	r := s.WithContext(ctx)
+	var vd []byte
+	var joliet bool
+	for i := int64(16); ; i++ {
+		buf := make([]byte, sectorSize)
+		if _, err := r.ReadAt(buf, i*sectorSize); err != nil {
+			if err == io.EOF {
+				err = ErrNotISO
+			}
+			return nil, fmt.Errorf("read volume descriptor %d: %w", i, err)
+		}
+		if string(buf[1:6]) != "CD001" {
+			return nil, ErrNotISO
+		}
+		typ := buf[0]
+		if typ == 255 {
+			break
+		}
+		if typ == 1 && vd == nil {
+			vd = buf
+		} else if typ == 2 && isJoliet(buf[88:120]) {
+			vd, joliet = buf, true
+		}
+	}
+	if vd == nil {
+		return nil, fmt.Errorf("no primary volume descriptor: %w", ErrNotISO)
+	}
+
+	f := &FS{
+		r:         s.ReaderAt(),
+		blockSize: int64(binary.LittleEndian.Uint16(vd[128:])),
+		joliet:    joliet,
+		dirs:      make(map[string]int64),
+		listed:    make(map[string][]*fileInfo),
+	}
+	if f.blockSize == 0 {
+		return nil, fmt.Errorf("invalid block size: %w", ErrNotISO)
+	}
+	size := int64(binary.LittleEndian.Uint32(vd[132:]))
+	if size > maxPathTable {
+		return nil, fmt.Errorf("path table of %d bytes too large", size)
+	}
+	table := make([]byte, size)
+	if _, err := r.ReadAt(table, int64(binary.LittleEndian.Uint32(vd[140:]))*f.blockSize); err != nil {
+		return nil, fmt.Errorf("read path table: %w", err)
+	}
+	if err := f.parsePathTable(table); err != nil {
+		return nil, err
+	}
+	if _, ok := f.dirs["."]; !ok {
+		f.dirs["."] = int64(binary.LittleEndian.Uint32(vd[156+2:]))
+	}
+	return f, nil
+}
+
+// isJoliet reports whether the escape sequences of a supplementary volume
+// descriptor are those of Joliet.
+func isJoliet(esc []byte) bool {
+	for _, level := range []string{"%/@", "%/C", "%/E"} {
+		if strings.Contains(string(esc), level) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePathTable parses a little-endian path table, recording the extent of
+// every directory.
+func (f *FS) parsePathTable(table []byte) error {
+	var names []string
+	for len(table) >= 8 {
+		n := int(table[0])
+		if n == 0 {
+			break
+		}
+		if 8+n+n%2 > len(table) {
+			return fmt.Errorf("truncated path table: %w", ErrNotISO)
+		}
+		extent := int64(binary.LittleEndian.Uint32(table[2:]))
+		parent := int(binary.LittleEndian.Uint16(table[6:]))
+		name := "."
+		if len(names) != 0 {
+			if parent == 0 || parent > len(names) {
+				return fmt.Errorf("invalid parent %d in path table: %w", parent, ErrNotISO)
+			}
+			name = f.decodeName(table[8:8+n], true)
+			if p := names[parent-1]; p != "." {
+				name = p + "/" + name
+			}
+		}
+		names = append(names, name)
+		if fs.ValidPath(name) {
+			f.dirs[name] = extent
+		}
+		table = table[8+n+n%2:]
+	}
+	return nil
+}
+
+// decodeName decodes a file identifier, stripping the version and the
+// trailing dot of names without extension.
+func (f *FS) decodeName(id []byte, dir bool) string {
+	var name string
+	if f.joliet {
+		u := make([]uint16, len(id)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(id[2*i:])
+		}
+		name = string(utf16.Decode(u))
+	} else {
+		name = string(id)
+	}
+	if !dir {
+		if i := strings.LastIndexByte(name, ';'); i >= 0 {
+			name = name[:i]
+		}
+		name = strings.TrimSuffix(name, ".")
+	}
+	return name
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := f.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return seekinghttp.NewDir(info, entries), nil
+	}
+	if info.interleaved {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+	return &file{SectionReader: io.NewSectionReader(f.r, info.extent*f.blockSize, info.size), info: info}, nil
+}
+
+// Stat returns the info of the named file from the directory holding it.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *FS) stat(op, name string) (*fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &fileInfo{name: ".", dir: true, extent: f.dirs["."]}, nil
+	}
+	infos, err := f.list(path.Dir(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	for _, info := range infos {
+		if info.name == path.Base(name) {
+			return info, nil
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir lists the named directory, sorted by file name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := f.list(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// list reads the records of the named directory, located by the path table.
+func (f *FS) list(name string) ([]*fileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if infos, ok := f.listed[name]; ok {
+		return infos, nil
+	}
+	extent, ok := f.dirs[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	// the first record describes the directory itself
+	buf := make([]byte, sectorSize)
+	if _, err := f.r.ReadAt(buf, extent*f.blockSize); err != nil {
+		return nil, err
+	}
+	if buf[0] < 34 {
+		return nil, fmt.Errorf("invalid directory record at block %d: %w", extent, ErrNotISO)
+	}
+	if size := int64(binary.LittleEndian.Uint32(buf[10:])); size > sectorSize {
+		if size > maxPathTable {
+			return nil, fmt.Errorf("directory of %d bytes too large", size)
+		}
+		buf = make([]byte, size)
+		if _, err := f.r.ReadAt(buf, extent*f.blockSize); err != nil {
+			return nil, err
+		}
+	} else {
+		buf = buf[:size]
+	}
+
+	infos, err := f.parseDir(buf)
+	if err != nil {
+		return nil, err
+	}
+	f.listed[name] = infos
+	return infos, nil
+}
+
+// parseDir parses the records of a directory, skipping its own and that of
+// its parent. The records of a file of several extents are merged.
+func (f *FS) parseDir(buf []byte) ([]*fileInfo, error) {
+	var infos []*fileInfo
+	var last *fileInfo
+	for off := 0; off < len(buf); {
+		n := int(buf[off])
+		if n == 0 {
+			// records do not cross sectors, skip the padding
+			off += sectorSize - off%sectorSize
+			continue
+		}
+		if n < 34 || off+n > len(buf) || 33+int(buf[off+32]) > n {
+			return nil, fmt.Errorf("invalid directory record at %d: %w", off, ErrNotISO)
+		}
+		rec := buf[off : off+n]
+		off += n
+
+		id := rec[33 : 33+int(rec[32])]
+		if len(id) == 1 && id[0] <= 1 {
+			continue
+		}
+		flags := rec[25]
+		info := &fileInfo{
+			name:    f.decodeName(id, flags&2 != 0),
+			dir:     flags&2 != 0,
+			extent:  int64(binary.LittleEndian.Uint32(rec[2:])),
+			size:    int64(binary.LittleEndian.Uint32(rec[10:])),
+			modTime: recordTime(rec[18:25]),
+		}
+		if last != nil && last.multi && last.name == info.name {
+			// another extent of the same file, contiguous unless interleaved
+			if last.extent*f.blockSize+last.size != info.extent*f.blockSize {
+				last.interleaved = true
+			}
+			last.size += info.size
+			last.multi = flags&0x80 != 0
+			continue
+		}
+		info.multi = flags&0x80 != 0
+		if !fs.ValidPath(info.name) || strings.Contains(info.name, "/") {
+			last = nil
+			continue
+		}
+		infos = append(infos, info)
+		last = info
+	}
+	return infos, nil
+}
+
+// recordTime decodes the recording time of a directory record.
+func recordTime(b []byte) time.Time {
+	if b[0] == 0 {
+		return time.Time{}
+	}
+	zone := time.FixedZone("", int(int8(b[6]))*15*60)
+	return time.Date(1900+int(b[0]), time.Month(b[1]), int(b[2]), int(b[3]), int(b[4]), int(b[5]), 0, zone)
+}
+
+// fileInfo is the info of a file from its directory record.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	// extent is the first block of the data, multi is set while more
+	// extents follow and interleaved if they are not contiguous.
+	extent      int64
+	multi       bool
+	interleaved bool
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+func (i *fileInfo) Sys() any           { return nil }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// file is an open file of the image.
+type file struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
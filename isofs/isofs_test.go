@@ -0,0 +1,186 @@
+package isofs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+	"unicode/utf16"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/stretchr/testify/assert"
+)
+
+// both encodes v in both byte orders.
+func both(b []byte, v uint32) []byte {
+	b = binary.LittleEndian.AppendUint32(b, v)
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+// record returns a directory record.
+func record(id []byte, extent, size uint32, flags byte) []byte {
+	b := []byte{0, 0}
+	b = both(b, extent)
+	b = both(b, size)
+	b = append(b, 123, 10, 8, 12, 30, 0, 4, flags, 0, 0, 1, 0, 0, 1, byte(len(id)))
+	b = append(b, id...)
+	if len(b)%2 != 0 {
+		b = append(b, 0)
+	}
+	b[0] = byte(len(b))
+	return b
+}
+
+// ucs2 encodes a Joliet name.
+func ucs2(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.BigEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// buildISO builds an image with a file at the root and a directory holding
+// a file of two extents, with Joliet names if joliet is set.
+func buildISO(joliet bool) []byte {
+	img := make([]byte, 32*sectorSize)
+	sector := func(i int) []byte { return img[i*sectorSize : (i+1)*sectorSize] }
+	name := func(iso, long string) []byte {
+		if joliet {
+			return ucs2(long)
+		}
+		return []byte(iso)
+	}
+	readme := []byte("hello from the image\n")
+	split := bytes.Repeat([]byte("s"), sectorSize+100)
+	copy(sector(25), readme)
+	copy(img[26*sectorSize:], split)
+
+	// the root at 21 and the directory at 22
+	root := bytes.Join([][]byte{
+		record([]byte{0}, 21, sectorSize, 2),
+		record([]byte{1}, 21, sectorSize, 2),
+		record(name("DIR", "Sub Dir"), 22, sectorSize, 2),
+		record(name("README.TXT;1", "ReadMe.txt;1"), 25, uint32(len(readme)), 0),
+	}, nil)
+	copy(sector(21), root)
+	sub := bytes.Join([][]byte{
+		record([]byte{0}, 22, sectorSize, 2),
+		record([]byte{1}, 21, sectorSize, 2),
+		record(name("SPLIT.BIN;1", "split.bin;1"), 26, sectorSize, 0x80),
+		record(name("SPLIT.BIN;1", "split.bin;1"), 27, 100, 0),
+	}, nil)
+	copy(sector(22), sub)
+
+	// the path table at 19
+	var table []byte
+	table = append(table, 1, 0)
+	table = binary.LittleEndian.AppendUint32(table, 21)
+	table = binary.LittleEndian.AppendUint16(table, 1)
+	table = append(table, 0, 0)
+	dirName := name("DIR", "Sub Dir")
+	table = append(table, byte(len(dirName)), 0)
+	table = binary.LittleEndian.AppendUint32(table, 22)
+	table = binary.LittleEndian.AppendUint16(table, 1)
+	table = append(table, dirName...)
+	if len(dirName)%2 != 0 {
+		table = append(table, 0)
+	}
+	copy(sector(19), table)
+
+	vd := sector(16)
+	vd[0] = 1
+	if joliet {
+		// an empty primary volume and the Joliet volume
+		vd[0], vd[6] = 1, 1
+		copy(vd[1:], "CD001")
+		binary.LittleEndian.PutUint16(vd[128:], sectorSize)
+		vd = sector(17)
+		vd[0] = 2
+		copy(vd[88:], "%/E")
+	}
+	copy(vd[1:], "CD001")
+	vd[6] = 1
+	binary.LittleEndian.PutUint16(vd[128:], sectorSize)
+	binary.LittleEndian.PutUint32(vd[132:], uint32(len(table)))
+	binary.LittleEndian.PutUint32(vd[140:], 19)
+	copy(vd[156:], record([]byte{0}, 21, sectorSize, 2))
+	term := sector(17)
+	if joliet {
+		term = sector(18)
+	}
+	term[0] = 255
+	copy(term[1:], "CD001")
+	return img
+}
+
+func TestISO(t *testing.T) {
+	images := map[string][]byte{"/iso": buildISO(false), "/joliet": buildISO(true), "/other": make([]byte, 64<<10)}
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(images[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct{ path, readme, split string }{
+		{"/iso", "README.TXT", "DIR/SPLIT.BIN"},
+		{"/joliet", "ReadMe.txt", "Sub Dir/split.bin"},
+	} {
+		numReq = 0
+		s := seekinghttp.New(srv.URL + c.path)
+		s.MinFetch = sectorSize
+		s.Cache = seekinghttp.NewCache(1 << 20)
+		fsys, err := New(context.Background(), s)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.NoError(t, fstest.TestFS(fsys, c.readme, c.split))
+
+		data, err := fs.ReadFile(fsys, c.readme)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello from the image\n", string(data))
+		info, err := fs.Stat(fsys, c.split)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(sectorSize+100), info.Size())
+		assert.Equal(t, 2023, info.ModTime().Year())
+
+		f, err := fsys.Open(c.split)
+		assert.NoError(t, err)
+		_, err = f.(io.Seeker).Seek(-8, io.SeekEnd)
+		assert.NoError(t, err)
+		data, err = io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("s", 8), string(data))
+		assert.NoError(t, f.Close())
+
+		_, err = fsys.Open("missing")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	}
+
+	// the descriptors and the path table are read, then a single sector
+	// to list a directory
+	numReq = 0
+	s := seekinghttp.New(srv.URL + "/iso")
+	s.MinFetch = sectorSize
+	fsys, err := New(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, numReq)
+	_, err = fsys.ReadDir("DIR")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, numReq)
+
+	_, err = New(context.Background(), seekinghttp.New(srv.URL+"/other"))
+	assert.ErrorIs(t, err, ErrNotISO)
+
+	// a path table cut before the pad byte of its last entry
+	table := []byte{1, 0, 20, 0, 0, 0, 1, 0, 0, 0, 3, 0, 21, 0, 0, 0, 1, 0, 'D', 'I', 'R'}
+	assert.ErrorIs(t, (&FS{dirs: make(map[string]int64)}).parsePathTable(table), ErrNotISO)
+}
@@ -0,0 +1,160 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// localClient is a HttpClient serving the requests of SeekingHTTP from a
+// local object, such as a file, instead of a server. It understands the
+// standard Range header only.
+type localClient struct {
+	// open opens the object of u.
+	open func(u *url.URL) (obj io.ReaderAt, size int64, closer io.Closer, err error)
+}
+
+// newFileClient returns a client serving file URLs from the file system.
+func newFileClient() *localClient {
+	return &localClient{open: func(u *url.URL) (io.ReaderAt, int64, io.Closer, error) {
+		f, err := os.Open(filepath.FromSlash(u.Path))
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		fi, err := f.Stat()
+		if err == nil && fi.IsDir() {
+			err = &os.PathError{Op: "read", Path: f.Name(), Err: os.ErrInvalid}
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, 0, nil, err
+		}
+		return f, fi.Size(), f, nil
+	}}
+}
+
+// newDataClient returns a client serving the data of the data URL rawURL,
+// which is decoded once.
+func newDataClient(rawURL string) *localClient {
+	data, err := decodeDataURL(rawURL)
+	return &localClient{open: func(*url.URL) (io.ReaderAt, int64, io.Closer, error) {
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return bytes.NewReader(data), int64(len(data)), io.NopCloser(nil), nil
+	}}
+}
+
+// decodeDataURL decodes the data of a data URL of the form
+// data:[<mediatype>][;base64],<data>.
+func decodeDataURL(rawURL string) ([]byte, error) {
+	rest, ok := strings.CutPrefix(rawURL, "data:")
+	if !ok {
+		return nil, fmt.Errorf("not a data URL: %q", rawURL)
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URL: missing comma")
+	}
+	data, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data URL: %w", err)
+	}
+	if !strings.HasSuffix(meta, ";base64") {
+		return []byte(data), nil
+	}
+	dec, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data URL: %w", err)
+	}
+	return dec, nil
+}
+
+// Do serves req from the object, setting the status and headers like a server
+// supporting range requests would.
+func (c *localClient) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+	setStatus := func(code int) {
+		resp.StatusCode = code
+		resp.Status = strconv.Itoa(code) + " " + http.StatusText(code)
+	}
+
+	obj, size, closer, err := c.open(req.URL)
+	switch {
+	case os.IsNotExist(err):
+		setStatus(http.StatusNotFound)
+		return resp, nil
+	case os.IsPermission(err):
+		setStatus(http.StatusForbidden)
+		return resp, nil
+	case err != nil:
+		return nil, err
+	}
+
+	start, end, ok := parseRange(req.Header.Get("Range"))
+	switch {
+	case !ok:
+		setStatus(http.StatusOK)
+		start, end = 0, size-1
+	case start >= size:
+		_ = closer.Close()
+		setStatus(http.StatusRequestedRangeNotSatisfiable)
+		resp.Header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+		return resp, nil
+	default:
+		setStatus(http.StatusPartialContent)
+		end = min(end, size-1)
+		resp.Header.Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+
+			strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(size, 10))
+	}
+	resp.ContentLength = end - start + 1
+	resp.Header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	if req.Method == "HEAD" || resp.ContentLength == 0 {
+		_ = closer.Close()
+		return resp, nil
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(obj, start, resp.ContentLength), closer}
+	return resp, nil
+}
+
+// parseRange parses a Range header of the form "bytes=start-end" as written
+// by fmtRange.
+func parseRange(hdr string) (start, end int64, ok bool) {
+	rng, ok := strings.CutPrefix(hdr, "bytes=")
+	if !ok {
+		return 0, 0, false
+	}
+	startStr, endStr, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	end, err = strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,65 @@
+package seekinghttp
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileURL(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	path := filepath.Join(t.TempDir(), "obj")
+	assert.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+	u := "file://" + filepath.ToSlash(path)
+
+	s := New(u)
+	s.MinFetch = 0
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(buf[:n]))
+	n, err = s.ReadAt(buf, 97)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "789", string(buf[:n]))
+	_, err = s.ReadAt(buf, 100)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = s.Seek(10, io.SeekStart)
+	assert.NoError(t, err)
+	all, err := io.ReadAll(s)
+	assert.NoError(t, err)
+	assert.Equal(t, body[10:], string(all))
+	assert.NoError(t, s.Close())
+
+	_, err = New(u+".missing").ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDataURL(t *testing.T) {
+	s := New("data:text/plain;base64,MDEyMzQ1Njc4OQ==")
+	s.MinFetch = 0
+	buf := make([]byte, 4)
+	n, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "3456", string(buf[:n]))
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), size)
+
+	s = New("data:,hello%20world")
+	all, err := io.ReadAll(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(all))
+
+	s = New("data:")
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorContains(t, err, "missing comma")
+}
@@ -0,0 +1,117 @@
+package seekinghttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// metadataVersion is the version of the format written by SaveMetadata.
+const metadataVersion = 1
+
+// Metadata is what a reader learned about its object, persisted by
+// SaveMetadata so a restarted process resumes with the same validators.
+type Metadata struct {
+	Version int    `json:"version"`
+	URL     string `json:"url"`
+	// Size is the size of the object, nil if unknown.
+	Size         *int64 `json:"size,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Ranges are the ranges of the object held by the cache, sorted by
+	// offset, such as to load them again with Pin.
+	Ranges []MetadataRange `json:"ranges,omitempty"`
+}
+
+// MetadataRange is a range of length bytes at Off.
+type MetadataRange struct {
+	Off    int64 `json:"off"`
+	Length int64 `json:"length"`
+}
+
+// Metadata returns what the reader learned about the object and the index of
+// the ranges cached.
+func (s *SeekingHTTP) Metadata() *Metadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := &Metadata{
+		Version:      metadataVersion,
+		URL:          s.URL,
+		ETag:         s.etag,
+		LastModified: s.lastModified,
+		Ranges:       s.cache().ranges(s.cacheKey()),
+	}
+	if s.KnownSize != nil {
+		size := *s.KnownSize
+		m.Size = &size
+	}
+	return m
+}
+
+// SaveMetadata writes the Metadata of the reader as JSON to the file at path,
+// replacing it atomically.
+func (s *SeekingHTTP) SaveMetadata(path string) error {
+	data, err := json.Marshal(s.Metadata())
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// LoadMetadata reads the Metadata saved by SaveMetadata from the file at path
+// and restores the size and validators, so the responses of an object that
+// changed since fail with ErrChanged. The metadata must be of the URL of the
+// reader, otherwise os.ErrInvalid is returned, and validators learned by the
+// reader already must match. The cached data is not restored, only listed in
+// the returned Metadata.
+func (s *SeekingHTTP) LoadMetadata(path string) (*Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid metadata %s: %w", path, err)
+	}
+	if m.Version != metadataVersion {
+		return nil, fmt.Errorf("unsupported metadata version %d: %w", m.Version, os.ErrInvalid)
+	}
+	if m.URL != s.URL {
+		return nil, fmt.Errorf("metadata of %s: %w", m.URL, os.ErrInvalid)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.etag != "" && m.ETag != "" && s.etag != m.ETag {
+		return nil, fmt.Errorf("etag %s changed to %s: %w", m.ETag, s.etag, ErrChanged)
+	}
+	if m.Size != nil {
+		size := *m.Size
+		s.KnownSize = &size
+	}
+	if m.ETag != "" {
+		s.etag = m.ETag
+	}
+	if m.LastModified != "" {
+		s.lastModified = m.LastModified
+	}
+	return m, nil
+}
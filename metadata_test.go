@@ -0,0 +1,75 @@
+package seekinghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata(t *testing.T) {
+	body := strings.Repeat("0123456789abcdef", 1024)
+	etag := `"v1"`
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, "", modTime, strings.NewReader(body))
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "meta.json")
+
+	s := New(srv.URL)
+	s.MinFetch = 1024
+	s.Cache = NewCache(1 << 20)
+	buf := make([]byte, 16)
+	for _, off := range []int64{8192, 0} {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", s.LastModified())
+	assert.NoError(t, s.SaveMetadata(path))
+
+	// a restarted reader knows the size and validators without requests
+	numReq = 0
+	s = New(srv.URL)
+	m, err := s.LoadMetadata(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []MetadataRange{{0, 1024}, {8192, 1024}}, m.Ranges)
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+	assert.Equal(t, `"v1"`, s.ETag())
+	assert.Zero(t, numReq)
+
+	// and notices changes of the object
+	etag = `"v2"`
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrChanged)
+
+	// without an ETag, by the Last-Modified time
+	etag = ""
+	s = New(srv.URL)
+	_, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, s.SaveMetadata(path))
+	modTime = modTime.Add(time.Hour)
+	s = New(srv.URL)
+	_, err = s.LoadMetadata(path)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrChanged)
+
+	_, err = New(srv.URL + "/other").LoadMetadata(path)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+	_, err = New(srv.URL).LoadMetadata(path + ".missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
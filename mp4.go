@@ -0,0 +1,97 @@
+package seekinghttp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoMoov is returned by PrepareMP4 when the object has no moov box.
+var ErrNoMoov = errors.New("seekinghttp: no moov box")
+
+// mp4StreamFetch is the SequentialFetch set by PrepareMP4 if unset.
+const mp4StreamFetch = 4 << 20
+
+// MP4Layout is the location of the top-level boxes of an MP4 file holding the
+// metadata and the media data.
+type MP4Layout struct {
+	MoovOff, MoovSize int64
+	// MdatOff and MdatSize locate the first mdat box, or are zero if there
+	// is none.
+	MdatOff, MdatSize int64
+}
+
+// FastStart reports whether the moov box precedes the media data, so players
+// can start without seeking to the end.
+func (l MP4Layout) FastStart() bool {
+	return l.MdatSize == 0 || l.MoovOff < l.MdatOff
+}
+
+// PrepareMP4 prepares s for playing or transcoding an MP4 or QuickTime file.
+// It walks the top-level boxes from the head, skipping the media data to
+// find the moov box at the tail of files that are not fast-start, and pins
+// the moov box in the cache, so the sample tables stay cached while the
+// media data is streamed. For streaming the mdat box, DoubleBuffer is
+// enabled and SequentialFetch set to 4 MiB unless set.
+func (s *SeekingHTTP) PrepareMP4(ctx context.Context) (MP4Layout, error) {
+	var l MP4Layout
+	size, err := s.SizeContext(ctx)
+	if err != nil {
+		return l, err
+	}
+
+	var hdr [16]byte
+	for off := int64(0); off < size; {
+		n, err := s.ReadAtContext(ctx, hdr[:min(size-off, 16)], off)
+		if err != nil && err != io.EOF {
+			return l, err
+		}
+		if n < 8 {
+			break
+		}
+		boxSize, hdrLen := int64(binary.BigEndian.Uint32(hdr[:4])), int64(8)
+		switch boxSize {
+		case 0:
+			// the box extends to the end
+			boxSize = size - off
+		case 1:
+			if n < 16 {
+				return l, fmt.Errorf("truncated header of box %q at %d: %w", hdr[4:8], off, io.ErrUnexpectedEOF)
+			}
+			boxSize, hdrLen = int64(binary.BigEndian.Uint64(hdr[8:16])), 16
+		}
+		if boxSize < hdrLen || boxSize > size-off {
+			return l, fmt.Errorf("invalid size %d of box %q at %d: %w", boxSize, hdr[4:8], off, ErrNoMoov)
+		}
+
+		switch string(hdr[4:8]) {
+		case "moov":
+			l.MoovOff, l.MoovSize = off, boxSize
+		case "mdat":
+			if l.MdatSize == 0 {
+				l.MdatOff, l.MdatSize = off, boxSize
+			}
+		}
+		if l.MoovSize != 0 && l.MdatSize != 0 {
+			break
+		}
+		off += boxSize
+	}
+	if l.MoovSize == 0 {
+		return l, ErrNoMoov
+	}
+	if s.Logger != nil {
+		s.Logger.Debugf("mp4: moov at %d (%d bytes), mdat at %d (%d bytes)", l.MoovOff, l.MoovSize, l.MdatOff, l.MdatSize)
+	}
+
+	if err := s.Pin(ctx, l.MoovOff, l.MoovSize); err != nil {
+		return l, err
+	}
+	if s.SequentialFetch == 0 {
+		s.SequentialFetch = mp4StreamFetch
+	}
+	s.DoubleBuffer = true
+	return l, nil
+}
@@ -0,0 +1,120 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mp4Box returns a box of type typ with a body of n bytes.
+func mp4Box(typ string, n int, large bool) []byte {
+	var b []byte
+	if large {
+		b = binary.BigEndian.AppendUint32(b, 1)
+		b = append(b, typ...)
+		b = binary.BigEndian.AppendUint64(b, uint64(16+n))
+	} else {
+		b = binary.BigEndian.AppendUint32(b, uint32(8+n))
+		b = append(b, typ...)
+	}
+	return append(b, bytes.Repeat([]byte(typ[:1]), n)...)
+}
+
+func TestPrepareMP4(t *testing.T) {
+	ftyp := mp4Box("ftyp", 16, false)
+	mdat := mp4Box("mdat", 256<<10, true)
+	moov := mp4Box("moov", 2000, false)
+	files := map[string][]byte{
+		"/tail":   bytes.Join([][]byte{ftyp, mdat, moov}, nil),
+		"/fast":   bytes.Join([][]byte{ftyp, moov, mdat}, nil),
+		"/nomoov": bytes.Join([][]byte{ftyp, mdat}, nil),
+	}
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(files[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "/tail")
+	s.MinFetch = 4096
+	s.Cache = NewCache(4096)
+	l, err := s.PrepareMP4(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, MP4Layout{MoovOff: 24 + 16 + 256<<10, MoovSize: 2008, MdatOff: 24, MdatSize: 16 + 256<<10}, l)
+	assert.False(t, l.FastStart())
+	assert.True(t, s.DoubleBuffer)
+	assert.Equal(t, int64(mp4StreamFetch), s.SequentialFetch)
+	// the HEAD, the head and the tail
+	assert.Equal(t, []string{"", "bytes=0-4095", "bytes=262184-264191"}, ranges)
+
+	// streaming the mdat box does not evict the moov box
+	s.DoubleBuffer = false
+	s.SequentialFetch = 0
+	buf := make([]byte, 4096)
+	for off := l.MdatOff; off+4096 <= l.MoovOff; off += 4096 {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+	ranges = nil
+	_, err = s.ReadAt(buf[:2008], l.MoovOff)
+	assert.NoError(t, err)
+	assert.Equal(t, moov, buf[:2008])
+	assert.Empty(t, ranges)
+
+	s.Unpin()
+	_, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4096), s.Cache.Size())
+
+	ranges = nil
+	s = New(srv.URL + "/fast")
+	s.MinFetch = 4096
+	s.SequentialFetch = 1 << 20
+	l, err = s.PrepareMP4(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, l.FastStart())
+	assert.Equal(t, int64(24), l.MoovOff)
+	assert.Equal(t, int64(1<<20), s.SequentialFetch)
+	assert.Equal(t, []string{"", "bytes=0-4095"}, ranges)
+
+	_, err = New(srv.URL + "/nomoov").PrepareMP4(context.Background())
+	assert.ErrorIs(t, err, ErrNoMoov)
+}
+
+func TestPin(t *testing.T) {
+	srv, lengths := rangeServer(t, 64<<10)
+
+	s := New(srv.URL)
+	s.MinFetch = 1024
+	assert.NoError(t, s.Pin(context.Background(), 100, 200))
+	assert.Equal(t, []int64{200}, *lengths)
+	// held by the pinned range
+	assert.NoError(t, s.Pin(context.Background(), 150, 100))
+	assert.Len(t, *lengths, 1)
+
+	// the private cache keeps the pinned range besides the last one
+	buf := make([]byte, 16)
+	_, err := s.ReadAt(buf, 8000)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(buf, 16000)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(buf, 112)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(buf))
+	assert.Equal(t, []int64{200, 1024, 1024}, *lengths)
+
+	s.Unpin()
+	_, err = s.ReadAt(buf, 8000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), s.cache().Size())
+
+	assert.ErrorIs(t, s.Pin(context.Background(), -1, 10), os.ErrInvalid)
+}
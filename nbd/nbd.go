@@ -0,0 +1,321 @@
+// Package nbd exports an object read by seekinghttp as a read-only Network
+// Block Device, so remote disk images can be attached and inspected without
+// downloading them.
+//
+// The server speaks the fixed newstyle handshake with the EXPORT_NAME, INFO,
+// GO, LIST and ABORT options and serves READ, FLUSH and DISC commands.
+// Writes and trims are refused with EPERM.
+package nbd
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/paralin/seekinghttp"
+)
+
+// Protocol constants, see the NBD protocol specification.
+const (
+	nbdMagic       = 0x4e42444d41474943 // NBDMAGIC
+	optMagic       = 0x49484156454f5054 // IHAVEOPT
+	optReplyMagic  = 0x3e889045565a9
+	requestMagic   = 0x25609513
+	simpleRepMagic = 0x67446698
+
+	flagFixedNewstyle = 1 << 0
+	flagNoZeroes      = 1 << 1
+
+	optExportName = 1
+	optAbort      = 2
+	optList       = 3
+	optInfo       = 6
+	optGo         = 7
+
+	repAck        = 1
+	repServer     = 2
+	repInfo       = 3
+	repErrUnsup   = 1<<31 + 1
+	repErrInvalid = 1<<31 + 3
+	repErrUnknown = 1<<31 + 6
+
+	infoExport    = 0
+	infoBlockSize = 3
+
+	transHasFlags = 1 << 0
+	transReadOnly = 1 << 1
+	transFlush    = 1 << 2
+	transMulti    = 1 << 8
+
+	cmdRead  = 0
+	cmdWrite = 1
+	cmdDisc  = 2
+	cmdFlush = 3
+	cmdTrim  = 4
+
+	errPerm  = 1
+	errIO    = 5
+	errInval = 22
+)
+
+// maxRead is the largest read served, longer requests are refused.
+const maxRead = 32 * 1024 * 1024
+
+// Server serves a read-only export of an object.
+type Server struct {
+	// ReaderAt reads the object. It is used by all connections at once and
+	// must be safe for concurrent use.
+	ReaderAt io.ReaderAt
+	// Size is the size of the export in bytes.
+	Size int64
+	// Name is the name of the export. Clients may also ask for the default
+	// export with an empty name.
+	Name string
+	// BlockSize is the block size advertised to clients, 4096 if zero.
+	BlockSize uint32
+}
+
+// New returns a server exporting the object read by s, whose size is
+// loaded.
+func New(ctx context.Context, s *seekinghttp.SeekingHTTP, name string) (*Server, error) {
+	size, err := s.SizeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{ReaderAt: s.ReaderAt(), Size: size, Name: name}, nil
+}
+
+// Serve serves the connections accepted by l until it fails.
+func (srv *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			_ = srv.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves a single connection and closes it. It returns nil when
+// the client disconnects.
+func (srv *Server) ServeConn(conn io.ReadWriteCloser) error {
+	defer conn.Close()
+	noZeroes, err := srv.handshake(conn)
+	if err != nil {
+		return err
+	}
+	ok, err := srv.negotiate(conn, noZeroes)
+	if err != nil || !ok {
+		return err
+	}
+	return srv.transmit(conn)
+}
+
+// handshake greets the client and returns whether it skips the zeroes
+// after an EXPORT_NAME reply.
+func (srv *Server) handshake(rw io.ReadWriter) (bool, error) {
+	var hello [18]byte
+	binary.BigEndian.PutUint64(hello[0:], nbdMagic)
+	binary.BigEndian.PutUint64(hello[8:], optMagic)
+	binary.BigEndian.PutUint16(hello[16:], flagFixedNewstyle|flagNoZeroes)
+	if _, err := rw.Write(hello[:]); err != nil {
+		return false, err
+	}
+	var flags uint32
+	if err := binary.Read(rw, binary.BigEndian, &flags); err != nil {
+		return false, err
+	}
+	if flags&flagFixedNewstyle == 0 {
+		return false, errors.New("client does not support the fixed newstyle handshake")
+	}
+	return flags&flagNoZeroes != 0, nil
+}
+
+// negotiate handles the options of the client and returns whether the
+// transmission phase starts.
+func (srv *Server) negotiate(rw io.ReadWriter, noZeroes bool) (bool, error) {
+	for {
+		var hdr struct {
+			Magic  uint64
+			Option uint32
+			Length uint32
+		}
+		if err := binary.Read(rw, binary.BigEndian, &hdr); err != nil {
+			return false, err
+		}
+		if hdr.Magic != optMagic {
+			return false, fmt.Errorf("invalid option magic %#x", hdr.Magic)
+		}
+		if hdr.Length > 4096 {
+			return false, fmt.Errorf("option %d of %d bytes is too long", hdr.Option, hdr.Length)
+		}
+		data := make([]byte, hdr.Length)
+		if _, err := io.ReadFull(rw, data); err != nil {
+			return false, err
+		}
+
+		switch hdr.Option {
+		case optExportName:
+			if !srv.known(string(data)) {
+				// there is no error reply to EXPORT_NAME
+				return false, fmt.Errorf("unknown export %q", data)
+			}
+			reply := make([]byte, 10, 10+124)
+			binary.BigEndian.PutUint64(reply[0:], uint64(srv.Size))
+			binary.BigEndian.PutUint16(reply[8:], srv.flags())
+			if !noZeroes {
+				reply = reply[:10+124]
+			}
+			_, err := rw.Write(reply)
+			return err == nil, err
+		case optAbort:
+			return false, reply(rw, hdr.Option, repAck, nil)
+		case optList:
+			name := binary.BigEndian.AppendUint32(nil, uint32(len(srv.Name)))
+			if err := reply(rw, hdr.Option, repServer, append(name, srv.Name...)); err != nil {
+				return false, err
+			}
+			if err := reply(rw, hdr.Option, repAck, nil); err != nil {
+				return false, err
+			}
+		case optInfo, optGo:
+			if len(data) < 4 || int(binary.BigEndian.Uint32(data))+6 > len(data) {
+				if err := reply(rw, hdr.Option, repErrInvalid, nil); err != nil {
+					return false, err
+				}
+				continue
+			}
+			name := string(data[4 : 4+binary.BigEndian.Uint32(data)])
+			if !srv.known(name) {
+				if err := reply(rw, hdr.Option, repErrUnknown, nil); err != nil {
+					return false, err
+				}
+				continue
+			}
+			if err := srv.info(rw, hdr.Option); err != nil {
+				return false, err
+			}
+			if hdr.Option == optGo {
+				return true, nil
+			}
+		default:
+			if err := reply(rw, hdr.Option, repErrUnsup, nil); err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+// known returns whether name is the export.
+func (srv *Server) known(name string) bool {
+	return name == "" || name == srv.Name
+}
+
+// flags returns the transmission flags of the export.
+func (srv *Server) flags() uint16 {
+	return transHasFlags | transReadOnly | transFlush | transMulti
+}
+
+// info replies to INFO and GO with the size and block sizes of the export.
+func (srv *Server) info(w io.Writer, option uint32) error {
+	export := binary.BigEndian.AppendUint16(nil, infoExport)
+	export = binary.BigEndian.AppendUint64(export, uint64(srv.Size))
+	export = binary.BigEndian.AppendUint16(export, srv.flags())
+	if err := reply(w, option, repInfo, export); err != nil {
+		return err
+	}
+	bs := srv.BlockSize
+	if bs == 0 {
+		bs = 4096
+	}
+	sizes := binary.BigEndian.AppendUint16(nil, infoBlockSize)
+	sizes = binary.BigEndian.AppendUint32(sizes, 1)
+	sizes = binary.BigEndian.AppendUint32(sizes, bs)
+	sizes = binary.BigEndian.AppendUint32(sizes, maxRead)
+	if err := reply(w, option, repInfo, sizes); err != nil {
+		return err
+	}
+	return reply(w, option, repAck, nil)
+}
+
+// reply writes an option reply.
+func reply(w io.Writer, option, typ uint32, data []byte) error {
+	buf := binary.BigEndian.AppendUint64(make([]byte, 0, 20+len(data)), optReplyMagic)
+	buf = binary.BigEndian.AppendUint32(buf, option)
+	buf = binary.BigEndian.AppendUint32(buf, typ)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	_, err := w.Write(append(buf, data...))
+	return err
+}
+
+// transmit serves the commands of the client until it disconnects.
+func (srv *Server) transmit(rw io.ReadWriter) error {
+	var buf []byte
+	for {
+		var req struct {
+			Magic  uint32
+			Flags  uint16
+			Type   uint16
+			Cookie uint64
+			Offset uint64
+			Length uint32
+		}
+		if err := binary.Read(rw, binary.BigEndian, &req); err != nil {
+			return err
+		}
+		if req.Magic != requestMagic {
+			return fmt.Errorf("invalid request magic %#x", req.Magic)
+		}
+
+		var code uint32
+		var data []byte
+		switch req.Type {
+		case cmdRead:
+			switch {
+			case req.Length > maxRead:
+				code = errInval
+			case req.Offset > uint64(srv.Size) || uint64(req.Length) > uint64(srv.Size)-req.Offset:
+				code = errInval
+			default:
+				if cap(buf) < int(req.Length) {
+					buf = make([]byte, req.Length)
+				}
+				data = buf[:req.Length]
+				n, err := srv.ReaderAt.ReadAt(data, int64(req.Offset))
+				if n < len(data) && err != nil {
+					code, data = errIO, nil
+				}
+			}
+		case cmdWrite:
+			// drop the data of the refused write
+			if _, err := io.CopyN(io.Discard, rw, int64(req.Length)); err != nil {
+				return err
+			}
+			code = errPerm
+		case cmdTrim:
+			code = errPerm
+		case cmdFlush:
+		case cmdDisc:
+			return nil
+		default:
+			code = errInval
+		}
+
+		rep := binary.BigEndian.AppendUint32(make([]byte, 0, 16), simpleRepMagic)
+		rep = binary.BigEndian.AppendUint32(rep, code)
+		rep = binary.BigEndian.AppendUint64(rep, req.Cookie)
+		if _, err := rw.Write(rep); err != nil {
+			return err
+		}
+		if len(data) != 0 {
+			if _, err := rw.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package nbd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client is a minimal NBD client.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func dial(t *testing.T, srv *Server) *client {
+	a, b := net.Pipe()
+	go func() {
+		_ = srv.ServeConn(b)
+	}()
+	t.Cleanup(func() { _ = a.Close() })
+	c := &client{t: t, conn: a}
+
+	var hello struct {
+		Magic, Opt uint64
+		Flags      uint16
+	}
+	c.read(&hello)
+	require.EqualValues(t, nbdMagic, hello.Magic)
+	require.EqualValues(t, optMagic, hello.Opt)
+	require.NotZero(t, hello.Flags&flagFixedNewstyle)
+	c.write(uint32(flagFixedNewstyle | flagNoZeroes))
+	return c
+}
+
+func (c *client) read(v any) {
+	require.NoError(c.t, binary.Read(c.conn, binary.BigEndian, v))
+}
+
+func (c *client) write(v any) {
+	require.NoError(c.t, binary.Write(c.conn, binary.BigEndian, v))
+}
+
+func (c *client) option(opt uint32, data []byte) {
+	c.write(struct {
+		Magic       uint64
+		Opt, Length uint32
+	}{optMagic, opt, uint32(len(data))})
+	if len(data) > 0 {
+		_, err := c.conn.Write(data)
+		require.NoError(c.t, err)
+	}
+}
+
+// reply reads an option reply and returns its type and data.
+func (c *client) reply() (uint32, []byte) {
+	var hdr struct {
+		Magic             uint64
+		Opt, Type, Length uint32
+	}
+	c.read(&hdr)
+	require.EqualValues(c.t, optReplyMagic, hdr.Magic)
+	data := make([]byte, hdr.Length)
+	_, err := io.ReadFull(c.conn, data)
+	require.NoError(c.t, err)
+	return hdr.Type, data
+}
+
+// cmd sends a command and returns the error of its reply.
+func (c *client) cmd(typ uint16, off uint64, length uint32, payload []byte) uint32 {
+	c.write(struct {
+		Magic       uint32
+		Flags, Type uint16
+		Cookie, Off uint64
+		Length      uint32
+	}{requestMagic, 0, typ, 42, off, length})
+	if payload != nil {
+		_, err := c.conn.Write(payload)
+		require.NoError(c.t, err)
+	}
+	var rep struct {
+		Magic, Error uint32
+		Cookie       uint64
+	}
+	c.read(&rep)
+	require.EqualValues(c.t, simpleRepMagic, rep.Magic)
+	require.EqualValues(c.t, 42, rep.Cookie)
+	return rep.Error
+}
+
+func goOption(name string) []byte {
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(name)))
+	return append(append(data, name...), 0, 0)
+}
+
+func TestServer(t *testing.T) {
+	image := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(image))
+	}))
+	defer hs.Close()
+
+	s := seekinghttp.New(hs.URL)
+	s.Cache = seekinghttp.NewCache(1 << 20)
+	srv, err := New(context.Background(), s, "disk")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(image), srv.Size)
+
+	c := dial(t, srv)
+	c.option(optList, nil)
+	typ, data := c.reply()
+	assert.EqualValues(t, repServer, typ)
+	assert.Equal(t, "\x00\x00\x00\x04disk", string(data))
+	typ, _ = c.reply()
+	assert.EqualValues(t, repAck, typ)
+
+	c.option(optGo, goOption("other"))
+	typ, _ = c.reply()
+	assert.EqualValues(t, repErrUnknown, typ)
+	c.option(42, nil)
+	typ, _ = c.reply()
+	assert.EqualValues(t, repErrUnsup, typ)
+
+	c.option(optGo, goOption("disk"))
+	typ, data = c.reply()
+	assert.EqualValues(t, repInfo, typ)
+	assert.EqualValues(t, len(image), binary.BigEndian.Uint64(data[2:]))
+	assert.NotZero(t, binary.BigEndian.Uint16(data[10:])&transReadOnly)
+	typ, _ = c.reply()
+	assert.EqualValues(t, repInfo, typ)
+	typ, _ = c.reply()
+	assert.EqualValues(t, repAck, typ)
+
+	assert.Zero(t, c.cmd(cmdRead, 1000, 512, nil))
+	buf := make([]byte, 512)
+	_, err = io.ReadFull(c.conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, image[1000:1512], buf)
+
+	assert.EqualValues(t, errInval, c.cmd(cmdRead, uint64(len(image))-10, 512, nil))
+	assert.EqualValues(t, errPerm, c.cmd(cmdWrite, 0, 4, []byte("evil")))
+	assert.EqualValues(t, errPerm, c.cmd(cmdTrim, 0, 4, nil))
+	assert.Zero(t, c.cmd(cmdFlush, 0, 0, nil))
+}
+
+func TestExportName(t *testing.T) {
+	srv := &Server{ReaderAt: bytes.NewReader([]byte("sector one")), Size: 10}
+	c := dial(t, srv)
+	c.option(optExportName, nil)
+	var export struct {
+		Size  uint64
+		Flags uint16
+	}
+	c.read(&export)
+	assert.EqualValues(t, 10, export.Size)
+
+	assert.Zero(t, c.cmd(cmdRead, 7, 3, nil))
+	buf := make([]byte, 3)
+	_, err := io.ReadFull(c.conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(buf))
+}
@@ -0,0 +1,133 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNoFooter is returned by PrepareParquet when the object does not end
+// with a parquet or ORC footer.
+var ErrNoFooter = errors.New("seekinghttp: no parquet or ORC footer")
+
+const (
+	// parquetTailFetch is the length pinned at the end by PrepareParquet,
+	// holding the typical footers.
+	parquetTailFetch = 64 << 10
+	// parquetMetaFetch and parquetChunkFetch are the MinFetch and HintFetch
+	// set by PrepareParquet.
+	parquetMetaFetch  = 64 << 10
+	parquetChunkFetch = 8 << 20
+)
+
+// ParquetFooter is the location of the metadata at the end of a parquet or
+// ORC file, including the trailing lengths and magic.
+type ParquetFooter struct {
+	// ORC is set for an ORC file, whose footer holds the metadata, the
+	// footer and the postscript.
+	ORC       bool
+	Off, Size int64
+}
+
+// PrepareParquet prepares s for reading a parquet or ORC file. It pins the
+// footer in the cache, loading the last 64 KiB and the rest of larger
+// footers, so the metadata stays cached while the column chunks are read.
+// MinFetch is set to 64 KiB for the random reads of the metadata and
+// HintFetch to 8 MiB unless set; call Hint with the ranges of the column
+// chunks to read from the metadata, so they are loaded by large aligned
+// requests.
+func (s *SeekingHTTP) PrepareParquet(ctx context.Context) (ParquetFooter, error) {
+	var f ParquetFooter
+	size, err := s.SizeContext(ctx)
+	if err != nil {
+		return f, err
+	}
+	tail := min(size, parquetTailFetch)
+	if err := s.Pin(ctx, size-tail, tail); err != nil {
+		return f, err
+	}
+	buf := make([]byte, tail)
+	if _, err := s.ReadAtContext(ctx, buf, size-tail); err != nil {
+		return f, err
+	}
+
+	switch {
+	case bytes.HasSuffix(buf, []byte("PAR1")) && tail >= 12:
+		n := int64(binary.LittleEndian.Uint32(buf[tail-8:]))
+		if n > size-12 {
+			return f, ErrNoFooter
+		}
+		f.Off, f.Size = size-8-n, n+8
+	case tail >= 1 && int64(buf[tail-1]) < tail:
+		ps := buf[tail-1-int64(buf[tail-1]) : tail-1]
+		footer, metadata, ok := orcPostScript(ps)
+		n := int64(len(ps)) + 1 + footer + metadata
+		if !ok || footer < 0 || metadata < 0 || n > size {
+			return f, ErrNoFooter
+		}
+		f.ORC, f.Off, f.Size = true, size-n, n
+	default:
+		return f, ErrNoFooter
+	}
+	if s.Logger != nil {
+		s.Logger.Debugf("footer at %d (%d bytes)", f.Off, f.Size)
+	}
+	if err := s.Pin(ctx, f.Off, f.Size); err != nil {
+		return f, err
+	}
+
+	s.MinFetch = parquetMetaFetch
+	if s.HintFetch == 0 {
+		s.HintFetch = parquetChunkFetch
+	}
+	return f, nil
+}
+
+// orcPostScript returns the lengths of the footer and the metadata from the
+// postscript of an ORC file, a protocol buffer message. Returns false if ps
+// is not a postscript.
+func orcPostScript(ps []byte) (footer, metadata int64, ok bool) {
+	var magic bool
+	for len(ps) != 0 {
+		key, n := binary.Uvarint(ps)
+		if n <= 0 {
+			return 0, 0, false
+		}
+		ps = ps[n:]
+		var v uint64
+		switch key & 7 {
+		case 0:
+			if v, n = binary.Uvarint(ps); n <= 0 {
+				return 0, 0, false
+			}
+		case 1:
+			n = 8
+		case 2:
+			l, m := binary.Uvarint(ps)
+			if m <= 0 || l > uint64(len(ps)-m) {
+				return 0, 0, false
+			}
+			if key>>3 == 8000 {
+				magic = string(ps[m:m+int(l)]) == "ORC"
+			}
+			n = m + int(l)
+		case 5:
+			n = 4
+		default:
+			return 0, 0, false
+		}
+		if n > len(ps) {
+			return 0, 0, false
+		}
+		ps = ps[n:]
+
+		switch key >> 3 {
+		case 1:
+			footer = int64(v)
+		case 5:
+			metadata = int64(v)
+		}
+	}
+	return footer, metadata, magic
+}
@@ -0,0 +1,83 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareParquet(t *testing.T) {
+	chunks := bytes.Repeat([]byte("c"), 300<<10)
+	meta := bytes.Repeat([]byte("m"), 100<<10)
+	var ps []byte
+	ps = binary.AppendUvarint(ps, 1<<3)
+	ps = binary.AppendUvarint(ps, 1000)
+	ps = append(ps, 2<<3, 0)
+	ps = binary.AppendUvarint(ps, 5<<3)
+	ps = binary.AppendUvarint(ps, 500)
+	ps = binary.AppendUvarint(ps, 8000<<3|2)
+	ps = append(ps, 3, 'O', 'R', 'C')
+	files := map[string][]byte{
+		"/parquet": bytes.Join([][]byte{[]byte("PAR1"), chunks, meta, binary.LittleEndian.AppendUint32(nil, uint32(len(meta))), []byte("PAR1")}, nil),
+		"/orc":     bytes.Join([][]byte{[]byte("ORC"), chunks, make([]byte, 1500), ps, {byte(len(ps))}}, nil),
+		"/csv":     []byte("a,b\n1,2\n"),
+	}
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(files[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "/parquet")
+	s.Cache = NewCache(256 << 10)
+	s.HintFetch = 64 << 10
+	f, err := s.PrepareParquet(context.Background())
+	assert.NoError(t, err)
+	size := int64(len(files["/parquet"]))
+	assert.Equal(t, ParquetFooter{Off: 4 + 300<<10, Size: 100<<10 + 8}, f)
+	assert.Equal(t, int64(parquetMetaFetch), s.MinFetch)
+	assert.Len(t, ranges, 3)
+
+	// a hinted chunk is loaded by aligned requests, other reads by small ones
+	ranges = nil
+	assert.NoError(t, s.Hint(4, 200<<10))
+	assert.NoError(t, s.Hint(4+200<<10, 100<<10))
+	buf := make([]byte, 100)
+	for _, off := range []int64{4, 100 << 10, 250 << 10} {
+		_, err = s.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"bytes=4-65535", "bytes=65536-131071", "bytes=204804-262143"}, ranges)
+	s.ClearHints()
+	ranges = nil
+	_, err = s.ReadAt(buf, 150<<10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bytes=153600-219135"}, ranges)
+
+	// the footer stays pinned
+	ranges = nil
+	_, err = s.ReadAt(buf, size-100)
+	assert.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	ranges = nil
+	s = New(srv.URL + "/orc")
+	f, err = s.PrepareParquet(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ParquetFooter{ORC: true, Off: 3 + 300<<10, Size: int64(1500 + len(ps) + 1)}, f)
+	assert.Equal(t, int64(parquetChunkFetch), s.HintFetch)
+	assert.Len(t, ranges, 2)
+
+	_, err = New(srv.URL + "/csv").PrepareParquet(context.Background())
+	assert.ErrorIs(t, err, ErrNoFooter)
+
+	assert.ErrorIs(t, s.Hint(-1, 10), os.ErrInvalid)
+}
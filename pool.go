@@ -0,0 +1,151 @@
+package seekinghttp
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// limiter bounds the number of concurrent requests. Waiting demand requests
+// get a free slot before waiting background requests, such as readaheads. A
+// nil limiter does not limit anything.
+type limiter struct {
+	// mu guards the fields below
+	mu   sync.Mutex
+	free int
+	// waiting holds the waiting demand and background requests in order.
+	waiting [2][]chan struct{}
+}
+
+// newLimiter creates a limiter allowing n concurrent requests, or nil if n
+// is not positive.
+func newLimiter(n int) *limiter {
+	if n <= 0 {
+		return nil
+	}
+	return &limiter{free: n}
+}
+
+// acquire waits for a free slot or until ctx is done. The returned function
+// frees the slot again.
+func (l *limiter) acquire(ctx context.Context, background bool) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	prio := 0
+	if background {
+		prio = 1
+	}
+
+	l.mu.Lock()
+	if l.free > 0 && len(l.waiting[0]) == 0 && len(l.waiting[prio]) == 0 {
+		l.free--
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	ready := make(chan struct{})
+	l.waiting[prio] = append(l.waiting[prio], ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return l.release, nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	if i := slices.Index(l.waiting[prio], ready); i >= 0 {
+		l.waiting[prio] = slices.Delete(l.waiting[prio], i, i+1)
+		l.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	l.mu.Unlock()
+	// the slot was handed over at the same time, pass it on
+	l.release()
+	return nil, ctx.Err()
+}
+
+// release hands the slot to the first waiting request or frees it.
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for prio, waiting := range l.waiting {
+		if len(waiting) != 0 {
+			close(waiting[0])
+			l.waiting[prio] = waiting[1:]
+			return
+		}
+	}
+	l.free++
+}
+
+// ReaderPool hands out readers of one object which share a cache and a limit
+// of concurrent requests. It suits servers that open the same remote object
+// for many concurrent requests: a reader from the pool costs a small struct
+// instead of a private cache.
+//
+// A ReaderPool is safe for concurrent use, the readers it returns are not.
+type ReaderPool struct {
+	// mu guards the fields below
+	mu   sync.Mutex
+	tmpl *SeekingHTTP
+	free []*SeekingHTTP
+}
+
+// NewReaderPool creates a pool of readers configured like s. At most
+// maxConns requests are in flight at once across all readers of the pool,
+// zero means unlimited.
+//
+// The readers share s.Cache. If it is nil, a cache holding one MinFetch range
+// per connection is created.
+func NewReaderPool(s *SeekingHTTP, maxConns int) *ReaderPool {
+	tmpl := s.Clone(false)
+	tmpl.Cache = s.Cache
+	if tmpl.Cache == nil {
+		tmpl.Cache = NewCache(max(s.MinFetch, 1) * int64(max(maxConns, 1)))
+	}
+	tmpl.limiter = newLimiter(maxConns)
+	return &ReaderPool{tmpl: tmpl}
+}
+
+// Cache returns the cache shared by the readers of the pool.
+func (p *ReaderPool) Cache() *Cache {
+	return p.tmpl.Cache
+}
+
+// Get returns a reader positioned at the start of the object.
+func (p *ReaderPool) Get() *SeekingHTTP {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n := len(p.free); n != 0 {
+		r := p.free[n-1]
+		p.free[n-1] = nil
+		p.free = p.free[:n-1]
+		r.learn(p.tmpl)
+		return r
+	}
+	return p.tmpl.Clone(true)
+}
+
+// Put returns a reader obtained from Get to the pool. What the reader learned
+// about the object is kept for the following readers, its readahead is
+// canceled and its offset, hints, fetch length and Stats are reset. Closed
+// readers are dropped.
+func (p *ReaderPool) Put(r *SeekingHTTP) {
+	r.mu.Lock()
+	closed := r.closed
+	r.dropAhead()
+	r.offset = 0
+	r.hints = nil
+	r.tuner = fetchTuner{}
+	r.mu.Unlock()
+	r.ResetStats()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tmpl.learn(r)
+	if closed {
+		return
+	}
+	p.free = append(p.free, r)
+}
@@ -0,0 +1,158 @@
+package seekinghttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderPool(t *testing.T) {
+	body := strings.Repeat("0123456789abcdefghij", 100)
+	var numReq, inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 100
+	assert.Equal(t, int64(200), NewReaderPool(s, 2).Cache().maxBytes)
+
+	s.Cache = NewCache(int64(len(body)))
+	p := NewReaderPool(s, 2)
+	assert.Same(t, s.Cache, p.Cache())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := p.Get()
+			defer p.Put(r)
+
+			off, err := r.Seek(int64(i*100), 0)
+			assert.NoError(t, err)
+			buf := make([]byte, 10)
+			n, err := r.Read(buf)
+			assert.NoError(t, err)
+			assert.Equal(t, body[off:off+10], string(buf[:n]))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+	assert.Equal(t, int32(16), numReq.Load())
+
+	// readers from the pool start at the beginning, know the size and read
+	// from the shared cache
+	r := p.Get()
+	assert.Equal(t, int64(0), r.offset)
+	if assert.NotNil(t, r.KnownSize) {
+		assert.Equal(t, int64(len(body)), *r.KnownSize)
+	}
+	_, err := r.ReadAt(make([]byte, 10), 1500)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(16), numReq.Load())
+}
+
+func TestReaderPoolReset(t *testing.T) {
+	body := strings.Repeat("0123456789abcdef", 1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 4096
+	s.DoubleBuffer = true
+	p := NewReaderPool(s, 0)
+	r := p.Get()
+	buf := make([]byte, 1024)
+	for _, off := range []int64{0, 4096 - 512} {
+		_, err := r.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, r.Hint(8192, 100))
+	_, err := r.Discard(4096)
+	assert.NoError(t, err)
+	assert.NotNil(t, r.ahead)
+	p.Put(r)
+
+	// the next user of the reader starts clean
+	r2 := p.Get()
+	assert.Same(t, r, r2)
+	assert.Nil(t, r2.ahead)
+	assert.Empty(t, r2.hints)
+	assert.Equal(t, fetchTuner{}, r2.tuner)
+	assert.Equal(t, int64(0), r2.offset)
+	assert.Zero(t, r2.Stats().Requests)
+}
+
+func TestLimiterPriority(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(1)
+	release, err := l.acquire(ctx, false)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	wait := func(name string, background bool) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(ctx, background)
+			if assert.NoError(t, err) {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				release()
+			}
+		}()
+	}
+	queued := func(prio, n int) func() bool {
+		return func() bool {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return len(l.waiting[prio]) == n
+		}
+	}
+	wait("background", true)
+	assert.Eventually(t, queued(1, 1), time.Second, time.Millisecond)
+	wait("demand", false)
+	assert.Eventually(t, queued(0, 1), time.Second, time.Millisecond)
+
+	// a canceled request leaves the queue
+	cctx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		_, err := l.acquire(cctx, false)
+		done <- err
+	}()
+	assert.Eventually(t, queued(0, 2), time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.True(t, queued(0, 1)())
+
+	release()
+	wg.Wait()
+	assert.Equal(t, []string{"demand", "background"}, order)
+	assert.Equal(t, 1, l.free)
+}
@@ -0,0 +1,237 @@
+package seekinghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoRanges is returned by Probe when the server answers range requests
+// with the whole object.
+var ErrNoRanges = errors.New("seekinghttp: server does not support ranges")
+
+// Capabilities describes what the server of an object supports, as learned
+// by Probe and the reads, for choosing strategies and for debugging.
+type Capabilities struct {
+	// Ranges is set if the server answers range requests with the range.
+	Ranges bool
+	// Head is set if the server answers HEAD requests. The reader learns
+	// the size with range requests otherwise.
+	Head bool
+	// Size is set if the server reports the size of the object.
+	Size bool
+	// ETag is set if the server sends an entity tag or validator.
+	ETag bool
+	// IfRange is set if the server answers a range request whose If-Range
+	// does not match with the whole object.
+	IfRange bool
+	// MaxRange is the longest range response observed.
+	MaxRange int64
+}
+
+// String lists the capabilities, such as "ranges head size max-range=1024".
+func (c Capabilities) String() string {
+	var caps []string
+	for _, f := range []struct {
+		name string
+		ok   bool
+	}{{"ranges", c.Ranges}, {"head", c.Head}, {"size", c.Size}, {"etag", c.ETag}, {"if-range", c.IfRange}} {
+		if f.ok {
+			caps = append(caps, f.name)
+		}
+	}
+	return strings.Join(append(caps, fmt.Sprintf("max-range=%d", c.MaxRange)), " ")
+}
+
+// Capabilities returns the capabilities of the server learned so far. Head
+// and IfRange are only learned by Probe.
+func (s *SeekingHTTP) Capabilities() Capabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.caps
+	c.Size = c.Size || s.KnownSize != nil
+	c.ETag = c.ETag || s.etag != ""
+	return c
+}
+
+// ProbeResult is the result of Probe.
+type ProbeResult struct {
+	// Status is the status of the response, zero for a Fetcher.
+	Status int
+	// Size is the size of the object, or -1 if unknown.
+	Size int64
+	// ETag is the entity tag or validator of the object, if any.
+	ETag string
+	// Latency is the time until the response headers arrived.
+	Latency time.Duration
+
+	Capabilities Capabilities
+}
+
+// Probe cheaply checks that the object is reachable, authorized and can be
+// read by range requests, such as to health-check a data source at startup.
+// It requests the first byte, learning the size and ETag if not known yet,
+// and then probes the other capabilities of the server with a HEAD and a
+// range request with a mismatched If-Range.
+//
+// Unsuccessful responses return a StatusError and servers ignoring the range
+// return ErrNoRanges without downloading the object; the result describes
+// the response in both cases.
+func (s *SeekingHTTP) Probe(ctx context.Context) (*ProbeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, os.ErrClosed
+	}
+
+	res, err := s.probe(ctx)
+	if res == nil {
+		return nil, s.ctxErr(ctx, err)
+	}
+	if res.Size >= 0 && s.KnownSize == nil {
+		size := res.Size
+		s.KnownSize = &size
+	}
+	if s.etag == "" {
+		s.etag = res.ETag
+	}
+	if err == nil && s.Fetcher == nil {
+		s.probeHead(ctx, res)
+		s.probeIfRange(ctx, res)
+	}
+	res.Capabilities.MaxRange = max(res.Capabilities.MaxRange, s.caps.MaxRange)
+	s.caps = res.Capabilities
+	return res, s.ctxErr(ctx, err)
+}
+
+// probe requests the first byte of the object.
+func (s *SeekingHTTP) probe(ctx context.Context) (*ProbeResult, error) {
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	release, err := s.limiter.acquire(ctx, s.background)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	s.stats.requests.Add(1)
+	start := time.Now()
+	if s.Fetcher != nil {
+		data, size, validator, err := s.Fetcher.FetchRange(ctx, 0, 1)
+		s.countEgress(int64(len(data)))
+		if err == io.EOF {
+			size, err = 0, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &ProbeResult{Size: size, ETag: validator, Latency: time.Since(start), Capabilities: Capabilities{
+			Ranges: true, Size: size >= 0, ETag: validator != "", MaxRange: int64(len(data)),
+		}}, nil
+	}
+
+	req, err := s.newReq(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.rangeStrategy().SetRange(req, 0, 1)
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	// the body is not drained, a full response may be the whole object
+	defer resp.Body.Close()
+
+	res := &ProbeResult{
+		Status:  resp.StatusCode,
+		Size:    -1,
+		ETag:    resp.Header.Get("ETag"),
+		Latency: time.Since(start),
+	}
+	res.Capabilities.ETag = res.ETag != ""
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+	default:
+		return res, s.statusErr(resp)
+	}
+	rr, err := s.rangeStrategy().ResponseRange(resp, 0)
+	if err != nil {
+		return res, err
+	}
+	res.Size = rr.Size
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// an empty object
+		res.Capabilities.Ranges = true
+		res.Capabilities.Size = res.Size >= 0
+		return res, nil
+	}
+	if !rr.Partial {
+		res.Size = resp.ContentLength
+		res.Capabilities.Size = res.Size >= 0
+		return res, ErrNoRanges
+	}
+	res.Capabilities.Ranges = true
+	res.Capabilities.Size = res.Size >= 0
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+	s.countEgress(n)
+	res.Capabilities.MaxRange = n
+	return res, err
+}
+
+// probeHead checks whether the server answers HEAD requests.
+func (s *SeekingHTTP) probeHead(ctx context.Context, res *ProbeResult) {
+	if s.method() != "GET" {
+		return
+	}
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	req, err := s.newReq(ctx)
+	if err != nil {
+		return
+	}
+	req.Method = "HEAD"
+	if resp, ok := s.probeReq(ctx, req); ok {
+		res.Capabilities.Head = resp.StatusCode/100 == 2
+		s.noHead = !res.Capabilities.Head
+	}
+}
+
+// probeIfRange checks whether the server honors If-Range, which is only
+// possible if it sends an ETag.
+func (s *SeekingHTTP) probeIfRange(ctx context.Context, res *ProbeResult) {
+	if res.ETag == "" {
+		return
+	}
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	req, err := s.newReq(ctx)
+	if err != nil {
+		return
+	}
+	s.rangeStrategy().SetRange(req, 0, 1)
+	req.Header.Set("If-Range", `"seekinghttp-probe"`)
+	if resp, ok := s.probeReq(ctx, req); ok {
+		res.Capabilities.IfRange = resp.StatusCode == http.StatusOK
+	}
+}
+
+// probeReq issues a request of the probe, closing the body without reading.
+func (s *SeekingHTTP) probeReq(ctx context.Context, req *http.Request) (*http.Response, bool) {
+	release, err := s.limiter.acquire(ctx, s.background)
+	if err != nil {
+		return nil, false
+	}
+	defer release()
+	s.stats.requests.Add(1)
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, false
+	}
+	_ = resp.Body.Close()
+	return resp, true
+}
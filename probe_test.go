@@ -0,0 +1,147 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbe(t *testing.T) {
+	body := strings.Repeat("0123456789", 1000)
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Method+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/nohead":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+		case "/slowhead":
+			if r.Method == "HEAD" {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(2 * time.Second):
+				}
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+		case "/secret":
+			w.WriteHeader(http.StatusForbidden)
+		case "/norange":
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			_, _ = io.WriteString(w, body)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	s := New(srv.URL)
+	res, err := s.Probe(ctx)
+	assert.NoError(t, err)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, http.StatusPartialContent, res.Status)
+		assert.Equal(t, int64(len(body)), res.Size)
+		assert.Equal(t, `"v1"`, res.ETag)
+		caps := Capabilities{Ranges: true, Head: true, Size: true, ETag: true, IfRange: true, MaxRange: 1}
+		assert.Equal(t, caps, res.Capabilities)
+		assert.Equal(t, "ranges head size etag if-range max-range=1", caps.String())
+	}
+	mu.Lock()
+	assert.Equal(t, []string{"GET bytes=0-0", "HEAD ", "GET bytes=0-0"}, ranges)
+	mu.Unlock()
+	if assert.NotNil(t, s.KnownSize) {
+		assert.Equal(t, int64(len(body)), *s.KnownSize)
+	}
+	assert.Equal(t, `"v1"`, s.ETag())
+	assert.Equal(t, int64(1), s.Egress())
+
+	// the whole object is not downloaded
+	s = New(srv.URL + "/norange")
+	res, err = s.Probe(ctx)
+	assert.ErrorIs(t, err, ErrNoRanges)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, Capabilities{Size: true}, res.Capabilities)
+		assert.Equal(t, int64(len(body)), res.Size)
+	}
+	assert.Zero(t, s.Egress())
+
+	// Close aborts the probes of the capabilities
+	s = New(srv.URL + "/slowhead")
+	time.AfterFunc(50*time.Millisecond, func() { _ = s.Close() })
+	start := time.Now()
+	_, err = s.Probe(ctx)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	res, err = New(srv.URL + "/secret").Probe(ctx)
+	assert.ErrorIs(t, err, ErrForbidden)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, http.StatusForbidden, res.Status)
+	}
+
+	f := &testFetcher{body: body, maxLen: 8, validator: "v2"}
+	res, err = NewFromFetcher("test://obj", f).Probe(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &ProbeResult{Size: int64(len(body)), ETag: "v2", Latency: res.Latency, Capabilities: Capabilities{
+		Ranges: true, Size: true, ETag: true, MaxRange: 1,
+	}}, res)
+}
+
+func TestCapabilities(t *testing.T) {
+	body := strings.Repeat("0123456789", 1000)
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	// reads learn the capabilities too
+	s := New(srv.URL)
+	s.MinFetch = 4096
+	_, err := s.ReadAt(make([]byte, 10), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Capabilities{Ranges: true, Size: true, MaxRange: 4096}, s.Capabilities())
+
+	res, err := s.Probe(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Capabilities{Ranges: true, Size: true, MaxRange: 4096}, res.Capabilities)
+
+	// the size is then learned without HEAD
+	methods = nil
+	c := s.Clone(false)
+	c.KnownSize = nil
+	size, err := c.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+	assert.Equal(t, []string{"GET"}, methods)
+
+	// which the reader also learns from a failed HEAD
+	methods = nil
+	s = New(srv.URL)
+	size, err = s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+	assert.NoError(t, s.Clone(false).Connect(context.Background()))
+	assert.Equal(t, []string{"HEAD", "GET", "GET"}, methods)
+}
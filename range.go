@@ -0,0 +1,178 @@
+package seekinghttp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RangeStrategy controls how a byte range is expressed in a request and how
+// the range a response holds is determined, so nonstandard byte-serving APIs
+// can be read.
+type RangeStrategy interface {
+	// SetRange modifies req to request length bytes starting at off.
+	SetRange(req *http.Request, off, length int64)
+	// ResponseRange returns the range held by the body of resp, a response
+	// to the request of the range starting at off with status 200, 206 or
+	// 416. For status 416, only the size of the object is used.
+	ResponseRange(resp *http.Response, off int64) (ResponseRange, error)
+}
+
+// ResponseRange describes the part of the object held by a response body.
+type ResponseRange struct {
+	// Partial is set if the body holds the range starting at Start,
+	// otherwise it holds the whole object.
+	Partial bool
+	Start   int64
+	// Length is the length of the range, or -1 if unknown. The Content-Length
+	// of the response is used if unknown.
+	Length int64
+	// Size is the size of the object, or -1 if unknown.
+	Size int64
+}
+
+// HeaderRange is the standard RangeStrategy, using the Range header in
+// requests and the status and Content-Range header of responses. The zero
+// value uses the standard header names and the bytes unit.
+type HeaderRange struct {
+	// Header is the name of the request header, "Range" if empty.
+	Header string
+	// ResponseHeader is the name of the response header echoing the range,
+	// "Content-Range" if empty.
+	ResponseHeader string
+	// Unit is the range unit, "bytes" if empty. The unit of the response
+	// must match.
+	Unit string
+}
+
+// _ is a type assertion
+var _ RangeStrategy = HeaderRange{}
+
+// SetRange sets the Range header of req.
+func (h HeaderRange) SetRange(req *http.Request, off, length int64) {
+	req.Header.Set(orDefault(h.Header, "Range"), fmtRange(h.unit(), off, length))
+}
+
+// ResponseRange parses the Content-Range header of resp. A 200 response holds
+// the whole object.
+func (h HeaderRange) ResponseRange(resp *http.Response, off int64) (ResponseRange, error) {
+	hdr := resp.Header.Get(orDefault(h.ResponseHeader, "Content-Range"))
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ResponseRange{Length: -1, Size: -1}, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the form is "bytes */size"
+		sizeStr, ok := strings.CutPrefix(hdr, h.unit()+" */")
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if !ok || err != nil || size < 0 {
+			size = -1
+		}
+		return ResponseRange{Size: size, Length: -1}, nil
+	}
+
+	if hdr == "" {
+		// nothing to check against
+		return ResponseRange{Partial: true, Start: off, Length: -1, Size: -1}, nil
+	}
+	start, end, size, err := parseContentRange(hdr, h.unit())
+	if err != nil {
+		return ResponseRange{}, err
+	}
+	return ResponseRange{Partial: true, Start: start, Length: end - start + 1, Size: size}, nil
+}
+
+func (h HeaderRange) unit() string {
+	return orDefault(h.Unit, "bytes")
+}
+
+// orDefault returns v, or def if v is empty.
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// QueryRange is a RangeStrategy for APIs taking the range in query
+// parameters, such as ?start=0&end=99. Every successful response holds the
+// requested range.
+type QueryRange struct {
+	// Start is the name of the parameter of the offset of the first byte.
+	Start string
+	// End is the name of the parameter of the offset of the last byte,
+	// which is included in the range.
+	End string
+}
+
+// SetRange sets the query parameters of req.
+func (q QueryRange) SetRange(req *http.Request, off, length int64) {
+	values := req.URL.Query()
+	values.Set(q.Start, strconv.FormatInt(off, 10))
+	values.Set(q.End, strconv.FormatInt(off+max(length, 1)-1, 10))
+	req.URL.RawQuery = values.Encode()
+}
+
+// ResponseRange returns the requested range.
+func (q QueryRange) ResponseRange(resp *http.Response, off int64) (ResponseRange, error) {
+	return ResponseRange{Partial: true, Start: off, Length: -1, Size: -1}, nil
+}
+
+// rangeStrategy returns the RangeStrategy to use.
+func (s *SeekingHTTP) rangeStrategy() RangeStrategy {
+	if s.RangeStrategy != nil {
+		return s.RangeStrategy
+	}
+	return HeaderRange{}
+}
+
+func fmtRange(unit string, from, l int64) string {
+	var to int64
+	if l == 0 {
+		to = from
+	} else {
+		to = from + (l - 1)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(unit) + 42)
+	_, _ = sb.WriteString(unit)
+	_, _ = sb.WriteString("=")
+	_, _ = sb.WriteString(strconv.FormatInt(from, 10))
+	_, _ = sb.WriteString("-")
+	_, _ = sb.WriteString(strconv.FormatInt(to, 10))
+	return sb.String()
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "unit start-end/size". size is -1 if the complete length is unknown ("*").
+func parseContentRange(hdr, unit string) (start, end, size int64, err error) {
+	rng, ok := strings.CutPrefix(hdr, unit+" ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid content range %q: unknown unit", hdr)
+	}
+	rng, sizeStr, ok := strings.Cut(rng, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid content range %q: missing size", hdr)
+	}
+	startStr, endStr, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid content range %q: missing end", hdr)
+	}
+
+	start, err = strconv.ParseInt(startStr, 10, 64)
+	if err == nil {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+	}
+	if err == nil {
+		if sizeStr == "*" {
+			size = -1
+		} else {
+			size, err = strconv.ParseInt(sizeStr, 10, 64)
+		}
+	}
+	if err != nil || start < 0 || end < start || (size >= 0 && end >= size) {
+		return 0, 0, 0, fmt.Errorf("invalid content range %q", hdr)
+	}
+	return start, end, size, nil
+}
@@ -0,0 +1,120 @@
+package seekinghttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRange(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Range"))
+		start, err1 := strconv.Atoi(r.URL.Query().Get("from"))
+		end, err2 := strconv.Atoi(r.URL.Query().Get("to"))
+		if err1 != nil || err2 != nil || r.URL.Query().Get("key") != "k" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		start, end = min(start, len(body)), min(end+1, len(body))
+		_, _ = w.Write([]byte(body[start:end]))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "?key=k")
+	s.MinFetch = 0
+	s.RangeStrategy = QueryRange{Start: "from", End: "to"}
+
+	// the 200 responses hold the requested range, not the whole object
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(buf[:n]))
+	assert.Nil(t, s.KnownSize)
+	n, err = s.ReadAt(buf, 97)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "789", string(buf[:n]))
+}
+
+// headerRange is a RangeStrategy using custom headers.
+type headerRange struct{}
+
+func (headerRange) SetRange(req *http.Request, off, length int64) {
+	req.Header.Set("X-Offset", strconv.FormatInt(off, 10))
+	req.Header.Set("X-Length", strconv.FormatInt(length, 10))
+}
+
+func (headerRange) ResponseRange(resp *http.Response, off int64) (ResponseRange, error) {
+	start, err := strconv.ParseInt(resp.Header.Get("X-Offset"), 10, 64)
+	if err != nil {
+		return ResponseRange{}, err
+	}
+	size, err := strconv.ParseInt(resp.Header.Get("X-Size"), 10, 64)
+	if err != nil {
+		return ResponseRange{}, err
+	}
+	return ResponseRange{Partial: true, Start: start, Length: -1, Size: size}, nil
+}
+
+func TestCustomRangeStrategy(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		off, _ := strconv.Atoi(r.Header.Get("X-Offset"))
+		length, _ := strconv.Atoi(r.Header.Get("X-Length"))
+		w.Header().Set("X-Offset", strconv.Itoa(off))
+		w.Header().Set("X-Size", strconv.Itoa(len(body)))
+		_, _ = w.Write([]byte(body[off:min(off+length, len(body))]))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 0
+	s.RangeStrategy = headerRange{}
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 31)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", string(buf[:n]))
+	if assert.NotNil(t, s.KnownSize) {
+		assert.Equal(t, int64(len(body)), *s.KnownSize)
+	}
+}
+
+func TestHeaderRangeNames(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	unit := "items"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng, ok := strings.CutPrefix(r.Header.Get("X-Range"), "items=")
+		startStr, endStr, _ := strings.Cut(rng, "-")
+		start, _ := strconv.Atoi(startStr)
+		end, _ := strconv.Atoi(endStr)
+		if !ok || r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Content-Range", unit+" "+startStr+"-"+endStr+"/"+strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(body[start : end+1]))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 0
+	s.RangeStrategy = HeaderRange{Header: "X-Range", ResponseHeader: "X-Content-Range", Unit: "items"}
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 21)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", string(buf[:n]))
+	if assert.NotNil(t, s.KnownSize) {
+		assert.Equal(t, int64(len(body)), *s.KnownSize)
+	}
+
+	// the unit of the echo is validated
+	unit = "bytes"
+	_, err = s.ReadAt(buf, 50)
+	assert.ErrorContains(t, err, "unknown unit")
+}
@@ -0,0 +1,160 @@
+package seekinghttp
+
+import "context"
+
+// PrefetchPool runs background loads, such as those of DoubleBuffer, with
+// at most a fixed number running at once. The others wait for a free worker
+// unless they are canceled first. A PrefetchPool is safe for concurrent use.
+type PrefetchPool struct {
+	workers *limiter
+}
+
+// NewPrefetchPool creates a pool running at most n loads at once, zero
+// means unlimited.
+func NewPrefetchPool(n int) *PrefetchPool {
+	return &PrefetchPool{workers: newLimiter(n)}
+}
+
+// run runs load in the background once a worker is free. If ctx is done
+// first, it calls load with the error of ctx instead.
+func (p *PrefetchPool) run(ctx context.Context, load func(err error)) {
+	go func() {
+		var workers *limiter
+		if p != nil {
+			workers = p.workers
+		}
+		release, err := workers.acquire(ctx, true)
+		if err != nil {
+			load(err)
+			return
+		}
+		defer release()
+		load(nil)
+	}()
+}
+
+// readahead is a background load of the range following a sequential load,
+// kept out of the cache until a read reaches it.
+type readahead struct {
+	off, length int64
+	// c is the clone loading the range into sp, which may be read once done
+	// is closed.
+	c      *SeekingHTTP
+	sp     *span
+	err    error
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// backgroundClone returns a clone of s loading in the background, whose
+// requests wait behind the demand requests for a slot of the limiter. They
+// are canceled by cancel or by closing s.
+func (s *SeekingHTTP) backgroundClone() (*SeekingHTTP, context.CancelFunc) {
+	c := s.Clone(false)
+	c.OnEgress = nil
+	c.background = true
+	c.DoubleBuffer = false
+	c.initOnce.Do(func() {
+		c.ctx, c.cancel = context.WithCancel(s.context())
+	})
+	return c, c.cancel
+}
+
+// adopt counts the requests of the background clone c in the stats of s and
+// learns what it learned about the object.
+func (s *SeekingHTTP) adopt(c *SeekingHTTP) {
+	s.stats.requests.Add(c.stats.requests.Load())
+	s.stats.retries.Add(c.stats.retries.Load())
+	s.stats.anomalies.Add(c.stats.anomalies.Load())
+	s.countEgress(c.stats.downloaded.Load())
+	s.learn(c)
+}
+
+// startAhead starts loading length bytes at off in the background.
+func (s *SeekingHTTP) startAhead(off, length int64) {
+	if s.KnownSize != nil {
+		length = min(length, *s.KnownSize-off)
+	}
+	if length <= 0 {
+		return
+	}
+	s.tracef(TracePlan, "readahead (%v-%v)", off, off+length)
+	c, cancel := s.backgroundClone()
+	ra := &readahead{off: off, length: length, c: c, done: make(chan struct{}), cancel: cancel}
+	s.ahead = ra
+	s.Prefetch.run(c.context(), func(err error) {
+		defer close(ra.done)
+		if err != nil {
+			ra.err = err
+			return
+		}
+		// the loaded range is handed to the cache by takeAhead
+		ra.sp, ra.err = c.load(c.context(), NewCache(0), off, length, length)
+	})
+}
+
+// takeAhead waits for the readahead if the read of want bytes at off needs
+// it and moves its range into cache, joined with the cached bytes the read
+// needs before it. It returns the readahead if it loaded the range.
+func (s *SeekingHTTP) takeAhead(ctx context.Context, cache *Cache, off, want int64) *readahead {
+	ra := s.ahead
+	if ra == nil || off >= ra.off+ra.length || off+want <= ra.off {
+		return nil
+	}
+	var head []byte
+	if off < ra.off {
+		// the read continues from the cached range into the readahead
+		head = make([]byte, ra.off-off)
+		if !cache.readAt(s.cacheKey(), head, off, ra.off-off) {
+			return nil
+		}
+	}
+	s.ahead = nil
+	select {
+	case <-ra.done:
+	case <-ctx.Done():
+		ra.cancel()
+		return nil
+	}
+	ra.cancel()
+	s.adopt(ra.c)
+	if ra.sp == nil || ra.sp.data.Len() == 0 {
+		return nil
+	}
+	if head != nil && ra.sp.off == ra.off {
+		sp := &span{off: off}
+		sp.data.Write(head)
+		sp.data.Write(ra.sp.data.Bytes())
+		ra.sp = sp
+	}
+	if !ra.c.noStore {
+		cache.put(s.cacheKey(), s.CacheTag, ra.sp.off, ra.sp.data.Bytes(), ra.c.validity())
+	}
+	return ra
+}
+
+// dropFarAhead cancels the readahead if off is outside of the window from
+// the last load to the end of the readahead, so a seek away does not keep
+// loading ranges that will not be read. The loaded bytes are counted.
+func (s *SeekingHTTP) dropFarAhead(off int64) {
+	ra := s.ahead
+	if ra == nil || (off >= s.tuner.off && off < ra.off+ra.length) {
+		return
+	}
+	if s.Logger != nil {
+		s.Logger.Debugf("canceling readahead of range (%v-%v) after seek to %v", ra.off, ra.off+ra.length, off)
+	}
+	s.dropAhead()
+}
+
+// dropAhead cancels the readahead, if any. The loaded bytes are counted.
+func (s *SeekingHTTP) dropAhead() {
+	ra := s.ahead
+	if ra == nil {
+		return
+	}
+	s.ahead = nil
+	ra.cancel()
+	<-ra.done
+	s.adopt(ra.c)
+}
@@ -0,0 +1,167 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoubleBuffer(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	var mu sync.Mutex
+	var ranges []string
+	requested := make(chan string, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		requested <- r.Header.Get("Range")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 4096
+	s.DoubleBuffer = true
+	buf := make([]byte, 1024)
+	read := func(off int64) {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+		assert.Equal(t, data[off:off+1024], buf)
+	}
+
+	// a random read does not load ahead
+	read(0)
+	assert.Equal(t, "bytes=0-4095", <-requested)
+	// a sequential one does
+	read(4096 - 512)
+	assert.Equal(t, "bytes=3584-7679", <-requested)
+	assert.Equal(t, "bytes=7680-11775", <-requested)
+	// while reading the range loaded ahead, the next one is loaded
+	read(8000)
+	assert.Equal(t, "bytes=11776-15871", <-requested)
+	read(12000)
+	assert.Equal(t, "bytes=15872-16383", <-requested)
+	// a read continuing into the range loaded ahead is served from both
+	read(15000)
+
+	assert.NoError(t, s.Close())
+	mu.Lock()
+	assert.Len(t, ranges, 5)
+	mu.Unlock()
+	st := s.Stats()
+	assert.EqualValues(t, 5, st.Requests)
+	assert.EqualValues(t, 4*4096+512, st.BytesDownloaded)
+}
+
+func TestPrefetchPool(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	requested := make(chan string, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.Header.Get("Range")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	f := NewFactory()
+	f.MinFetch = 4096
+	f.DoubleBuffer = true
+	f.Prefetch = NewPrefetchPool(1)
+
+	// occupy the only worker
+	release := make(chan struct{})
+	running := make(chan struct{})
+	f.Prefetch.run(context.Background(), func(err error) {
+		assert.NoError(t, err)
+		close(running)
+		<-release
+	})
+	<-running
+
+	s := f.Open(srv.URL)
+	_, err := s.ReadAt(make([]byte, 10), 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 10), 4090)
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes=0-4095", <-requested)
+	assert.Equal(t, "bytes=4090-8185", <-requested)
+	select {
+	case rng := <-requested:
+		t.Fatalf("loaded %s ahead without a free worker", rng)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, "bytes=8186-12281", <-requested)
+
+	// loads waiting for a worker give up when canceled
+	release = make(chan struct{})
+	running = make(chan struct{})
+	f.Prefetch.run(context.Background(), func(error) {
+		close(running)
+		<-release
+	})
+	<-running
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	f.Prefetch.run(ctx, func(err error) { canceled <- err })
+	cancel()
+	assert.ErrorIs(t, <-canceled, context.Canceled)
+	close(release)
+	assert.NoError(t, s.Close())
+}
+
+func TestCancelReadaheadOnSeek(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	requested := make(chan string, 16)
+	aborted := make(chan string, 16)
+	stall := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		requested <- rng
+		if rng == "bytes=8192-12287" {
+			// the readahead stalls until it is aborted
+			select {
+			case <-r.Context().Done():
+				aborted <- rng
+			case <-stall:
+			}
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+	defer close(stall)
+
+	s := New(srv.URL)
+	s.MinFetch = 4096
+	s.DoubleBuffer = true
+	_, err := s.ReadAt(make([]byte, 10), 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 10), 4096)
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes=0-4095", <-requested)
+	assert.Equal(t, "bytes=4096-8191", <-requested)
+	assert.Equal(t, "bytes=8192-12287", <-requested)
+
+	// reads within the window keep it
+	_, err = s.Seek(6000, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = s.Read(make([]byte, 10))
+	assert.NoError(t, err)
+	assert.NotNil(t, s.ahead)
+
+	// a seek away cancels it
+	_, err = s.Seek(1<<19, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Nil(t, s.ahead)
+	assert.Equal(t, "bytes=8192-12287", <-aborted)
+	assert.EqualValues(t, 2, s.Stats().BytesDownloaded/4096)
+}
@@ -0,0 +1,124 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+)
+
+// readerAt is a stateless io.ReaderAt that is safe for concurrent use.
+type readerAt struct {
+	// mu guards tmpl
+	mu   sync.Mutex
+	tmpl *SeekingHTTP
+}
+
+// _ is a type assertion
+var _ io.ReaderAt = (*readerAt)(nil)
+
+// ReaderAt returns an io.ReaderAt for the object of s that has no offset and
+// is safe for concurrent use, for example by zip.NewReader from several
+// goroutines. Each ReadAt reads with its own clone of s, sharing the
+// configuration, the learned size and ETag, and the cache.
+//
+// Concurrent reads benefit from a cache holding more than one range, so set
+// s.Cache to a Cache of a suitable size before calling ReaderAt.
+func (s *SeekingHTTP) ReaderAt() io.ReaderAt {
+	return &readerAt{tmpl: s.Clone(true)}
+}
+
+// ReadAt reads len(buf) bytes into buf starting at offset off.
+func (r *readerAt) ReadAt(buf []byte, off int64) (int, error) {
+	r.mu.Lock()
+	if r.tmpl.readCached(buf, off) {
+		r.mu.Unlock()
+		return len(buf), nil
+	}
+	c := r.tmpl.Clone(true)
+	r.mu.Unlock()
+
+	n, err := c.ReadAtContext(context.Background(), buf, off)
+
+	// remember what the clone learned for the following reads
+	r.mu.Lock()
+	r.tmpl.learn(c)
+	r.mu.Unlock()
+
+	return n, err
+}
+
+// readCached reads len(buf) bytes at off from the cache, reporting whether
+// they were all cached, so cache hits of a ReaderAt need no clone.
+func (s *SeekingHTTP) readCached(buf []byte, off int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	length := int64(len(buf))
+	if s.closed || length == 0 || off < 0 || off > math.MaxInt64-length || s.BlockSize > 0 {
+		return false
+	}
+	if s.KnownSize != nil && off+length > *s.KnownSize {
+		return false
+	}
+	if !s.cache().peek(s.cacheKey(), buf, off, length) {
+		return false
+	}
+	s.hit(off, length, length)
+	return true
+}
+
+// Section returns a reader of the length bytes at off of the object, such as
+// a file embedded in an archive to hand to a parser. It reads with ReaderAt,
+// sharing the configuration and cache of s, and is independent of the offset
+// of s. Like the ReaderAt, its ReadAt is safe for concurrent use.
+func (s *SeekingHTTP) Section(off, length int64) *io.SectionReader {
+	return io.NewSectionReader(s.ReaderAt(), off, length)
+}
+
+// SizeReaderAt is an io.ReaderAt of an object with a Size method that returns
+// no error, the shape wanted by APIs such as zip.NewReader. It is safe for
+// concurrent use.
+type SizeReaderAt struct {
+	r *readerAt
+
+	once sync.Once
+	size int64
+	err  error
+}
+
+// NewSizeReaderAt returns a SizeReaderAt of the object of s, reading like
+// s.ReaderAt.
+func NewSizeReaderAt(s *SeekingHTTP) *SizeReaderAt {
+	return &SizeReaderAt{r: &readerAt{tmpl: s.Clone(true)}}
+}
+
+// ReadAt reads len(buf) bytes into buf starting at offset off.
+func (r *SizeReaderAt) ReadAt(buf []byte, off int64) (int, error) {
+	return r.r.ReadAt(buf, off)
+}
+
+// Size returns the size of the object, learning it on the first call if it
+// is not known yet. If that fails, it returns -1 and Err returns the error.
+func (r *SizeReaderAt) Size() int64 {
+	r.once.Do(func() {
+		r.r.mu.Lock()
+		c := r.r.tmpl.Clone(true)
+		r.r.mu.Unlock()
+
+		r.size, r.err = c.SizeContext(context.Background())
+		if r.err != nil {
+			r.size = -1
+			return
+		}
+		r.r.mu.Lock()
+		r.r.tmpl.learn(c)
+		r.r.mu.Unlock()
+	})
+	return r.size
+}
+
+// Err returns the error learning the size, if Size failed.
+func (r *SizeReaderAt) Err() error {
+	r.Size()
+	return r.err
+}
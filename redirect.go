@@ -0,0 +1,175 @@
+package seekinghttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxRedirects is the most redirects followed by a request, as by
+// http.Client.
+const maxRedirects = 10
+
+// do issues req with the Client. Redirects are followed keeping the Range
+// and the other headers of req, except the credentials and Header on
+// redirects to other origins, see RedirectHeaders. The final URL is remembered so later requests skip the
+// redirects, see ResolveTTL.
+//
+// A *http.Client with a CheckRedirect function follows the redirects
+// itself.
+func (s *SeekingHTTP) do(req *http.Request) (*http.Response, error) {
+	c := s.Client
+	if hc, ok := c.(*http.Client); ok && hc.CheckRedirect == nil {
+		nc := *hc
+		nc.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		c = &nc
+	}
+
+	if s.resolved != nil && s.ResolveTTL > 0 && time.Since(s.resolvedAt) >= s.ResolveTTL {
+		s.resolved = nil
+	}
+	if s.resolved == nil {
+		return s.follow(c, req)
+	}
+
+	// skip the redirects followed before
+	next, err := s.redirectReq(req, http.StatusTemporaryRedirect, s.resolved)
+	if err != nil || next == nil {
+		return s.follow(c, req)
+	}
+	resp, err := s.follow(c, next)
+	if err != nil || resp.StatusCode/100 != 4 || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return resp, err
+	}
+	// the resolved URL may have expired, resolve URL again
+	if s.Logger != nil {
+		s.Logger.Debugf("resolved URL %s failed: %s", s.resolved, resp.Status)
+	}
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrain)
+	_ = resp.Body.Close()
+	s.resolved = nil
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return s.follow(c, req)
+}
+
+// follow signs and issues req with c, following the redirects.
+func (s *SeekingHTTP) follow(c HttpClient, req *http.Request) (*http.Response, error) {
+	for redirects := 0; ; redirects++ {
+		if s.SignRequest != nil {
+			if err := s.SignRequest(req.Context(), req); err != nil {
+				return nil, fmt.Errorf("sign request: %w", err)
+			}
+		}
+		resp, err := s.roundTrip(c, req)
+		if err != nil {
+			return nil, err
+		}
+		loc := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || loc == "" {
+			if resp.StatusCode/100 == 2 && resp.Request != nil && resp.Request.URL != nil &&
+				resp.Request.URL.String() != req.URL.String() {
+				// redirected by the client
+				req = resp.Request
+				redirects++
+			}
+			if redirects > 0 && resp.StatusCode/100 == 2 {
+				if s.Logger != nil {
+					s.Logger.Debugf("resolved %s to %s", s.URL, req.URL)
+				}
+				u := *req.URL
+				s.resolved, s.resolvedAt = &u, time.Now()
+			}
+			return resp, nil
+		}
+		if redirects == maxRedirects {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		target, err := req.URL.Parse(loc)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("redirect location: %w", err)
+		}
+		next, err := s.redirectReq(req, resp.StatusCode, target)
+		if err != nil || next == nil {
+			// the body cannot be sent again, return the redirect
+			return resp, err
+		}
+		_, _ = io.CopyN(io.Discard, resp.Body, maxDrain)
+		_ = resp.Body.Close()
+		if s.Logger != nil {
+			s.Logger.Debugf("redirected to %s", target)
+		}
+		req = next
+	}
+}
+
+// isRedirect returns whether status is a redirect to the Location.
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// sensitiveHeaders are the headers not sent to other origins.
+var sensitiveHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2", "Proxy-Authorization"}
+
+// redirectReq returns the request following the redirect of req with status
+// to target, or nil if the body of req cannot be sent again. The method
+// changes to GET as by http.Client.
+func (s *SeekingHTTP) redirectReq(req *http.Request, status int, target *url.URL) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	next.URL, next.Host = target, ""
+	if (status == http.StatusSeeOther && req.Method != "HEAD") ||
+		((status == http.StatusMovedPermanently || status == http.StatusFound) && req.Method == "POST") {
+		next.Method = "GET"
+		next.Body, next.GetBody, next.ContentLength = nil, nil, 0
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	} else if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	if !sameOrigin(req.URL, target) {
+		for _, h := range sensitiveHeaders {
+			s.dropHeader(next.Header, h)
+		}
+		for h := range s.Header {
+			s.dropHeader(next.Header, h)
+		}
+	}
+	return next, nil
+}
+
+// dropHeader deletes the header h from hdr unless it is in RedirectHeaders.
+func (s *SeekingHTTP) dropHeader(hdr http.Header, h string) {
+	for _, keep := range s.RedirectHeaders {
+		if strings.EqualFold(keep, h) {
+			return
+		}
+	}
+	hdr.Del(h)
+}
+
+// sameOrigin returns whether a and b have the same scheme, host and port.
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
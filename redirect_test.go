@@ -0,0 +1,167 @@
+package seekinghttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirect(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var redirected atomic.Int32
+	var ranges, custom, auth []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		custom = append(custom, r.Header.Get("X-Custom"))
+		auth = append(auth, r.Header.Get("Authorization"))
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer target.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		redirected.Add(1)
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		redirected.Add(1)
+		http.Redirect(w, r, target.URL+"/obj", http.StatusTemporaryRedirect)
+	})
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+
+	s := New(origin.URL + "/old")
+	s.MinFetch = 10
+	s.Header = http.Header{"X-Custom": {"v"}}
+	s.SetBasicAuth("user", "pass")
+
+	buf := make([]byte, 10)
+	_, err := s.ReadAt(buf, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, body[10:20], string(buf))
+	_, err = s.ReadAt(buf, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, body[50:60], string(buf))
+
+	// the second read went straight to the final URL
+	assert.EqualValues(t, 2, redirected.Load())
+	assert.Equal(t, []string{"bytes=10-19", "bytes=50-59"}, ranges)
+	// the credentials and custom headers do not cross origins
+	assert.Equal(t, []string{"", ""}, custom)
+	assert.Equal(t, []string{"", ""}, auth)
+
+	// clones skip the redirects too
+	c := s.Clone(false)
+	_, err = c.ReadAt(buf, 80)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, redirected.Load())
+
+	// unless allowed
+	s = New(origin.URL + "/old")
+	s.Header = http.Header{"X-Custom": {"v"}, "X-Other": {"o"}}
+	s.MinFetch = 10
+	s.RedirectHeaders = []string{"x-custom"}
+	custom = nil
+	_, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(buf, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v", "v"}, custom)
+}
+
+func TestRedirectLoop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "/loop")
+	_, err := s.ReadAt(make([]byte, 10), 0)
+	assert.ErrorContains(t, err, "redirects")
+}
+
+func TestResolveAgain(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var redirected atomic.Int32
+	var expired atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/obj", func(w http.ResponseWriter, r *http.Request) {
+		n := redirected.Add(1)
+		http.Redirect(w, r, fmt.Sprintf("/edge/%d", n), http.StatusFound)
+	})
+	mux.HandleFunc("/edge/", func(w http.ResponseWriter, r *http.Request) {
+		if expired.Load() && r.URL.Path != fmt.Sprintf("/edge/%d", redirected.Load()) {
+			http.Error(w, "expired", http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	s := New(srv.URL + "/obj")
+	s.MinFetch = 10
+	s.ResolveTTL = time.Hour
+	buf := make([]byte, 10)
+	read := func(off int64) {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+		assert.Equal(t, body[off:off+10], string(buf))
+	}
+
+	read(0)
+	read(20)
+	assert.EqualValues(t, 1, redirected.Load())
+
+	// resolved again after ResolveTTL
+	s.resolvedAt = s.resolvedAt.Add(-2 * time.Hour)
+	read(40)
+	assert.EqualValues(t, 2, redirected.Load())
+
+	// and when the resolved URL is refused
+	expired.Store(true)
+	redirected.Add(1)
+	read(60)
+	assert.EqualValues(t, 4, redirected.Load())
+}
+
+func TestSignRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/obj", http.StatusFound)
+			return
+		}
+		if r.Header.Get("X-Signature") != r.Method+" "+r.URL.Path+" "+r.Header.Get("Range") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "/old")
+	s.MinFetch = 2
+	var signed int
+	s.SignRequest = func(_ context.Context, req *http.Request) error {
+		signed++
+		req.Header.Set("X-Signature", req.Method+" "+req.URL.Path+" "+req.Header.Get("Range"))
+		return nil
+	}
+	_, err := s.ReadAt(make([]byte, 2), 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 2), 4)
+	assert.NoError(t, err)
+	// the redirect and both range requests
+	assert.Equal(t, 3, signed)
+
+	s.SignRequest = func(context.Context, *http.Request) error { return io.ErrClosedPipe }
+	_, err = s.ReadAt(make([]byte, 2), 8)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
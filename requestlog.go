@@ -0,0 +1,65 @@
+package seekinghttp
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// requestLogEntry is a line of the RequestLog.
+type requestLogEntry struct {
+	Time   time.Time `json:"time"`
+	URL    string    `json:"url"`
+	Offset int64     `json:"offset"`
+	Length int64     `json:"length"`
+	// Status is the response status, zero for cache hits and failed
+	// requests.
+	Status int   `json:"status,omitempty"`
+	Bytes  int64 `json:"bytes"`
+	// Duration is in the log as duration_ms.
+	Duration time.Duration `json:"-"`
+	// Cache is "hit" for reads served from the cache, "miss" otherwise.
+	Cache string `json:"cache"`
+	// Attempt counts the attempts of a retried request from 1.
+	Attempt int    `json:"attempt,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	err error
+}
+
+// logRequest writes e as a line of JSON to the RequestLog.
+func (s *SeekingHTTP) logRequest(e requestLogEntry) {
+	e.Time = time.Now()
+	e.URL = redactURL(s.URL)
+	if e.err != nil {
+		e.Error = strings.ReplaceAll(e.err.Error(), s.URL, e.URL)
+	}
+	line, err := json.Marshal(struct {
+		requestLogEntry
+		DurationMS float64 `json:"duration_ms"`
+	}{e, float64(e.Duration) / float64(time.Millisecond)})
+	if err != nil {
+		return
+	}
+	_, _ = s.RequestLog.Write(append(line, '\n'))
+}
+
+// redactURL returns rawURL with the values of its query and its password
+// replaced, as presigned URLs carry credentials in the query, such as the
+// X-Amz-Signature of S3 or the sig of Azure.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		base, _, _ := strings.Cut(rawURL, "?")
+		return base
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			q[k] = []string{"xxxxx"}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,70 @@
+package seekinghttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLog(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var truncate atomic.Bool
+	truncate.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if truncate.CompareAndSwap(true, false) {
+			w.Header().Set("Content-Range", "bytes 0-19/100")
+			w.Header().Set("Content-Length", "20")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(body[:10]))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	s := New(srv.URL + "?X-Amz-Signature=secret")
+	s.MinFetch = 20
+	s.Cache = NewCache(1024)
+	s.RequestLog = &out
+	_, err := s.ReadAt(make([]byte, 20), 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 5), 10)
+	assert.NoError(t, err)
+
+	log := out.String()
+	var lines []map[string]any
+	sc := bufio.NewScanner(&out)
+	for sc.Scan() {
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(sc.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	if !assert.Len(t, lines, 3) {
+		return
+	}
+	// the truncated request is resumed by a second attempt
+	// without the credentials of a presigned URL
+	assert.Equal(t, srv.URL+"?X-Amz-Signature=xxxxx", lines[0]["url"])
+	assert.NotContains(t, log, "secret")
+	assert.Equal(t, float64(206), lines[0]["status"])
+	assert.Equal(t, float64(10), lines[0]["bytes"])
+	assert.Equal(t, float64(1), lines[0]["attempt"])
+	assert.Contains(t, lines[0]["error"], "unexpected EOF")
+	assert.Equal(t, "miss", lines[0]["cache"])
+	assert.Equal(t, float64(10), lines[1]["offset"])
+	assert.Equal(t, float64(2), lines[1]["attempt"])
+	assert.Nil(t, lines[1]["error"])
+	assert.Contains(t, lines[1], "duration_ms")
+	assert.Equal(t, "hit", lines[2]["cache"])
+	assert.Equal(t, float64(5), lines[2]["bytes"])
+	assert.Nil(t, lines[2]["status"])
+}
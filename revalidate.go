@@ -0,0 +1,213 @@
+package seekinghttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RevalidateStats counts the cached ranges checked by Revalidate.
+type RevalidateStats struct {
+	// Fresh ranges are unchanged. Refreshed ranges were loaded again from a
+	// changed object and Dropped ranges were evicted because the changed
+	// object no longer holds them or the server ignored the range.
+	Fresh, Refreshed, Dropped int
+	// Skipped ranges were loaded without an ETag and cannot be revalidated.
+	Skipped int
+}
+
+// Revalidate checks every cached range of the object with a conditional
+// range request carrying the ETag it was loaded with in If-None-Match, such
+// as for a shared Cache in front of a mutable object. Unchanged ranges cost
+// a 304 Not Modified response without a body. Ranges of an object that
+// changed are replaced with the bytes of the new version, whose ETag and
+// size the reader adopts, so reads continue without ErrChanged.
+func (s *SeekingHTTP) Revalidate(ctx context.Context) (RevalidateStats, error) {
+	var st RevalidateStats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return st, os.ErrClosed
+	}
+	if s.Fetcher != nil {
+		return st, fmt.Errorf("revalidate with a fetcher: %w", os.ErrInvalid)
+	}
+
+	cache := s.cache()
+	for _, g := range cache.tagged(s.cacheKey()) {
+		if g.etag == "" {
+			st.Skipped++
+			continue
+		}
+		if err := s.revalidate(ctx, cache, &g, &st); err != nil {
+			return st, s.ctxErr(ctx, err)
+		}
+	}
+	if s.Logger != nil {
+		s.Logger.Debugf("revalidated the cache: %+v", st)
+	}
+	return st, nil
+}
+
+// revalidate checks the cached segment g.
+func (s *SeekingHTTP) revalidate(ctx context.Context, cache *Cache, g *segment, st *RevalidateStats) error {
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	req, err := s.newReq(ctx)
+	if err != nil {
+		return err
+	}
+	length := int64(len(g.data))
+	s.rangeStrategy().SetRange(req, g.off, length)
+	req.Header.Set("If-None-Match", g.etag)
+
+	release, err := s.limiter.acquire(ctx, s.background)
+	if err != nil {
+		return err
+	}
+	defer release()
+	s.stats.requests.Add(1)
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		st.Fresh++
+		if s.HonorCacheControl {
+			// served for another lifetime
+			s.learnLifetime(resp)
+			if !s.noStore {
+				cache.put(s.cacheKey(), s.CacheTag, g.off, g.data, validity{etag: g.etag, expires: s.expires})
+			}
+		}
+		return nil
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+	default:
+		return s.statusErr(resp)
+	}
+	rr, err := s.rangeStrategy().ResponseRange(resp, g.off)
+	if err != nil {
+		return err
+	}
+	if s.Logger != nil {
+		s.Logger.Debugf("range (%v-%v) of etag %s changed to %s", g.off, g.off+length, g.etag, resp.Header.Get("ETag"))
+	}
+	if rr.Size >= 0 {
+		size := rr.Size
+		s.KnownSize = &size
+	}
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	s.learnLifetime(resp)
+	if resp.StatusCode != http.StatusPartialContent || rr.Start != g.off {
+		cache.drop(s.cacheKey(), g.off, length)
+		st.Dropped++
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, length))
+	s.countEgress(int64(len(data)))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) < length {
+		// the object shrank, or the body was cut short
+		cache.drop(s.cacheKey(), g.off, length)
+	}
+	if !s.noStore {
+		cache.put(s.cacheKey(), s.CacheTag, g.off, data, s.validity())
+	}
+	g.data = data
+	st.Refreshed++
+	return nil
+}
+
+// RevalidateEvery runs Revalidate every interval in the background until
+// stop is called or the reader is closed, keeping a long-lived cache current.
+// Failures are logged to the Logger.
+func (s *SeekingHTTP) RevalidateEvery(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(s.context())
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if _, err := s.Revalidate(ctx); err != nil && ctx.Err() == nil && s.Logger != nil {
+				s.Logger.Infof("revalidation failed: %v", err)
+			}
+		}
+	}()
+	return cancel
+}
+
+// learnLifetime learns how long the bytes of resp may be cached, see
+// HonorCacheControl. The Age of the response counts against max-age, and an
+// invalid Expires header means already expired.
+func (s *SeekingHTTP) learnLifetime(resp *http.Response) {
+	s.noStore, s.expires = false, time.Time{}
+	if !s.HonorCacheControl {
+		return
+	}
+	cc := resp.Header.Get("Cache-Control")
+	now := time.Now()
+	switch {
+	case hasDirective(cc, "no-store"):
+		s.noStore = true
+		return
+	case hasDirective(cc, "no-cache"):
+		s.expires = now
+		return
+	}
+	if v, ok := directiveValue(cc, "max-age"); ok {
+		if maxAge, err := strconv.ParseInt(v, 10, 64); err == nil && maxAge >= 0 {
+			age, _ := strconv.ParseInt(resp.Header.Get("Age"), 10, 64)
+			s.expires = now.Add(time.Duration(maxAge-max(age, 0)) * time.Second)
+			return
+		}
+	}
+	if e := resp.Header.Get("Expires"); e != "" {
+		s.expires = now
+		if t, err := http.ParseTime(e); err == nil {
+			s.expires = t
+		}
+	}
+}
+
+// validity returns the validity of the bytes of the last response.
+func (s *SeekingHTTP) validity() validity {
+	return validity{etag: s.etag, expires: s.expires}
+}
+
+// revalidateExpired revalidates an expired segment holding the range of
+// length bytes at off and copies the range to buf, reporting whether it did.
+// The range is served even if its new lifetime already ended, as for
+// no-cache.
+func (s *SeekingHTTP) revalidateExpired(ctx context.Context, cache *Cache, buf []byte, off, length int64) bool {
+	g, ok := cache.expired(s.cacheKey(), off, length)
+	if !ok || g.etag == "" {
+		return false
+	}
+	var st RevalidateStats
+	if err := s.revalidate(ctx, cache, &g, &st); err != nil {
+		if s.Logger != nil {
+			s.Logger.Debugf("revalidation of range (%v-%v) failed: %v", g.off, g.end(), err)
+		}
+		return false
+	}
+	if st.Fresh+st.Refreshed == 0 || off+length > g.end() {
+		return false
+	}
+	copy(buf, g.data[off-g.off:off-g.off+length])
+	return true
+}
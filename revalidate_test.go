@@ -0,0 +1,179 @@
+package seekinghttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevalidate(t *testing.T) {
+	var mu sync.Mutex
+	body, etag := strings.Repeat("a", 8192), `"v1"`
+	var statuses []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		b, e := body, etag
+		mu.Unlock()
+		w.Header().Set("ETag", e)
+		rec := &statusRecorder{ResponseWriter: w, record: func(status int) {
+			mu.Lock()
+			statuses = append(statuses, status)
+			mu.Unlock()
+		}}
+		http.ServeContent(rec, r, "", time.Time{}, strings.NewReader(b))
+	}))
+	defer srv.Close()
+	recorded := func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return statuses
+	}
+	reset := func() {
+		mu.Lock()
+		statuses = nil
+		mu.Unlock()
+	}
+
+	s := New(srv.URL)
+	s.MinFetch = 1024
+	s.Cache = NewCache(1 << 20)
+	buf := make([]byte, 4)
+	for _, off := range []int64{0, 4096} {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+	}
+
+	// unchanged ranges are confirmed without bodies
+	reset()
+	st, err := s.Revalidate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, RevalidateStats{Fresh: 2}, st)
+	assert.Equal(t, []int{http.StatusNotModified, http.StatusNotModified}, recorded())
+
+	// changed ranges are loaded again and the reader follows the new version
+	mu.Lock()
+	body, etag = strings.Repeat("b", 4200), `"v2"`
+	mu.Unlock()
+	st, err = s.Revalidate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, RevalidateStats{Refreshed: 2}, st)
+	assert.Equal(t, `"v2"`, s.ETag())
+	assert.Equal(t, int64(4200), *s.KnownSize)
+	reset()
+	_, err = s.ReadAt(buf, 4096)
+	assert.NoError(t, err)
+	assert.Equal(t, "bbbb", string(buf))
+	_, err = s.ReadAt(buf, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "bbbb", string(buf))
+	assert.Empty(t, recorded())
+
+	// in the background, while the reader is used
+	mu.Lock()
+	body, etag = strings.Repeat("c", 4200), `"v3"`
+	mu.Unlock()
+	stop := s.RevalidateEvery(time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, _ = s.Size()
+		_, _ = s.Discard(1)
+		_ = s.Capabilities()
+		return s.ETag() == `"v3"`
+	}, time.Second, time.Millisecond)
+	stop()
+}
+
+// statusRecorder calls record with the status of a response before it is
+// sent, so the client cannot see the response first.
+type statusRecorder struct {
+	http.ResponseWriter
+	record  func(status int)
+	written bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.written {
+		r.written = true
+		r.record(status)
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if !r.written {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func TestHonorCacheControl(t *testing.T) {
+	body := strings.Repeat("a", 8192)
+	var mu sync.Mutex
+	var cc string
+	var statuses []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", cc)
+		if cc == "" {
+			w.Header().Set("Expires", "0")
+		}
+		mu.Unlock()
+		rec := &statusRecorder{ResponseWriter: w, record: func(status int) {
+			mu.Lock()
+			statuses = append(statuses, status)
+			mu.Unlock()
+		}}
+		http.ServeContent(rec, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+	serve := func(c string) {
+		mu.Lock()
+		cc, statuses = c, nil
+		mu.Unlock()
+	}
+	recorded := func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return statuses
+	}
+
+	buf := make([]byte, 4)
+	read := func(s *SeekingHTTP) {
+		for range 2 {
+			_, err := s.ReadAt(buf, 100)
+			assert.NoError(t, err)
+		}
+	}
+	for _, c := range []struct {
+		cc       string
+		statuses []int
+	}{
+		{"max-age=3600", []int{http.StatusPartialContent}},
+		// revalidated on every read
+		{"no-cache", []int{http.StatusPartialContent, http.StatusNotModified}},
+		{"max-age=60, must-revalidate", []int{http.StatusPartialContent}},
+		// an invalid Expires header is expired
+		{"", []int{http.StatusPartialContent, http.StatusNotModified}},
+		// not cached at all
+		{"no-store", []int{http.StatusPartialContent, http.StatusPartialContent}},
+	} {
+		serve(c.cc)
+		s := New(srv.URL)
+		s.MinFetch = 1024
+		s.HonorCacheControl = true
+		read(s)
+		assert.Equal(t, c.statuses, recorded(), c.cc)
+	}
+
+	// ignored by default
+	serve("no-store")
+	s := New(srv.URL)
+	read(s)
+	assert.Equal(t, []int{http.StatusPartialContent}, recorded())
+}
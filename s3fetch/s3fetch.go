@@ -0,0 +1,136 @@
+// Package s3fetch implements a seekinghttp.RangeFetcher reading objects from
+// Amazon S3 with ranged GetObject calls, so s3://bucket/key URLs can be read
+// without presigning.
+package s3fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/paralin/seekinghttp"
+)
+
+// API is the part of the S3 client used by the Fetcher, implemented by
+// *s3.Client.
+type API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Fetcher fetches ranges of an S3 object.
+type Fetcher struct {
+	Client API
+	Bucket string
+	Key    string
+
+	// VersionID pins the version of the object if set.
+	VersionID string
+
+	// RequesterPays acknowledges that the requester pays for the requests
+	// and the data transfer of requester-pays buckets.
+	RequesterPays bool
+}
+
+// _ is a type assertion
+var _ seekinghttp.RangeFetcher = (*Fetcher)(nil)
+
+// New initializes a Fetcher for the object.
+func New(client API, bucket, key string) *Fetcher {
+	return &Fetcher{Client: client, Bucket: bucket, Key: key}
+}
+
+// ParseURL parses a URL of the form s3://bucket/key.
+func ParseURL(rawURL string) (bucket, key string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", "", fmt.Errorf("invalid s3 url %q: expected s3://bucket/key", rawURL)
+	}
+	return u.Host, key, nil
+}
+
+// Open returns a reader for the s3://bucket/key URL using a client with the
+// default AWS configuration, such as credentials from the environment, the
+// shared config files or an IAM role.
+func Open(ctx context.Context, rawURL string, optFns ...func(*config.LoadOptions) error) (*seekinghttp.SeekingHTTP, error) {
+	bucket, key, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return seekinghttp.NewFromFetcher(rawURL, New(s3.NewFromConfig(cfg), bucket, key)), nil
+}
+
+// FetchRange fetches a range of the object with GetObject.
+func (f *Fetcher) FetchRange(ctx context.Context, off, length int64) ([]byte, int64, string, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(f.Bucket),
+		Key:    aws.String(f.Key),
+		Range:  aws.String("bytes=" + strconv.FormatInt(off, 10) + "-" + strconv.FormatInt(off+max(length, 1)-1, 10)),
+	}
+	if f.VersionID != "" {
+		in.VersionId = aws.String(f.VersionID)
+	}
+	if f.RequesterPays {
+		in.RequestPayer = types.RequestPayerRequester
+	}
+
+	out, err := f.Client.GetObject(ctx, in)
+	if err != nil {
+		return nil, -1, "", mapErr(err)
+	}
+	defer out.Body.Close()
+
+	size := int64(-1)
+	if out.ContentRange != nil {
+		// the form is "bytes start-end/size"
+		_, sizeStr, _ := strings.Cut(*out.ContentRange, "/")
+		if n, err := strconv.ParseInt(sizeStr, 10, 64); err == nil {
+			size = n
+		}
+	}
+	validator := aws.ToString(out.ETag)
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		// keep what arrived, the rest is retried
+		return data, size, validator, fmt.Errorf("read %d bytes: %v: %w", len(data), err, io.ErrUnexpectedEOF)
+	}
+	if out.ContentLength != nil && int64(len(data)) < *out.ContentLength {
+		return data, size, validator, fmt.Errorf("read %d bytes of %d: %w", len(data), *out.ContentLength, io.ErrUnexpectedEOF)
+	}
+	return data, size, validator, nil
+}
+
+// mapErr maps the errors of S3 to the errors of seekinghttp.
+func mapErr(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch apiErr.ErrorCode() {
+	case "InvalidRange":
+		// the range starts at or past the end of the object
+		return io.EOF
+	case "NoSuchKey", "NoSuchBucket", "NotFound":
+		return fmt.Errorf("%w: %w", err, seekinghttp.ErrNotFound)
+	case "AccessDenied", "Forbidden":
+		return fmt.Errorf("%w: %w", err, seekinghttp.ErrForbidden)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package s3fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/paralin/seekinghttp"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockAPI serves GetObject from body.
+type mockAPI struct {
+	t     *testing.T
+	body  string
+	payer types.RequestPayer
+}
+
+func (m *mockAPI) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if aws.ToString(in.Bucket) != "bucket" || aws.ToString(in.Key) != "dir/obj" {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchKey"}
+	}
+	assert.Equal(m.t, m.payer, in.RequestPayer)
+	var start, end int64
+	_, err := fmt.Sscanf(aws.ToString(in.Range), "bytes=%d-%d", &start, &end)
+	assert.NoError(m.t, err)
+	if start >= int64(len(m.body)) {
+		return nil, &smithy.GenericAPIError{Code: "InvalidRange"}
+	}
+	end = min(end, int64(len(m.body))-1)
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader(m.body[start : end+1])),
+		ContentLength: aws.Int64(end - start + 1),
+		ContentRange:  aws.String(fmt.Sprintf("bytes %d-%d/%d", start, end, len(m.body))),
+		ETag:          aws.String(`"abc"`),
+	}, nil
+}
+
+func TestFetcher(t *testing.T) {
+	api := &mockAPI{t: t, body: strings.Repeat("0123456789", 10), payer: types.RequestPayerRequester}
+	bucket, key, err := ParseURL("s3://bucket/dir/obj")
+	assert.NoError(t, err)
+	f := New(api, bucket, key)
+	f.RequesterPays = true
+
+	s := seekinghttp.NewFromFetcher("s3://bucket/dir/obj", f)
+	s.MinFetch = 0
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 33)
+	assert.NoError(t, err)
+	assert.Equal(t, "34567", string(buf[:n]))
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+	assert.Equal(t, `"abc"`, s.ETag())
+
+	_, err = s.ReadAt(buf, 100)
+	assert.Equal(t, io.EOF, err)
+
+	f.Key = "missing"
+	_, err = seekinghttp.NewFromFetcher("s3://bucket/missing", f).ReadAt(buf, 0)
+	assert.ErrorIs(t, err, seekinghttp.ErrNotFound)
+
+	_, _, err = ParseURL("s3://bucket")
+	assert.Error(t, err)
+}
@@ -2,14 +2,39 @@ package seekinghttp
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
-	"github.com/pkg/errors"
+var (
+	// ErrNotFound is returned when the server responds with 404 Not Found.
+	ErrNotFound = errors.New("seekinghttp: not found")
+	// ErrForbidden is returned when the server responds with 403 Forbidden.
+	ErrForbidden = errors.New("seekinghttp: forbidden")
+	// ErrChanged is returned when the ETag of a response differs from the
+	// one learned before, because the object changed between requests.
+	ErrChanged = errors.New("seekinghttp: remote object changed")
+	// ErrBlockHash is returned when a block does not match its hash, see
+	// VerifyBlockHashes.
+	ErrBlockHash = errors.New("seekinghttp: block hash mismatch")
+	// ErrUnknownSize is returned when the size of the object cannot be
+	// learned from the responses of the server.
+	ErrUnknownSize = errors.New("seekinghttp: unknown size")
+	// ErrContentType is returned when the Content-Type of a response does
+	// not match ExpectContentType.
+	ErrContentType = errors.New("seekinghttp: unexpected content type")
 )
 
 type HttpClient interface {
@@ -24,7 +49,8 @@ type Logger interface {
 // SeekingHTTP uses a series of HTTP GETs with Range headers to implement
 // io.ReadSeeker and io.ReaderAt.
 //
-// NOTE: SeekingHTTP is NOT concurrency safe!
+// NOTE: SeekingHTTP is NOT concurrency safe! The exception is Close, which
+// may be called from another goroutine to abort an in-flight read.
 type SeekingHTTP struct {
 	URL       string
 	MinFetch  int64
@@ -32,67 +58,664 @@ type SeekingHTTP struct {
 	Logger    Logger
 	Client    HttpClient
 
-	url        *url.URL
+	// MaxRetries is the number of times a range request is retried after its
+	// body ended before the promised length or it exceeded ReadTimeout.
+	// Truncated bodies that cannot be resumed return io.ErrUnexpectedEOF.
+	MaxRetries int
+
+	// Backoff decides which failed range requests are retried, up to
+	// MaxRetries times, and how long to wait before, if set. If nil, the
+	// failures above are retried immediately.
+	Backoff BackoffPolicy
+
+	// RetryBudget bounds the retries if set. It may be shared by many
+	// readers, see Factory.
+	RetryBudget *RetryBudget
+
+	// ReadTimeout limits the time of each range request including reading
+	// its body, independent of any timeout of the Client. Zero means no
+	// timeout. A deadline of the caller's context that is sooner wins, and
+	// requests are not retried once it has passed.
+	ReadTimeout time.Duration
+
+	// MaxFetch is the most bytes loaded by a single request if set, capping
+	// the other fetch lengths. Longer reads are loaded by consecutive
+	// requests. See SetMinFetch and SetMaxFetch for changing the lengths of
+	// a reader in use.
+	MaxFetch int64
+
+	// AlignFetch widens the ranges loaded on a miss to multiples of it from
+	// the start of the object if set, such as 8 MiB, so CDN edge caches and
+	// a shared Cache see a small, repeatable set of ranges. MaxFetch should
+	// be a multiple of it.
+	AlignFetch int64
+
+	// BlockSize addresses the object as blocks of this size if set, for
+	// block device semantics. Every load is a single whole block, cached as
+	// its own range and verified by VerifyBlock, and the other fetch lengths
+	// are ignored.
+	BlockSize int64
+
+	// VerifyBlock checks every block loaded in block mode if set, such as
+	// against its hash, see VerifyBlockHashes. An error fails the read and
+	// the block is not cached.
+	VerifyBlock func(index int64, data []byte) error
+
+	// DoubleBuffer loads the range following a sequential miss in the
+	// background into a second buffer, so a consumer reading the loaded
+	// range does not wait for the next one. The hooks, such as SignRequest,
+	// are then called concurrently.
+	DoubleBuffer bool
+
+	// Prefetch runs the background loads if set, bounding how many run at
+	// once. It may be shared by many readers, see Factory. If nil, every
+	// reader runs its own.
+	Prefetch *PrefetchPool
+
+	// AutoFetch adapts the length loaded on a miss to the access pattern,
+	// starting at MinFetch. It grows while the reads are sequential and
+	// shrinks on far seeks away from loads that were mostly unread, but not
+	// below the bytes the connection transfers during the latency of a
+	// request, as measured.
+	AutoFetch bool
+
+	// SequentialFetch is the length loaded instead of MinFetch by a miss
+	// continuing the range loaded by the last miss if set, so scans load
+	// large ranges while random probes, such as index lookups, load
+	// MinFetch. It is ignored with AutoFetch.
+	SequentialFetch int64
+
+	// HintFetch is the length of the requests loading a range declared by
+	// Hint if set, aligned to multiples of it from the start of the object,
+	// such as 8 MiB for the column chunks of a parquet file. Zero loads the
+	// rest of a hinted range on a miss.
+	HintFetch int64
+
+	// TrustBodyLength trusts the bytes read over the lengths in the headers
+	// of partial responses, for proxies reporting wrong lengths. Longer
+	// bodies are kept and shorter ones continued by another request like a
+	// short response, instead of failing or being retried.
+	TrustBodyLength bool
+
+	// StrictSeek validates the target of every Seek against the size of the
+	// object, fetching the size if necessary. Seeking to a negative offset
+	// returns os.ErrInvalid and seeking past the end returns io.EOF.
+	StrictSeek bool
+
+	// Cache holds the loaded ranges. If nil, a private cache holding only
+	// the most recently loaded range is used. Readers sharing a Cache only
+	// share the bytes of requests with the same Authorization and Cookie
+	// headers and the same headers named by the Vary header of the server.
+	Cache *Cache
+
+	// CacheTag names the tenant of the reader in a shared Cache, whose
+	// ranges are limited by Cache.SetTagQuota.
+	CacheTag string
+
+	// Trace returns the trace attached to the request of the range of length
+	// bytes at off, if set, to observe DNS, connection and TLS setup and the
+	// time to the first byte of each request.
+	Trace func(off, length int64) *httptrace.ClientTrace
+
+	// StartSpan starts a span of an operation of the reader if set, to
+	// connect a tracing system such as OpenTelemetry: SpanRead, SpanReadAt
+	// and SpanSeek for the calls of the application and SpanFetch for every
+	// range request. The context it returns is passed on, so the range
+	// requests of a read are started with the context of its span and
+	// become its children. end is called when the operation finished with
+	// the bytes read, the new offset for SpanSeek, and the error.
+	StartSpan func(ctx context.Context, op string, off, length int64) (_ context.Context, end func(n int64, err error))
+
+	// ObserveLatency is called with the duration of each stage of every
+	// range request if set, see the Stage constants, to feed a metrics
+	// system. Only StageRequest is reported for a Fetcher.
+	ObserveLatency func(stage string, d time.Duration)
+
+	// TraceTimings logs the timings of the stages of each range request to
+	// the Logger.
+	TraceTimings bool
+
+	// DebugDump receives a dump of every request and response if set, for
+	// diagnosing misbehaving servers. It may be set and cleared between reads.
+	DebugDump io.Writer
+
+	// DebugDumpBody is the most bytes of each body included in the dumps.
+	// Zero dumps the headers only.
+	DebugDumpBody int
+
+	// RequestLog receives a line of JSON for every range request and every
+	// read served from the cache if set, for ingestion into log pipelines.
+	// Shared writers must be safe for concurrent use.
+	RequestLog io.Writer
+
+	// ErrorBodyLimit is the most bytes of the body of an unsuccessful
+	// response read into its StatusError, to see why a request was denied.
+	// The rest is not downloaded. Zero reads up to 1024 bytes and a negative
+	// limit reads none.
+	ErrorBodyLimit int
+
+	// OnEgress is called with the total bytes downloaded by the reader, see
+	// Egress, each time the total crosses a multiple of EgressThreshold, to
+	// attribute the egress of shared readers. Clones count from zero.
+	OnEgress        func(total int64)
+	EgressThreshold int64
+
+	// OnAnomaly is called for every unexpected response of the server if
+	// set, such as a full response to a range request, so broken origins
+	// are noticed. They are also counted in Stats.
+	OnAnomaly func(a Anomaly)
+
+	// Fetcher fetches the ranges instead of HTTP requests if set. The caching,
+	// retries and seeking work the same and the HTTP specific fields are
+	// ignored. Close does not close the Fetcher, it may be shared by clones.
+	Fetcher RangeFetcher
+
+	// RangeStrategy expresses the ranges on the wire and interprets the ranges
+	// of the responses. If nil, the standard Range header is used.
+	RangeStrategy RangeStrategy
+
+	// Header holds extra headers sent with every request, such as
+	// Authorization.
+	Header http.Header
+
+	// UserAgent is the User-Agent of every request. If empty, the User-Agent
+	// of the Request template or else DefaultUserAgent is used.
+	UserAgent string
+
+	// CacheControl is the Cache-Control header of every request if set, such
+	// as "no-transform" so intermediaries do not alter partial responses or
+	// "no-cache" so they revalidate mutable objects. A no-cache directive is
+	// also sent as Pragma for HTTP/1.0 caches.
+	CacheControl string
+
+	// HonorCacheControl limits how long the loaded ranges are served from
+	// the cache by the Cache-Control max-age of the responses, or their
+	// Expires header, for mutable objects. Expired ranges are revalidated
+	// with a conditional request before they are served again, which needs
+	// an ETag, and ranges of no-store responses are not cached at all.
+	HonorCacheControl bool
+
+	// Accept is the Accept header of every request if set, for APIs that
+	// only return the raw bytes of the object for a specific media type.
+	Accept string
+
+	// ExpectContentType is the expected Content-Type of the object if set,
+	// matched case-insensitively as a prefix, such as "application/zip" or
+	// "image/". Responses of another type, typically HTML error or login
+	// pages sent with status 200, fail with ErrContentType. It is ignored by
+	// a Fetcher.
+	ExpectContentType string
+
+	// Query modifies the query parameters of every request if set, such as
+	// adding a cache-busting token or an API key, leaving URL unchanged. The
+	// parameters are not added to the targets of redirects.
+	Query func(q url.Values)
+
+	// SignRequest is called with every request immediately before it is
+	// sent if set, including retries and redirects, to sign it with schemes
+	// covering the method, headers and time. An error fails the request.
+	SignRequest func(ctx context.Context, req *http.Request) error
+
+	// Method is the method of the range requests, GET if empty. The Range
+	// header is set and the response handled the same way for any method.
+	// Size of an object read with a method other than GET loads its first
+	// range instead of issuing a HEAD request.
+	Method string
+
+	// GetBody returns a new copy of the body sent with every range request.
+	// If nil, no body is sent.
+	GetBody func() (io.ReadCloser, error)
+
+	// RedirectHeaders are the headers of Header, Authorization and Cookie
+	// sent to redirect targets on other origins, such as an API key valid
+	// for a mirror. By default they are sent to the origin of URL only.
+	RedirectHeaders []string
+
+	// ResolveTTL is how long the final URL of a redirected request is used
+	// by later requests before URL is resolved again. Zero keeps it until a
+	// request to it fails with a client error, which is retried against URL,
+	// such as an expired presigned URL of a mirror.
+	ResolveTTL time.Duration
+
+	// Request is a template cloned for every request instead of building a
+	// bare GET of URL, keeping its method, URL, headers and cookies. URL
+	// still keys the cache. A body of the template is sent with every
+	// request and must be replayable by GetBody.
+	Request *http.Request
+
+	url *url.URL
+	// resolved is the final URL of the last redirected request, at
+	// resolvedAt.
+	resolved   *url.URL
+	resolvedAt time.Time
 	offset     int64
-	last       *bytes.Buffer
-	lastOffset int64
+	private    *Cache
+	etag       string
+	limiter    *limiter
+	// caps are the capabilities of the server, see Capabilities. noHead is
+	// set if the server does not answer HEAD requests.
+	caps   Capabilities
+	noHead bool
+	// typeChecked is set once a response matched ExpectContentType.
+	typeChecked bool
+	// sniffed is the result of SniffContentType.
+	sniffed string
+	// lastModified is the Last-Modified header learned from the responses.
+	lastModified string
+	// traceLevel is the TraceLevel, see SetTraceLevel.
+	traceLevel atomic.Int32
+	// noStore and expires are the lifetime of the bytes of the last
+	// response, see HonorCacheControl.
+	noStore bool
+	expires time.Time
+	// hints are the ranges declared by Hint, sorted by offset.
+	hints []hint
+	// vary are the request headers named by the Vary header of the
+	// responses, see cacheKey.
+	vary []string
+	// key is the last key computed by cacheKey.
+	key *cacheKey
+	// background is set for clones loading in the background.
+	background bool
+	stats      stats
+	tuner      fetchTuner
+	ahead      *readahead
+
+	// mu guards the cache and closed against a concurrent Close.
+	mu       sync.Mutex
+	closed   bool
+	initOnce sync.Once
+	ctx      context.Context
+	cancel   context.CancelFunc
 }
 
 // _ is a type assertion
 var (
-	_ io.ReadSeeker = (*SeekingHTTP)(nil)
-	_ io.ReaderAt   = (*SeekingHTTP)(nil)
+	_ io.ReadSeekCloser = (*SeekingHTTP)(nil)
+	_ io.ReaderAt       = (*SeekingHTTP)(nil)
+	_ io.Closer         = (*SeekingHTTP)(nil)
 )
 
-// New initializes a SeekingHTTP for the given URL.
+// New initializes a SeekingHTTP for the given URL. A http+unix URL, such
+// as http+unix://%2Frun%2Fapp.sock/path, is fetched over the unix socket
+// given by its escaped host. A file URL is read from the local file system
+// and a data URL from its decoded data.
 func New(url string) *SeekingHTTP {
+	if socket, _, ok, err := splitUnixURL(url); ok && err == nil {
+		return NewWithClient(url, &http.Client{Transport: newUnixTransport(socket)})
+	}
+	if strings.HasPrefix(url, "file:") {
+		return NewWithClient(url, newFileClient())
+	}
+	if strings.HasPrefix(url, "data:") {
+		return NewWithClient(url, newDataClient(url))
+	}
 	return NewWithClient(url, http.DefaultClient)
 }
 
 // NewWithClient initializes a SeekingHTTP for the given URL with a client..
 func NewWithClient(url string, client HttpClient) *SeekingHTTP {
-	return &SeekingHTTP{URL: url, Client: client, MinFetch: 1024 * 1024}
+	return &SeekingHTTP{URL: url, Client: client, MinFetch: 1024 * 1024, MaxRetries: 2}
+}
+
+// NewFromFetcher initializes a SeekingHTTP reading the object identified by
+// url with f, see Fetcher. url keys the cache.
+func NewFromFetcher(url string, f RangeFetcher) *SeekingHTTP {
+	s := NewWithClient(url, nil)
+	s.Fetcher = f
+	return s
+}
+
+// NewFromRequest initializes a SeekingHTTP cloning req for every request,
+// see Request.
+func NewFromRequest(req *http.Request) *SeekingHTTP {
+	s := New(req.URL.String())
+	s.Request = req
+	return s
 }
 
 func (s *SeekingHTTP) SetLogger(logger Logger) {
 	s.Logger = logger
 }
 
-func (s *SeekingHTTP) newReq() (*http.Request, error) {
+// SetBasicAuth sets the Authorization header of every request to use HTTP
+// Basic Authentication with the provided username and password.
+func (s *SeekingHTTP) SetBasicAuth(username, password string) {
+	s.Header = setBasicAuth(s.Header, username, password)
+}
+
+// setBasicAuth sets the basic Authorization in h, allocating it if nil.
+func setBasicAuth(h http.Header, username, password string) http.Header {
+	req := &http.Request{Header: make(http.Header)}
+	req.SetBasicAuth(username, password)
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Set("Authorization", req.Header.Get("Authorization"))
+	return h
+}
+
+// SetRequesterPays sets the x-amz-request-payer header acknowledging that
+// the requester pays for reading an S3 requester pays bucket. The s3fetch
+// Fetcher has the RequesterPays field instead.
+func (s *SeekingHTTP) SetRequesterPays() {
+	s.Header = setRequesterPays(s.Header)
+}
+
+// setRequesterPays sets the requester pays header in h, allocating it if nil.
+func setRequesterPays(h http.Header) http.Header {
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Set("X-Amz-Request-Payer", "requester")
+	return h
+}
+
+// cache returns the cache to use for reads.
+func (s *SeekingHTTP) cache() *Cache {
+	if s.Cache != nil {
+		return s.Cache
+	}
+	if s.private == nil {
+		s.private = NewCache(0)
+	}
+	return s.private
+}
+
+// ETag returns the entity tag of the object learned from the responses, or
+// an empty string if the server did not send one yet.
+func (s *SeekingHTTP) ETag() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.etag
+}
+
+// LastModified returns the Last-Modified header of the object learned from
+// the responses, or an empty string if the server did not send one yet.
+func (s *SeekingHTTP) LastModified() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastModified
+}
+
+// Clone returns a new reader for the same object with its own offset. The
+// clone shares the configuration, client and what was learned about the
+// object, such as its size and ETag. If shareCache is set the clone also
+// shares the cache with s; if s had a private cache, it becomes s.Cache.
+func (s *SeekingHTTP) Clone(shareCache bool) *SeekingHTTP {
+	c := &SeekingHTTP{
+		URL:         s.URL,
+		MinFetch:    s.MinFetch,
+		Logger:      s.Logger,
+		Client:      s.Client,
+		MaxRetries:  s.MaxRetries,
+		ReadTimeout: s.ReadTimeout,
+		StrictSeek:  s.StrictSeek,
+		Header:      s.Header.Clone(),
+		UserAgent:   s.UserAgent,
+		Request:     s.Request,
+		Method:      s.Method,
+		GetBody:     s.GetBody,
+		ResolveTTL:  s.ResolveTTL,
+
+		RedirectHeaders: s.RedirectHeaders,
+		CacheControl:    s.CacheControl,
+		Accept:          s.Accept,
+		MaxFetch:        s.MaxFetch,
+		AlignFetch:      s.AlignFetch,
+		BlockSize:       s.BlockSize,
+		VerifyBlock:     s.VerifyBlock,
+		DoubleBuffer:    s.DoubleBuffer,
+		Prefetch:        s.Prefetch,
+		AutoFetch:       s.AutoFetch,
+		SequentialFetch: s.SequentialFetch,
+		HintFetch:       s.HintFetch,
+		CacheTag:        s.CacheTag,
+		TrustBodyLength: s.TrustBodyLength,
+		Query:           s.Query,
+		SignRequest:     s.SignRequest,
+
+		RangeStrategy:  s.RangeStrategy,
+		Fetcher:        s.Fetcher,
+		Trace:          s.Trace,
+		StartSpan:      s.StartSpan,
+		TraceTimings:   s.TraceTimings,
+		ObserveLatency: s.ObserveLatency,
+		DebugDump:      s.DebugDump,
+		DebugDumpBody:  s.DebugDumpBody,
+		RequestLog:     s.RequestLog,
+		ErrorBodyLimit: s.ErrorBodyLimit,
+
+		Backoff:         s.Backoff,
+		RetryBudget:     s.RetryBudget,
+		OnEgress:        s.OnEgress,
+		EgressThreshold: s.EgressThreshold,
+		OnAnomaly:       s.OnAnomaly,
+
+		ExpectContentType: s.ExpectContentType,
+		HonorCacheControl: s.HonorCacheControl,
+
+		url:        s.url,
+		resolved:   s.resolved,
+		resolvedAt: s.resolvedAt,
+		etag:       s.etag,
+		caps:       s.caps,
+		hints:      slices.Clone(s.hints),
+		vary:       s.vary,
+		noHead:     s.noHead,
+		sniffed:    s.sniffed,
+		limiter:    s.limiter,
+
+		lastModified: s.lastModified,
+	}
+	if s.KnownSize != nil {
+		size := *s.KnownSize
+		c.KnownSize = &size
+	}
+	c.traceLevel.Store(s.traceLevel.Load())
+	if shareCache {
+		s.mu.Lock()
+		s.Cache = s.cache()
+		s.mu.Unlock()
+		c.Cache = s.Cache
+	}
+	return c
+}
+
+// learn copies what c learned about the object to s, if s does not know it
+// yet.
+func (s *SeekingHTTP) learn(c *SeekingHTTP) {
+	if s.KnownSize == nil && c.KnownSize != nil {
+		size := *c.KnownSize
+		s.KnownSize = &size
+	}
+	if s.etag == "" {
+		s.etag = c.etag
+	}
+	if s.lastModified == "" {
+		s.lastModified = c.lastModified
+	}
+	if s.vary == nil {
+		s.vary = c.vary
+	}
+	if s.resolved == nil {
+		s.resolved, s.resolvedAt = c.resolved, c.resolvedAt
+	}
+}
+
+// context returns the context for requests, which is canceled by Close.
+func (s *SeekingHTTP) context() context.Context {
+	s.initOnce.Do(func() {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	})
+	return s.ctx
+}
+
+// requestContext returns the context for a single request, which is canceled
+// when ctx is done or the reader is closed.
+func (s *SeekingHTTP) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.context(), cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// ctxErr replaces err with os.ErrClosed if the reader was closed or with the
+// error of ctx if it is done.
+func (s *SeekingHTTP) ctxErr(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if s.context().Err() != nil {
+		// the request was aborted by Close
+		return os.ErrClosed
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (s *SeekingHTTP) newReq(ctx context.Context) (*http.Request, error) {
+	var req *http.Request
 	var err error
-	if s.url == nil {
-		s.url, err = url.Parse(s.URL)
+	if s.Request != nil {
+		req, err = s.cloneReq(ctx)
+	} else {
+		if s.url == nil {
+			s.url, err = parseURL(s.URL)
+			if err != nil {
+				return nil, err
+			}
+		}
+		req, err = http.NewRequestWithContext(ctx, "GET", s.url.String(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Query != nil {
+		q := req.URL.Query()
+		s.Query(q)
+		req.URL.RawQuery = q.Encode()
+	}
+
+	if s.Method != "" {
+		req.Method = s.Method
+	}
+	if s.GetBody != nil {
+		req.Body, err = s.GetBody()
 		if err != nil {
 			return nil, err
 		}
+		req.GetBody = s.GetBody
 	}
-	return http.NewRequest("GET", s.url.String(), nil)
+	s.setHeader(req.Header)
+	return req, nil
 }
 
-func fmtRange(from, l int64) string {
-	var to int64
-	if l == 0 {
-		to = from
-	} else {
-		to = from + (l - 1)
+// setHeader sets the headers of every request in h.
+func (s *SeekingHTTP) setHeader(h http.Header) {
+	requestHeader{s.Header, s.UserAgent, s.Accept, s.CacheControl}.set(h)
+}
+
+// requestHeader are the fields of a reader or Factory setting the headers of
+// the requests.
+type requestHeader struct {
+	header                          http.Header
+	userAgent, accept, cacheControl string
+}
+
+// set sets the headers in h.
+func (r requestHeader) set(h http.Header) {
+	if r.userAgent != "" {
+		h.Set("User-Agent", r.userAgent)
+	} else if h.Get("User-Agent") == "" && DefaultUserAgent != "" {
+		h.Set("User-Agent", DefaultUserAgent)
+	}
+	if r.accept != "" {
+		h.Set("Accept", r.accept)
+	}
+	if r.cacheControl != "" {
+		h.Set("Cache-Control", r.cacheControl)
+		if hasDirective(r.cacheControl, "no-cache") {
+			h.Set("Pragma", "no-cache")
+		}
+	}
+	for k, v := range r.header {
+		h[k] = append([]string(nil), v...)
+	}
+}
+
+// hasDirective returns whether the Cache-Control header cc has the directive.
+func hasDirective(cc, directive string) bool {
+	_, ok := directiveValue(cc, directive)
+	return ok
+}
+
+// directiveValue returns the value of the directive of the Cache-Control
+// header cc, unquoted, and whether cc has it.
+func directiveValue(cc, directive string) (string, bool) {
+	for d := range strings.SplitSeq(cc, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(name, directive) {
+			return strings.Trim(value, `"`), true
+		}
 	}
+	return "", false
+}
 
-	var sb strings.Builder
-	sb.Grow(24)
-	_, _ = sb.WriteString("bytes=")
-	_, _ = sb.WriteString(strconv.FormatInt(from, 10))
-	_, _ = sb.WriteString("-")
-	_, _ = sb.WriteString(strconv.FormatInt(to, 10))
-	return sb.String()
+// cloneReq clones the Request template.
+func (s *SeekingHTTP) cloneReq(ctx context.Context) (*http.Request, error) {
+	req := s.Request.Clone(ctx)
+	if s.Request.GetBody != nil {
+		body, err := s.Request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	} else if req.Body != nil && req.Body != http.NoBody && s.GetBody == nil {
+		return nil, fmt.Errorf("request template body without GetBody: %w", os.ErrInvalid)
+	}
+	return req, nil
+}
+
+// method returns the method of the range requests.
+func (s *SeekingHTTP) method() string {
+	switch {
+	case s.Method != "":
+		return s.Method
+	case s.Request != nil && s.Request.Method != "":
+		return s.Request.Method
+	default:
+		return "GET"
+	}
 }
 
 // ReadAt reads len(buf) bytes into buf starting at offset off.
-// Returns the length read into buf.
+// Returns the length read into buf. If fewer than len(buf) bytes are read
+// because the end of the object was reached, the error is io.EOF. A read that
+// fills buf returns a nil error, even if it ends exactly at the end.
 func (s *SeekingHTTP) ReadAt(buf []byte, off int64) (n int, err error) {
-	n, err = s.ReadAtWithLength(buf, off, int64(len(buf)))
+	return s.ReadAtContext(context.Background(), buf, off)
+}
+
+// ReadAtContext is like ReadAt but aborts the underlying requests when ctx is
+// done.
+func (s *SeekingHTTP) ReadAtContext(ctx context.Context, buf []byte, off int64) (n int, err error) {
+	ctx, end := s.startSpan(ctx, SpanReadAt, off, int64(len(buf)))
+	defer func() { end(int64(n), err) }()
+	return s.readAtFull(ctx, buf, off)
+}
+
+// readAtFull is ReadAtContext without its span.
+func (s *SeekingHTTP) readAtFull(ctx context.Context, buf []byte, off int64) (n int, err error) {
+	n, err = s.readAtWithLength(ctx, buf, off, int64(len(buf)))
 	n = min(len(buf), n)
 	if n != len(buf) && err == nil {
-		// ReadAt must always return len(buf), nil
+		// ReadAt must return a non-nil error if it reads less than len(buf)
 		err = io.EOF
 	}
 	return n, err
@@ -103,6 +726,33 @@ func (s *SeekingHTTP) ReadAt(buf []byte, off int64) (n int, err error) {
 // The minimum read size is controlled by MinFetch.
 // Returns min(full length read, length) (may be larger than len(buf))
 func (s *SeekingHTTP) ReadAtWithLength(buf []byte, off, length int64) (n int, err error) {
+	return s.ReadAtWithLengthContext(context.Background(), buf, off, length)
+}
+
+// ReadAtWithLengthContext is like ReadAtWithLength but aborts the underlying
+// requests when ctx is done.
+func (s *SeekingHTTP) ReadAtWithLengthContext(ctx context.Context, buf []byte, off, length int64) (n int, err error) {
+	ctx, end := s.startSpan(ctx, SpanReadAt, off, length)
+	defer func() { end(int64(n), err) }()
+	return s.readAtWithLength(ctx, buf, off, length)
+}
+
+// readAtWithLength is ReadAtWithLengthContext without its span.
+func (s *SeekingHTTP) readAtWithLength(ctx context.Context, buf []byte, off, length int64) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err = s.readAt(ctx, buf, off, length)
+	return n, s.ctxErr(ctx, err)
+}
+
+func (s *SeekingHTTP) readAt(ctx context.Context, buf []byte, off, length int64) (n int, err error) {
 	if s.Logger != nil {
 		s.Logger.Debugf("ReadAt len %v off %v", length, off)
 	}
@@ -110,60 +760,346 @@ func (s *SeekingHTTP) ReadAtWithLength(buf []byte, off, length int64) (n int, er
 	if off < 0 {
 		return 0, io.EOF
 	}
-
-	// Set the length to be at least MinFetch if set.
-	if s.MinFetch != 0 {
-		length = max(length, s.MinFetch)
+	if length < 0 {
+		return 0, fmt.Errorf("invalid negative length %d: %w", length, os.ErrInvalid)
 	}
 
-	// If the size is known, cap the length to the size.
+	// want is the part of the range the caller needs loaded. Cap it so that
+	// off+want cannot overflow and, if the size is known, to the size.
+	want := min(length, math.MaxInt64-off)
 	if s.KnownSize != nil {
-		length = min(*s.KnownSize-off, length)
-		if length < 0 {
+		if *s.KnownSize-off <= 0 && want != 0 {
 			return 0, io.EOF
 		}
+		want = min(want, *s.KnownSize-off)
+	}
+	if want == 0 {
+		return 0, nil
 	}
 
-	if s.last != nil && off >= s.lastOffset {
+	// Only the part the caller needs has to be cached, the fetch length
+	// just widens the range loaded on a miss.
+	cache := s.cache()
+	s.stats.cache.Store(cache)
+	if s.tracing(TraceCache) {
+		s.tracef(TraceCache, "read (%v-%v), cache %s", off, off+want, cache.segmentMap())
+	}
+	if s.BlockSize > 0 {
+		return s.readBlocks(ctx, cache, buf, off, want)
+	}
+	if cache.readAt(s.cacheKey(), buf, off, want) {
+		s.hit(off, want, min(int64(len(buf)), want))
+		return min(len(buf), int(want)), nil
+	}
+	if ra := s.takeAhead(ctx, cache, off, want); ra != nil {
+		s.tuner.loaded(ra.sp, want)
+		s.startAhead(ra.sp.end(), ra.length)
+		if cache.readAt(s.cacheKey(), buf, off, want) {
+			s.hit(off, want, min(int64(len(buf)), want))
+			return min(len(buf), int(want)), nil
+		}
+	}
+	if s.HonorCacheControl && s.revalidateExpired(ctx, cache, buf, off, want) {
+		s.hit(off, want, min(int64(len(buf)), want))
+		return min(len(buf), int(want)), nil
+	}
+	s.miss(off, want)
+	s.dropFarAhead(off)
+	sequential := s.tuner.sequential(off)
+
+	// Load at least the fetch length from, capped like want.
+	from, length := off, min(s.fetchLength(off, want), math.MaxInt64-off)
+	s.tracef(TracePlan, "miss (%v-%v): fetch length %d, sequential %v", off, off+want, length, sequential)
+	if a := s.AlignFetch; a > 0 {
+		from = off - off%a
 		end := off + length
-		if end <= s.lastOffset+int64(s.last.Len()) {
-			start := off - s.lastOffset
-			if s.Logger != nil {
-				s.Logger.Debugf("cache hit: range (%v-%v) is within cache (%v-%v)", off, off+length, s.lastOffset, s.lastOffset+int64(s.last.Len()))
-			}
-			copy(buf, s.last.Bytes()[start:end-s.lastOffset])
-			return min(len(buf), int(length)), nil
+		if r := end % a; r != 0 && end <= math.MaxInt64-(a-r) {
+			end += a - r
 		}
+		length = end - from
+		s.tracef(TracePlan, "aligned to %d: (%v-%v)", a, from, from+length)
+	}
+	if h, ok := s.hinted(off); ok {
+		from, length = s.hintRange(h, off, want)
+		s.tracef(TracePlan, "hinted range (%v-%v): (%v-%v)", h.off, h.end, from, from+length)
+	}
+	if s.KnownSize != nil {
+		length = min(length, *s.KnownSize-from)
 	}
+	s.tracef(TracePlan, "loading (%v-%v)", from, from+length)
 
+	sp, err := s.loadShared(ctx, cache, from, length, off-from+want)
+	if sp == nil {
+		return 0, err
+	}
+	s.tuner.loaded(sp, want)
+	if s.DoubleBuffer && sequential && err == nil {
+		s.startAhead(sp.end(), length)
+	}
+
+	// The server may have sent more than requested, trim to the range.
+	start := off - sp.off
+	avail := max(int64(sp.data.Len())-start, 0)
+	n = min(int(min(avail, from+length-off)), len(buf))
+	if n != 0 {
+		copy(buf, sp.data.Bytes()[start:])
+	}
+	s.served(int64(n), false)
+	return n, err
+}
+
+// hit records a read of n of the length bytes at off from the cache.
+func (s *SeekingHTTP) hit(off, length, n int64) {
+	s.stats.hits.Add(1)
+	s.served(n, true)
+	if s.AutoFetch {
+		s.tuner.hit(off, n)
+	}
 	if s.Logger != nil {
-		if s.last != nil {
-			s.Logger.Debugf("cache miss: range (%v-%v) is NOT within cache (%v-%v)", off, off+length, s.lastOffset, s.lastOffset+int64(s.last.Len()))
-		} else {
-			s.Logger.Debugf("cache miss: cache empty")
+		s.Logger.Debugf("cache hit: range (%v-%v) is within cache", off, off+length)
+	}
+	if s.RequestLog != nil {
+		s.logRequest(requestLogEntry{Offset: off, Length: length, Bytes: n, Cache: "hit"})
+	}
+}
+
+// miss records a read of length bytes at off missing the cache.
+func (s *SeekingHTTP) miss(off, length int64) {
+	if s.Logger != nil {
+		s.Logger.Debugf("cache miss: range (%v-%v) is NOT within cache", off, off+length)
+	}
+	s.stats.misses.Add(1)
+}
+
+// load fetches length bytes at off, of which the caller needs want, and
+// stores everything loaded in cache.
+func (s *SeekingHTTP) load(ctx context.Context, cache *Cache, off, length, want int64) (sp *span, err error) {
+	sp = &span{off: off}
+	defer func() {
+		if s.BlockSize > 0 {
+			// only whole verified blocks are kept and read
+			if err != nil {
+				sp = nil
+				return
+			}
+			if sp, err = s.block(sp, off, length); err != nil {
+				return
+			}
+		}
+		// keep everything that was loaded, even if the read failed
+		if !s.noStore {
+			cache.put(s.cacheKey(), s.CacheTag, sp.off, sp.data.Bytes(), s.validity())
+		}
+	}()
+
+	// Some servers cap the size of a range response and MaxFetch caps the
+	// requests. Keep fetching the remainder until the bytes the caller asked
+	// for are loaded.
+	var retries int
+	for {
+		got := sp.end() - off
+		n := length - got
+		if s.MaxFetch > 0 {
+			n = min(n, s.MaxFetch)
+		}
+		var partial bool
+		partial, err = s.fetch(ctx, sp, off+got, n, retries+1)
+		loaded := sp.end() - off
+		if err != nil {
+			if loaded >= want {
+				// the rest of the range was only read ahead
+				err = nil
+				break
+			}
+			if retries < s.MaxRetries {
+				delay, ok := s.retryDelay(ctx, retries+1, err)
+				if ok && !s.RetryBudget.take() {
+					if s.Logger != nil {
+						s.Logger.Debugf("fetch failed, retry budget exhausted: %v", err)
+					}
+					ok = false
+				}
+				if ok {
+					retries++
+					s.stats.retries.Add(1)
+					if s.Logger != nil {
+						s.Logger.Debugf("fetch failed: loaded %d of %d bytes, retrying in %v: %v", loaded, want, delay, err)
+					}
+					if err = s.sleep(ctx, delay); err == nil {
+						continue
+					}
+				}
+			}
+			if loaded <= 0 {
+				return sp, err
+			}
+			if err == io.EOF {
+				// nothing more to load, serve what the previous requests loaded
+				err = nil
+			}
+			break
+		}
+		if !partial || loaded == got || loaded >= want {
+			break
+		}
+		if s.KnownSize != nil && off+loaded >= *s.KnownSize {
+			break
+		}
+		if s.Logger != nil {
+			s.Logger.Debugf("short response: loaded %d of %d bytes, continuing", loaded, want)
+		}
+	}
+
+	return sp, err
+}
+
+// loadShared is like load, but if another reader sharing the cache is
+// loading the identical range already, it waits for and shares its result
+// instead of issuing the same requests.
+func (s *SeekingHTTP) loadShared(ctx context.Context, cache *Cache, off, length, want int64) (*span, error) {
+	key := flightKey{key: s.flightKey(), off: off, length: length, want: want}
+	for {
+		call, leader := cache.flights.join(key)
+		if leader {
+			return s.lead(ctx, cache, key, call)
+		}
+
+		if s.Logger != nil {
+			s.Logger.Debugf("joining in-flight load of range (%v-%v)", off, off+length)
+		}
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.abandoned {
+			// the other reader gave up, load it again
+			continue
 		}
+		if s.KnownSize == nil && call.size >= 0 {
+			size := call.size
+			s.KnownSize = &size
+		}
+		return call.sp, call.err
+	}
+}
+
+// errLoadPanicked is returned to the readers joining a load that panicked.
+var errLoadPanicked = errors.New("seekinghttp: shared load panicked")
+
+// lead runs the load of call as the leader of key. The joiners are woken up
+// even if the load panics, such as in a Fetcher, and get errLoadPanicked.
+func (s *SeekingHTTP) lead(ctx context.Context, cache *Cache, key flightKey, call *flightCall) (*span, error) {
+	call.err, call.size = errLoadPanicked, -1
+	defer cache.flights.done(key, call)
+	call.sp, call.err = s.load(ctx, cache, key.off, key.length, key.want)
+	if s.KnownSize != nil {
+		call.size = *s.KnownSize
+	}
+	call.abandoned = call.err != nil && (ctx.Err() != nil || s.context().Err() != nil)
+	return call.sp, call.err
+}
+
+// span is a contiguous range of the object loaded by one or more requests.
+type span struct {
+	off  int64
+	data bytes.Buffer
+}
+
+// end returns the offset after the last loaded byte.
+func (sp *span) end() int64 {
+	return sp.off + int64(sp.data.Len())
+}
+
+// retryable reports whether a failed fetch may succeed if it is retried.
+// Nothing is retried once ctx is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// maxDrain is the most of an unread response body that is drained to reuse
+// the connection. Longer remainders are dropped by closing the body.
+const maxDrain = 64 * 1024
+
+// fetch issues a single GET for length bytes at off and appends the response
+// body to sp. A full (200) response replaces sp with the whole object.
+// Returns whether the response was a partial (206) response.
+func (s *SeekingHTTP) fetch(ctx context.Context, sp *span, off, length int64, attempt int) (partial bool, err error) {
+	var status int
+	var n int64
+	s.stats.requests.Add(1)
+	defer func() { s.countEgress(n) }()
+	if s.RequestLog != nil || s.ObserveLatency != nil {
+		start := time.Now()
+		defer func() {
+			d := time.Since(start)
+			if s.ObserveLatency != nil {
+				s.ObserveLatency(StageRequest, d)
+			}
+			if s.RequestLog != nil {
+				s.logRequest(requestLogEntry{
+					Offset: off, Length: length, Status: status, Bytes: n,
+					Duration: d, Cache: "miss", Attempt: attempt, err: err,
+				})
+			}
+		}()
 	}
 
-	req, err := s.newReq()
+	ctx, end := s.startSpan(ctx, SpanFetch, off, length)
+	defer func() { end(n, err) }()
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	if s.ReadTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, s.ReadTimeout)
+		defer cancelTimeout()
+	}
+	if s.Fetcher != nil {
+		partial, err = s.fetchRange(ctx, sp, off, length)
+		n = sp.end() - off
+		return partial, err
+	}
+	ctx = s.traceContext(ctx, off, length)
+
+	req, err := s.newReq(ctx)
 	if err != nil {
-		return 0, err
+		return false, err
 	}
 
-	rng := fmtRange(off, length)
-	req.Header.Add("Range", rng)
+	s.rangeStrategy().SetRange(req, off, length)
+
+	release, err := s.limiter.acquire(ctx, s.background)
+	if err != nil {
+		return false, err
+	}
+	defer release()
 
 	if s.Logger != nil {
-		s.Logger.Infof("Start HTTP GET with Range: %s", rng)
+		s.Logger.Infof("Start HTTP %s of range (%v-%v)", req.Method, off, off+length)
 	}
 
-	resp, err := s.Client.Do(req)
+	sent := time.Now()
+	resp, err := s.do(req)
 	if err != nil {
-		return 0, err
+		return false, err
 	}
+	latency := time.Since(sent)
 
 	// body needs to be closed, even if responses that aren't 200 or 206
+	drain := true
 	defer func(body io.ReadCloser) {
-		_, cErr := io.Copy(io.Discard, body)
+		// Drain a bounded remainder so the connection can be reused, unless
+		// the request was aborted or failed.
+		var cErr error
+		if drain && ctx.Err() == nil {
+			_, cErr = io.CopyN(io.Discard, body, maxDrain)
+			if cErr == io.EOF {
+				cErr = nil
+			}
+		}
 		if cErr == nil {
 			cErr = body.Close()
 		} else {
@@ -174,128 +1110,558 @@ func (s *SeekingHTTP) ReadAtWithLength(buf []byte, off, length int64) (n int, er
 		}
 	}(resp.Body)
 
+	status = resp.StatusCode
 	if s.Logger != nil {
 		s.Logger.Infof("Response status: %v", resp.StatusCode)
 	}
 
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
-		s.lastOffset = off
-		if s.last == nil {
-			// Cache does not exist yet. So make it.
-			s.last = &bytes.Buffer{}
-		} else {
-			// Cache is getting replaced. Bring it back to zero bytes, but
-			// keep the underlying []byte, since we'll reuse it right away.
-			s.last.Reset()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent &&
+		resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		// error pages are not downloaded beyond ErrorBodyLimit
+		drain = false
+		return false, s.statusErr(resp)
+	}
+	// check before reading, so a mismatched body is not downloaded
+	rr, err := s.rangeStrategy().ResponseRange(resp, off)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// the range starts at or past the end of the object
+		if rr.Size >= 0 && s.KnownSize == nil {
+			s.KnownSize = &rr.Size
 		}
+		return false, io.EOF
+	}
 
-		n, err := s.last.ReadFrom(resp.Body)
-		if err != nil {
-			return 0, err
-		}
+	if err := s.checkContentType(resp); err != nil {
+		drain = false
+		return false, err
+	}
+	if err := s.checkValidators(resp); err != nil {
+		return false, err
+	}
+	s.learnLifetime(resp)
+	s.learnVary(resp)
 
-		contentLength := resp.ContentLength
-		if contentLength == 0 {
-			// for some reason the content length header was not set
-			contentLength = n
-		} else if n != contentLength {
-			return 0, errors.Errorf("read %d bytes but content length indicated %d", n, contentLength)
-		} else if resp.StatusCode == http.StatusOK && s.KnownSize == nil {
-			// status 200 = this is the full file, set the size.
-			size := contentLength
-			s.KnownSize = &size
+	partial = rr.Partial
+	if partial && rr.Start != off {
+		s.anomaly(resp, off, length, AnomalyRangeMismatch, "requested range starting at %d, got %d", off, rr.Start)
+		return false, fmt.Errorf("requested range starting at %d but server returned range starting at %d", off, rr.Start)
+	}
+	if !partial {
+		// The server ignored the range and sent the full file. Keep all of
+		// it, the requested range is trimmed out by the caller.
+		sp.data.Reset()
+		sp.off = 0
+	}
+
+	// expected is the length promised by the headers or -1 if unknown, such
+	// as for chunked responses. Unknown lengths trust the bytes read.
+	expected := resp.ContentLength
+	if expected == 0 {
+		// for some reason the content length header was not set
+		expected = -1
+	}
+	if partial && rr.Length >= 0 {
+		expected = rr.Length
+	}
+	size := rr.Size
+	if !partial && (off > 0 || expected > length) {
+		s.anomaly(resp, off, length, AnomalyFullResponse, "full response of %d bytes to range (%v-%v)", expected, off, off+length)
+	}
+	if enc := resp.Header.Get("Content-Encoding"); partial && enc != "" && enc != "identity" {
+		s.anomaly(resp, off, length, AnomalyCompressedRange, "partial response with content encoding %s", enc)
+	}
+
+	prev := sp.data.Len()
+	var rErr error
+	n, rErr = sp.data.ReadFrom(resp.Body)
+	if s.AutoFetch {
+		s.tuner.observe(n, latency, time.Since(sent)-latency)
+	}
+	if partial && expected >= 0 && n > expected && !s.TrustBodyLength {
+		s.anomaly(resp, off, length, AnomalyLongBody, "read %d bytes but the response range indicated %d", n, expected)
+		sp.data.Truncate(prev)
+		return false, fmt.Errorf("read %d bytes but the response range indicated %d", n, expected)
+	}
+	if size >= 0 && s.KnownSize == nil {
+		s.KnownSize = &size
+	}
+	if partial {
+		s.caps.Ranges = true
+		s.caps.MaxRange = max(s.caps.MaxRange, n)
+	}
+	if !partial && off == 0 && expected < 0 && n > length {
+		s.anomaly(resp, off, length, AnomalyFullResponse, "full response of %d bytes to range (%v-%v)", n, off, off+length)
+	}
+
+	if rErr == nil && expected >= 0 && n < expected {
+		rErr = io.ErrUnexpectedEOF
+	}
+	if s.TrustBodyLength && partial && n > 0 && errors.Is(rErr, io.ErrUnexpectedEOF) {
+		// a short response, the rest is loaded by another request
+		if s.Logger != nil {
+			s.Logger.Debugf("read %d bytes but the response indicated %d, trusting the body", n, expected)
+		}
+		rErr = nil
+	}
+	if rErr != nil {
+		// The data that arrived is valid, keep it so the rest can be resumed.
+		if s.Logger != nil {
+			s.Logger.Debugf("loaded %d bytes before error: %v", n, rErr)
+		}
+		if errors.Is(rErr, io.ErrUnexpectedEOF) {
+			return partial, fmt.Errorf("read %d bytes but response indicated %d: %w", n, expected, io.ErrUnexpectedEOF)
 		}
+		return partial, rErr
+	}
 
+	if expected >= 0 && n > expected {
+		// over-long body, keep the extra data
+		s.anomaly(resp, off, length, AnomalyLongBody, "read %d bytes but content length indicated %d", n, expected)
 		if s.Logger != nil {
-			s.Logger.Debugf("loaded %d bytes into last", contentLength)
+			s.Logger.Debugf("read %d bytes but content length indicated %d, keeping all", n, expected)
+		}
+	}
+	if !partial && s.KnownSize == nil && resp.ContentLength != 0 {
+		// this is the full file, set the size.
+		full := n
+		s.KnownSize = &full
+	}
+
+	if s.Logger != nil {
+		s.Logger.Debugf("loaded %d bytes", n)
+	}
+
+	return partial, nil
+}
+
+// checkValidators learns the ETag and Last-Modified of the object from resp,
+// failing with ErrChanged if they differ from those learned before. The
+// Last-Modified time is only compared for objects without an ETag.
+func (s *SeekingHTTP) checkValidators(resp *http.Response) error {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		if s.etag == "" {
+			s.etag = etag
+		} else if etag != s.etag {
+			return fmt.Errorf("etag %s changed to %s: %w", s.etag, etag, ErrChanged)
 		}
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		if s.lastModified == "" {
+			s.lastModified = lm
+		} else if lm != s.lastModified && s.etag == "" {
+			return fmt.Errorf("last modified %s changed to %s: %w", s.lastModified, lm, ErrChanged)
+		}
+	}
+	return nil
+}
 
-		n = min(contentLength, length)
-		bufN := min(int(n), len(buf))
-		copy(buf, s.last.Bytes())
+// StatusError is the error of an unsuccessful response. It matches
+// ErrNotFound and ErrForbidden with errors.Is for these statuses.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Body is the start of the response body, which often explains the
+	// error, such as the XML error documents of S3.
+	Body []byte
 
-		return bufN, err
+	// resp is passed to the Backoff
+	resp *http.Response
+}
+
+func (e *StatusError) Error() string {
+	msg := "unexpected response status: " + e.Status
+	if body := strings.Join(strings.Fields(string(e.Body)), " "); body != "" {
+		msg += ": " + body
 	}
+	return msg
+}
 
-	return 0, io.EOF
+// Is reports whether target is the sentinel error of the status.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
 }
 
+// defaultErrorBodyLimit is the ErrorBodyLimit used if it is zero.
+const defaultErrorBodyLimit = 1024
+
+// checkContentType checks the Content-Type of resp against
+// ExpectContentType, until a response matched.
+func (s *SeekingHTTP) checkContentType(resp *http.Response) error {
+	if s.ExpectContentType == "" || s.typeChecked {
+		return nil
+	}
+	ct := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(strings.ToLower(ct), strings.ToLower(s.ExpectContentType)) {
+		return fmt.Errorf("content type %q, expected %q: %w", ct, s.ExpectContentType, ErrContentType)
+	}
+	s.typeChecked = true
+	return nil
+}
+
+// statusErr returns the error for an unsuccessful response, reading the
+// start of its body up to ErrorBodyLimit.
+func (s *SeekingHTTP) statusErr(resp *http.Response) error {
+	limit := int64(s.ErrorBodyLimit)
+	if limit == 0 {
+		limit = defaultErrorBodyLimit
+	}
+	var body []byte
+	if limit > 0 {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, limit))
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body, resp: resp}
+}
+
+// Read reads up to len(buf) bytes at the current offset and advances it.
+// A partial read at the end of the object returns a nil error, the next
+// Read returns 0, io.EOF.
 func (s *SeekingHTTP) Read(buf []byte) (int, error) {
+	return s.ReadContext(context.Background(), buf)
+}
+
+// ReadContext is like Read but aborts the underlying requests when ctx is
+// done.
+func (s *SeekingHTTP) ReadContext(ctx context.Context, buf []byte) (int, error) {
 	if s.Logger != nil {
 		s.Logger.Debugf("got read len %v", len(buf))
 	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
 
-	n, err := s.ReadAt(buf, s.offset)
-	if err == nil {
-		s.offset += int64(n)
+	s.mu.Lock()
+	off := s.offset
+	s.mu.Unlock()
+	ctx, end := s.startSpan(ctx, SpanRead, off, int64(len(buf)))
+	n, err := s.readAtFull(ctx, buf, off)
+	s.mu.Lock()
+	s.offset = off + int64(n)
+	s.mu.Unlock()
+	if n != 0 && err == io.EOF {
+		err = nil
 	}
+	end(int64(n), err)
 
 	return n, err
 }
 
 // Seek sets the offset for the next Read.
 func (s *SeekingHTTP) Seek(offset int64, whence int) (int64, error) {
+	return s.SeekContext(context.Background(), offset, whence)
+}
+
+// SeekContext is like Seek but aborts the HEAD request issued to find the
+// size, if any, when ctx is done.
+func (s *SeekingHTTP) SeekContext(ctx context.Context, offset int64, whence int) (target int64, err error) {
 	if s.Logger != nil {
 		s.Logger.Debugf("got seek %v %v", offset, whence)
 	}
+	ctx, end := s.startSpan(ctx, SpanSeek, offset, 0)
+	defer func() { end(target, err) }()
+	return s.seek(ctx, offset, whence)
+}
 
+// seek is SeekContext without its span.
+func (s *SeekingHTTP) seek(ctx context.Context, offset int64, whence int) (int64, error) {
+	var target int64
+	var err error
 	switch whence {
 	case io.SeekStart:
-		s.offset = offset
+		target = offset
 	case io.SeekCurrent:
-		s.offset += offset
+		s.mu.Lock()
+		cur := s.offset
+		s.mu.Unlock()
+		target, err = addOffset(cur, offset)
+		if err != nil {
+			return 0, err
+		}
 	case io.SeekEnd:
-		var length int64
-		if s.KnownSize != nil {
-			length = *s.KnownSize
-		} else {
-			var err error
-			length, err = s.Size()
-			if err != nil {
-				return 0, err
-			}
+		length, err := s.SizeContext(ctx)
+		if err != nil {
+			return 0, err
 		}
 
-		s.offset = length + offset
-		if s.offset > length || s.offset < 0 {
+		target, err = addOffset(length, offset)
+		if err != nil {
+			return 0, err
+		}
+		if target > length || target < 0 {
 			return 0, io.EOF
 		}
 	default:
 		return 0, os.ErrInvalid
 	}
 
-	return s.offset, nil
+	if s.StrictSeek && whence != io.SeekEnd {
+		if target < 0 {
+			return 0, fmt.Errorf("seek to negative offset %d: %w", target, os.ErrInvalid)
+		}
+		length, err := s.SizeContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if target > length {
+			return 0, io.EOF
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropFarAhead(target)
+	s.offset = target
+	return target, nil
+}
+
+// Discard skips the next n bytes, like Read without copying them, and returns
+// the number of bytes skipped. Only the offset is moved, no request is issued,
+// so skipped ranges that are not cached are never loaded. If the size is known
+// and fewer than n bytes remain, it skips to the end and returns io.EOF.
+func (s *SeekingHTTP) Discard(n int64) (int64, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("discard of negative length %d: %w", n, os.ErrInvalid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.offset > 0 {
+		n = min(n, math.MaxInt64-s.offset)
+	}
+	var err error
+	if s.KnownSize != nil && n > *s.KnownSize-s.offset {
+		n = max(*s.KnownSize-s.offset, 0)
+		err = io.EOF
+	}
+	s.dropFarAhead(s.offset + n)
+	s.offset += n
+	return n, err
+}
+
+// addOffset returns a+b, or an error wrapping os.ErrInvalid if the sum
+// would overflow an int64.
+func addOffset(a, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, fmt.Errorf("offset %d%+d overflows: %w", a, b, os.ErrInvalid)
+	}
+	return sum, nil
 }
 
 // Size uses an HTTP HEAD to find out how many bytes are available in total.
 func (s *SeekingHTTP) Size() (int64, error) {
-	if s.KnownSize != nil {
-		return *s.KnownSize, nil
+	return s.SizeContext(context.Background())
+}
+
+// SizeContext is like Size but aborts the HEAD request when ctx is done.
+func (s *SeekingHTTP) SizeContext(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	closed, size, noHead := s.closed, s.KnownSize, s.noHead
+	s.mu.Unlock()
+	if closed {
+		return 0, os.ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
 
-	req, err := s.newReq()
+	if size != nil {
+		return *size, nil
+	}
+	if s.method() != "GET" || s.Fetcher != nil || noHead {
+		return s.probeSize(ctx)
+	}
+	return s.head(ctx)
+}
+
+// head learns the size of the object with a HEAD request, probing it if the
+// server does not send it.
+func (s *SeekingHTTP) head(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	length, probe, err := s.sendHead(ctx)
+	s.mu.Unlock()
+	if probe {
+		return s.probeSize(ctx)
+	}
+	return length, err
+}
+
+// sendHead sends the HEAD request of head, reporting whether the size has to
+// be probed instead. The lock must be held.
+func (s *SeekingHTTP) sendHead(ctx context.Context) (_ int64, probe bool, _ error) {
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+
+	req, err := s.newReq(ctx)
 	if err != nil {
-		return 0, err
+		return 0, false, err
 	}
 	req.Method = "HEAD"
 
-	resp, err := s.Client.Do(req)
+	release, err := s.limiter.acquire(ctx, s.background)
 	if err != nil {
-		return 0, err
+		return 0, false, err
+	}
+	resp, err := s.do(req)
+	// freed before falling back to probeSize, which takes a slot again
+	release()
+	if err != nil {
+		return 0, false, s.ctxErr(ctx, err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		if s.Logger != nil {
+			s.Logger.Debugf("HEAD not supported, probing the size")
+		}
+		s.noHead = true
+		return 0, true, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return 0, false, s.statusErr(resp)
+	}
+	if err := s.checkContentType(resp); err != nil {
+		return 0, false, err
+	}
+	if err := s.checkValidators(resp); err != nil {
+		return 0, false, err
 	}
 
 	if resp.ContentLength < 0 {
-		return 0, errors.New("no content length for Size()")
+		// some servers omit it, such as for chunked objects
+		if s.Logger != nil {
+			s.Logger.Debugf("no content length in HEAD response, probing the size")
+		}
+		return 0, true, nil
 	}
 
 	length := resp.ContentLength
 	if s.Logger != nil {
 		s.Logger.Debugf("url: %v, size %v", req.URL.String(), length)
 	}
-	if length < 0 {
-		return 0, errors.New("invalid negative content legnth returned")
+	s.KnownSize = &length
+	return length, false, nil
+}
+
+// Connect sets up the connection to the server ahead of the first read,
+// resolving redirects and paying DNS, TCP and TLS setup in a HEAD request
+// that also learns the size of the object. The client keeps the connection
+// for the next request if it pools connections. With a method other than
+// GET, or if the server does not answer HEAD requests, the first range is
+// loaded instead and with a Fetcher only the size is learned.
+func (s *SeekingHTTP) Connect(ctx context.Context) error {
+	s.mu.Lock()
+	closed, noHead := s.closed, s.noHead
+	s.mu.Unlock()
+	if closed {
+		return os.ErrClosed
 	}
 
-	s.KnownSize = &length
-	return resp.ContentLength, nil
+	var err error
+	switch {
+	case s.Fetcher != nil:
+		_, err = s.SizeContext(ctx)
+	case s.method() != "GET" || noHead:
+		_, err = s.probeSize(ctx)
+	default:
+		_, err = s.head(ctx)
+	}
+	return err
+}
+
+// probeSize learns the size of the object from the response to a range
+// request of its first byte.
+func (s *SeekingHTTP) probeSize(ctx context.Context) (int64, error) {
+	_, err := s.ReadAtContext(ctx, make([]byte, 1), 0)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.KnownSize == nil {
+		return s.countSize(ctx)
+	}
+	return *s.KnownSize, nil
+}
+
+// maxCountSize is the most bytes of a body countSize reads.
+var maxCountSize int64 = 64 << 20
+
+// countSize learns the size of the object by counting the bytes of a full
+// response, as a last resort for servers not sending it in any header.
+// Objects larger than maxCountSize are not counted. The lock must be held.
+func (s *SeekingHTTP) countSize(ctx context.Context) (int64, error) {
+	if s.Fetcher != nil {
+		return 0, fmt.Errorf("no size in response for Size(): %w", ErrUnknownSize)
+	}
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+
+	req, err := s.newReq(ctx)
+	if err != nil {
+		return 0, err
+	}
+	release, err := s.limiter.acquire(ctx, s.background)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	if s.Logger != nil {
+		s.Logger.Debugf("no size in range response, counting the bytes of the object")
+	}
+	s.stats.requests.Add(1)
+	resp, err := s.do(req)
+	if err != nil {
+		return 0, s.ctxErr(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, s.statusErr(resp)
+	}
+
+	size := resp.ContentLength
+	if size < 0 {
+		size, err = io.Copy(io.Discard, io.LimitReader(resp.Body, maxCountSize+1))
+		s.countEgress(size)
+		if err != nil {
+			return 0, s.ctxErr(ctx, err)
+		}
+		if size > maxCountSize {
+			return 0, fmt.Errorf("no size in response for Size() and the object is larger than %d bytes: %w", maxCountSize, ErrUnknownSize)
+		}
+	}
+	s.KnownSize = &size
+	return size, nil
+}
+
+// Close aborts any in-flight request, releases the cache and closes idle
+// connections of the client if it supports it. Reads after Close return
+// os.ErrClosed.
+func (s *SeekingHTTP) Close() error {
+	// cancel before locking so an in-flight read returns promptly
+	s.context()
+	s.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.private = nil
+
+	// The default client is shared with the rest of the process, leave its
+	// connections alone.
+	if c, ok := s.Client.(interface{ CloseIdleConnections() }); ok && s.Client != http.DefaultClient {
+		c.CloseIdleConnections()
+	}
+	return nil
 }
@@ -1,13 +1,24 @@
 package seekinghttp
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -35,17 +46,23 @@ func (c *MockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	start, _ := strconv.Atoi(y[0])
 	end, _ := strconv.Atoi(y[1])
 
-	if end > len(c.str) {
-		end = len(c.str)
+	if end >= len(c.str) {
+		end = len(c.str) - 1
 	}
 	if start > end {
-		start = end
+		// Create an empty mock response for ranges past the end.
+		c.numReq++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+		}, nil
 	}
 
 	// Create a mock response for testing purposes.
 	resp := &http.Response{
-		StatusCode: http.StatusOK,
-		Body:       io.NopCloser(bytes.NewReader([]byte(c.str[start:end]))),
+		StatusCode: http.StatusPartialContent,
+		Header:     http.Header{"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", start, end, len(c.str))}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(c.str[start : end+1]))),
 	}
 	c.numReq++
 	return resp, nil
@@ -67,7 +84,9 @@ func TestReadAt(t *testing.T) {
 	}{
 		{0, 10, 10, nil},
 		{10, 1, 1, nil},
-		{30, 30, 0, nil},
+		{20, 3, 3, nil},
+		{20, 5, 3, io.EOF},
+		{30, 30, 0, io.EOF},
 		{-1, 0, 0, io.EOF},
 	}
 
@@ -75,11 +94,12 @@ func TestReadAt(t *testing.T) {
 		buf := make([]byte, tc.bufSize)
 		n, err := s.ReadAt(buf, tc.offset)
 
-		assert.ErrorIs(t, tc.expectErr, err, "ReadAt(offset=%d, bufSize=%d) error = %v, expected error = %v", tc.offset, tc.bufSize, err, tc.expectErr)
+		assert.ErrorIs(t, err, tc.expectErr, "ReadAt(offset=%d, bufSize=%d) error = %v, expected error = %v", tc.offset, tc.bufSize, err, tc.expectErr)
 		assert.Equal(t, tc.expectLen, n, "ReadAt(offset=%d, bufSize=%d) len = %d, expected len = %d", tc.offset, tc.bufSize, n, tc.expectLen)
 	}
-	// expect 2 reads: one to load the cache, and one to look for bytes past the end for the seek to 30.
-	assert.Equal(t, 2, m.numReq)
+	// expect 1 read to load the cache, which also reports the size, so
+	// the read past the end for the seek to 30 needs no request.
+	assert.Equal(t, 1, m.numReq)
 }
 
 func TestReadNothing(t *testing.T) {
@@ -90,7 +110,7 @@ func TestReadNothing(t *testing.T) {
 
 	buf := make([]byte, 10)
 	n, err := s.Read(buf)
-	assert.ErrorIs(t, err, nil)
+	assert.ErrorIs(t, err, io.EOF)
 	assert.Equal(t, 0, n)
 }
 
@@ -114,8 +134,1332 @@ func TestReadOffEnd(t *testing.T) {
 	assert.Equal(t, int64(20), s.offset)
 
 	n, err = s.Read(buf)
-	assert.ErrorIs(t, err, nil)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, int64(20), s.offset)
+}
+
+func TestReadPartialEnd(t *testing.T) {
+	// Create a new SeekingHTTP instance with a mock HTTP client.
+	s := New("https://example.com")
+	s.Client = &MockHTTPClient{str: "0123456789abcde"}
+	s.Logger = &logger{t: t}
+
+	buf := make([]byte, 10)
+	n, err := s.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	// the final partial read returns the data, the next one io.EOF
+	n, err = s.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "abcde", string(buf[:n]))
+	assert.Equal(t, int64(15), s.offset)
+
+	n, err = s.Read(buf)
+	assert.ErrorIs(t, err, io.EOF)
 	assert.Equal(t, 0, n)
+
+	// ReadAt reports the short read with io.EOF
+	n, err = s.ReadAt(buf, 10)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 5, n)
+}
+
+func TestSizeWithoutContentLength(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var gets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			// no content length
+			return
+		}
+		gets = append(gets, r.Header.Get("Range"))
+		if r.URL.Path == "/probe" {
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+			return
+		}
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-0/*")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = io.WriteString(w, body[:1])
+			return
+		}
+		// chunked
+		_, _ = io.WriteString(w, body[:10])
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, body[10:])
+	}))
+	defer srv.Close()
+
+	// the size from the Content-Range of a range request
+	s := New(srv.URL + "/probe")
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+	assert.Equal(t, []string{"bytes=0-1048575"}, gets)
+
+	// counted as a last resort
+	gets = nil
+	s = New(srv.URL + "/count")
+	size, err = s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+	assert.Equal(t, []string{"bytes=0-1048575", ""}, gets)
+	assert.Equal(t, int64(len(body)+1), s.Egress())
+
+	// but only up to a bound
+	defer func(n int64) { maxCountSize = n }(maxCountSize)
+	maxCountSize = 10
+	_, err = New(srv.URL + "/count").Size()
+	assert.ErrorIs(t, err, ErrUnknownSize)
+	assert.ErrorContains(t, err, "larger than 10 bytes")
+}
+
+func TestUnexpectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	buf := make([]byte, 10)
+	_, err := s.ReadAt(buf, 0)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, io.EOF)
+	_, err = s.Size()
+	assert.Error(t, err)
+}
+
+func TestStatusErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/secret":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, "<Error>\n  <Code>AccessDenied</Code>\n</Error>\n")
+		case "/throttled":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, strings.Repeat("x", 4096))
+		}
+	}))
+	defer srv.Close()
+
+	buf := make([]byte, 10)
+
+	s := New(srv.URL + "/missing")
+	s.Logger = &logger{t: t}
+	_, err := s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Size()
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	s = New(srv.URL + "/secret")
+	s.Logger = &logger{t: t}
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = s.Size()
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	// the error body explains the status
+	_, err = s.ReadAt(buf, 0)
+	assert.EqualError(t, err, "unexpected response status: 403 Forbidden: <Error> <Code>AccessDenied</Code> </Error>")
+	var statusErr *StatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	}
+
+	_, err = New(srv.URL+"/throttled").ReadAt(buf, 0)
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+		assert.Len(t, statusErr.Body, defaultErrorBodyLimit)
+	}
+
+	// the limit is configurable and nothing more is downloaded
+	s = New(srv.URL + "/throttled")
+	s.ErrorBodyLimit = 10
+	_, err = s.ReadAt(buf, 0)
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, "xxxxxxxxxx", string(statusErr.Body))
+	}
+	s.ErrorBodyLimit = -1
+	_, err = s.ReadAt(buf, 0)
+	assert.EqualError(t, err, "unexpected response status: 503 Service Unavailable")
+
+	var read bytes.Buffer
+	s = NewWithClient("http://example.com/", clientFunc(func(req *http.Request) (*http.Response, error) {
+		body := iotest.OneByteReader(strings.NewReader(strings.Repeat("x", 4096)))
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Status:     "503 Service Unavailable",
+			Body:       io.NopCloser(io.TeeReader(body, &read)),
+		}, nil
+	}))
+	s.ErrorBodyLimit = 10
+	_, err = s.ReadAt(buf, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 10, read.Len())
+}
+
+func TestContentRangeValidation(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var contentRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentRange == "" {
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+			return
+		}
+		w.Header().Set("Content-Range", contentRange)
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, body[10:15])
+	}))
+	defer srv.Close()
+
+	buf := make([]byte, 5)
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	n, err := s.ReadAt(buf, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "abcde", string(buf))
+	if assert.NotNil(t, s.KnownSize) {
+		assert.Equal(t, int64(len(body)), *s.KnownSize)
+	}
+
+	for _, cr := range []string{
+		"bytes 9-13/20",  // off-by-one start
+		"bytes 0-4/20",   // ignored the lower bound
+		"bytes 10-13/20", // body longer than the range, short ones are resumed
+		"bytes 10-9/20",  // malformed
+	} {
+		contentRange = cr
+		s := New(srv.URL)
+		s.Logger = &logger{t: t}
+		_, err := s.ReadAt(buf, 10)
+		assert.Error(t, err, cr)
+		assert.Nil(t, s.KnownSize, cr)
+	}
+}
+
+// cappedRangeHandler serves ranges of body like a server that never returns
+// more than maxLen bytes in a single response.
+func cappedRangeHandler(body string, maxLen int64, numReq *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*numReq++
+		var start, end int64
+		_, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		if err != nil || start >= int64(len(body)) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		end = min(end, start+maxLen-1, int64(len(body))-1)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, body[start:end+1])
+	}
+}
+
+func TestShortPartialContent(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq int
+	srv := httptest.NewServer(cappedRangeHandler(body, 4, &numReq))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+
+	buf := make([]byte, 10)
+	n, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, "3456789abc", string(buf))
+	assert.Equal(t, 3, numReq)
+
+	// the tail of the object can only be partially filled
+	n, err = s.ReadAt(buf, 15)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "fghij", string(buf[:n]))
+	assert.Equal(t, 5, numReq)
+}
+
+// chunkedWriter drops the Content-Length and flushes every write, so the
+// response is sent chunked.
+type chunkedWriter struct {
+	http.ResponseWriter
+}
+
+func (w chunkedWriter) WriteHeader(code int) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w chunkedWriter) Write(p []byte) (int, error) {
+	w.Header().Del("Content-Length")
+	n, err := w.ResponseWriter.Write(p)
+	w.ResponseWriter.(http.Flusher).Flush()
+	return n, err
+}
+
+func TestChunkedPartialContent(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq int
+	capped := cappedRangeHandler(body, 4, &numReq)
+	var truncate int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if truncate > 0 {
+			// the chunked body ends before the Content-Range
+			truncate--
+			numReq++
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-3/%d", len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = chunkedWriter{w}.Write([]byte(body[:2]))
+			return
+		}
+		capped(chunkedWriter{w}, r)
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	buf := make([]byte, 10)
+	n, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "3456789abc", string(buf[:n]))
+	assert.Equal(t, 3, numReq)
+	if assert.NotNil(t, s.KnownSize) {
+		assert.Equal(t, int64(len(body)), *s.KnownSize)
+	}
+	n, err = s.ReadAt(buf, 15)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "fghij", string(buf[:n]))
+
+	// a truncated body is resumed
+	numReq = 0
+	truncate = 1
+	s = New(srv.URL)
+	s.MinFetch = 4
+	n, err = s.ReadAt(buf[:4], 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123", string(buf[:n]))
+	assert.Equal(t, 2, numReq)
+}
+
+func TestUnknownContentLength(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil || r.URL.Path == "/full" {
+			start, end = 0, len(body)-1
+		} else {
+			end = min(end, len(body)-1)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		// flushing before the end makes the response chunked
+		_, _ = io.WriteString(w, body[start:start+1])
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, body[start+1:end+1])
+	}))
+	defer srv.Close()
+
+	var anomalies []string
+	onAnomaly := func(a Anomaly) { anomalies = append(anomalies, a.Kind) }
+	buf := make([]byte, 5)
+
+	// the size is taken from the Content-Range
+	s := New(srv.URL)
+	s.MinFetch = 5
+	s.OnAnomaly = onAnomaly
+	n, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "34567", string(buf[:n]))
+	if assert.NotNil(t, s.KnownSize) {
+		assert.Equal(t, int64(len(body)), *s.KnownSize)
+	}
+
+	// a full response of the whole requested range is no anomaly
+	s = New(srv.URL + "/full")
+	s.OnAnomaly = onAnomaly
+	n, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "01234", string(buf[:n]))
+	if assert.NotNil(t, s.KnownSize) {
+		assert.Equal(t, int64(len(body)), *s.KnownSize)
+	}
+	assert.Empty(t, anomalies)
+
+	// but a longer one is
+	s = New(srv.URL + "/full")
+	s.MinFetch = 5
+	s.OnAnomaly = onAnomaly
+	n, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "01234", string(buf[:n]))
+	assert.Equal(t, []string{AnomalyFullResponse}, anomalies)
+}
+
+func TestOverlongResponses(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq int
+	ignoreRange := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		if ignoreRange {
+			_, _ = io.WriteString(w, body)
+			return
+		}
+		// round the range up to blocks of 8 bytes
+		var start, end int64
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		end = min((end/8+1)*8-1, int64(len(body))-1)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, body[start:end+1])
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	s.MinFetch = 0
+
+	buf := make([]byte, 4)
+	n, err := s.ReadAt(buf, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "5678", string(buf))
+
+	// served from the extra data
+	n, err = s.ReadAt(buf, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "1234", string(buf))
+	assert.Equal(t, 1, numReq)
+
+	ignoreRange = false
+	s = New(srv.URL)
+	s.Logger = &logger{t: t}
+	s.MinFetch = 0
+
+	n, err = s.ReadAt(buf, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "2345", string(buf))
+
+	n, err = s.ReadAt(buf, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "4567", string(buf))
+	assert.Equal(t, 2, numReq)
+}
+
+// clientFunc implements HttpClient with a function.
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestOverflow(t *testing.T) {
+	var rng string
+	s := New("https://example.com")
+	s.Logger = &logger{t: t}
+	s.Client = clientFunc(func(req *http.Request) (*http.Response, error) {
+		rng = req.Header.Get("Range")
+		return &http.Response{
+			StatusCode: http.StatusRequestedRangeNotSatisfiable,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+
+	buf := make([]byte, 10)
+	_, err := s.ReadAt(buf, math.MaxInt64-4)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "bytes=9223372036854775803-9223372036854775806", rng)
+
+	_, err = s.ReadAtWithLength(buf, 0, -1)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+
+	off, err := s.Seek(math.MaxInt64-1, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64-1), off)
+	_, err = s.Seek(2, io.SeekCurrent)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+	assert.Equal(t, int64(math.MaxInt64-1), s.offset)
+
+	size := int64(math.MaxInt64)
+	s.KnownSize = &size
+	_, err = s.Seek(1, io.SeekEnd)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+	size = 10
+	_, err = s.Seek(math.MinInt64, io.SeekEnd)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, int64(math.MaxInt64-1), s.offset)
+}
+
+func TestStrictSeek(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+
+	// lenient by default
+	off, err := s.Seek(-5, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-5), off)
+	assert.Nil(t, s.KnownSize)
+
+	s.StrictSeek = true
+	_, err = s.Seek(-5, io.SeekStart)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+	_, err = s.Seek(21, io.SeekStart)
+	assert.ErrorIs(t, err, io.EOF)
+
+	off, err = s.Seek(20, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), off)
+	_, err = s.Seek(1, io.SeekCurrent)
+	assert.ErrorIs(t, err, io.EOF)
+	off, err = s.Seek(-15, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), off)
+	_, err = s.Seek(-6, io.SeekCurrent)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+	assert.Equal(t, int64(5), s.offset)
+}
+
+func TestDiscard(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 4
+	n, err := s.Discard(8)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), n)
+	assert.Equal(t, 0, numReq)
+
+	buf := make([]byte, 2)
+	_, err = s.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "89", string(buf))
+	assert.Equal(t, 1, numReq)
+
+	// within the cached range and past the end once the size is known
+	n, err = s.Discard(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = s.Read(buf[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(buf[:1]))
+	assert.Equal(t, 1, numReq)
+
+	n, err = s.Discard(100)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, int64(8), n)
 	assert.Equal(t, int64(20), s.offset)
 
+	_, err = s.Discard(-1)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+}
+
+func TestTrustBodyLength(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		end = min(end, len(body)-1)
+		// the proxy reports wrong lengths in chunked responses
+		sent := end
+		if r.URL.Path == "/long" {
+			end = start + (end-start)/2
+		} else {
+			sent = start + (end-start)/2
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = chunkedWriter{w}.Write([]byte(body[start : sent+1]))
+	}))
+	defer srv.Close()
+
+	buf := make([]byte, 8)
+	s := New(srv.URL + "/long")
+	s.MinFetch = 8
+	_, err := s.ReadAt(buf, 0)
+	assert.ErrorContains(t, err, "read 8 bytes but the response range indicated 4")
+
+	s = New(srv.URL + "/long")
+	s.MinFetch = 8
+	s.TrustBodyLength = true
+	n, err := s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, body[:8], string(buf[:n]))
+	assert.Equal(t, int64(1), s.Stats().Requests)
+
+	// short bodies are retried as truncated unless trusted
+	s = New(srv.URL + "/short")
+	s.MinFetch = 8
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, int64(2), s.Stats().Retries)
+
+	s = New(srv.URL + "/short")
+	s.MinFetch = 8
+	s.TrustBodyLength = true
+	n, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, body[:8], string(buf[:n]))
+	assert.Zero(t, s.Stats().Retries)
+}
+
+func TestTruncatedBody(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq, truncate int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		var start, end int64
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		end = min(end, int64(len(body))-1)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if truncate > 0 {
+			// send only a few bytes, then close the connection
+			truncate--
+			end = start
+		}
+		_, _ = io.WriteString(w, body[start:end+1])
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+
+	// resumed until the requested range is complete
+	truncate = 2
+	buf := make([]byte, 8)
+	n, err := s.ReadAt(buf, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, n)
+	assert.Equal(t, "23456789", string(buf))
+	assert.Equal(t, 3, numReq)
+
+	// retries exhausted
+	s = New(srv.URL)
+	s.Logger = &logger{t: t}
+	numReq = 0
+	truncate = 10
+	_, err = io.ReadFull(s, buf)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, 1+s.MaxRetries, numReq)
+}
+
+func TestClose(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			close(block)
+			<-r.Context().Done()
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	buf := make([]byte, 5)
+	_, err := s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.NotNil(t, s.private)
+
+	assert.NoError(t, s.Close())
+	assert.Nil(t, s.private)
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, os.ErrClosed)
+	_, err = s.Read(buf)
+	assert.ErrorIs(t, err, os.ErrClosed)
+	s.KnownSize = nil
+	_, err = s.Size()
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.NoError(t, s.Close())
+
+	// Close aborts an in-flight read
+	s = New(srv.URL + "/block")
+	s.Logger = &logger{t: t}
+	go func() {
+		<-block
+		_ = s.Close()
+	}()
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestReadAtContextCancel(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(block)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	buf := make([]byte, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-block
+		cancel()
+	}()
+	_, err := s.ReadAtContext(ctx, buf, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// an already canceled context does not issue a request
+	_, err = s.ReadAtContext(ctx, buf, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.SizeContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReadTimeout(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq, stall atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq.Add(1)
+		if stall.Add(-1) >= 0 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	s.ReadTimeout = 50 * time.Millisecond
+
+	// the stalled request is retried
+	stall.Store(1)
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "01234", string(buf[:n]))
+	assert.Equal(t, int32(2), numReq.Load())
+
+	// retries exhausted
+	s = New(srv.URL)
+	s.Logger = &logger{t: t}
+	s.ReadTimeout = 50 * time.Millisecond
+	numReq.Store(0)
+	stall.Store(10)
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1+s.MaxRetries), numReq.Load())
+}
+
+func TestContextDeadline(t *testing.T) {
+	var numReq atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq.Add(1)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	s.ReadTimeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := s.WithContext(ctx)
+
+	// the caller's deadline applies and is not retried
+	buf := make([]byte, 5)
+	_, err := r.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), numReq.Load())
+
+	_, err = r.Read(buf)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = r.Seek(0, io.SeekEnd)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), numReq.Load())
+}
+
+func TestClone(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	buf := make([]byte, 5)
+	_, err := s.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `"v1"`, s.ETag())
+
+	// shares what was learned, but not the offset
+	c := s.Clone(true)
+	assert.Equal(t, int64(20), *c.KnownSize)
+	assert.Equal(t, `"v1"`, c.ETag())
+	assert.Equal(t, int64(0), c.offset)
+	_, err = c.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "01234", string(buf))
+	assert.Equal(t, 1, numReq)
+	assert.Same(t, s.Cache, c.Cache)
+
+	// a private cache
+	c = s.Clone(false)
+	_, err = c.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, numReq)
+}
+
+func TestCloneCopiesConfig(t *testing.T) {
+	// implementations of the interface fields
+	impls := map[reflect.Type]any{
+		reflect.TypeOf((*HttpClient)(nil)).Elem():    http.DefaultClient,
+		reflect.TypeOf((*Logger)(nil)).Elem():        &logger{t: t},
+		reflect.TypeOf((*RangeStrategy)(nil)).Elem(): QueryRange{},
+		reflect.TypeOf((*RangeFetcher)(nil)).Elem():  &testFetcher{},
+		reflect.TypeOf((*io.Writer)(nil)).Elem():     &bytes.Buffer{},
+		reflect.TypeOf((*BackoffPolicy)(nil)).Elem(): ExponentialBackoff{},
+	}
+
+	// Set every exported field, so a field missing from Clone is noticed.
+	s := &SeekingHTTP{}
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			f.SetInt(7)
+		case reflect.String:
+			f.SetString("x")
+		case reflect.Pointer:
+			f.Set(reflect.New(f.Type().Elem()))
+		case reflect.Map:
+			f.Set(reflect.MakeMap(f.Type()))
+		case reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 1, 1))
+		case reflect.Func:
+			f.Set(reflect.MakeFunc(f.Type(), func([]reflect.Value) []reflect.Value { return nil }))
+		case reflect.Interface:
+			impl, ok := impls[f.Type()]
+			if !ok {
+				t.Fatalf("no implementation of %v for %s", f.Type(), v.Type().Field(i).Name)
+			}
+			f.Set(reflect.ValueOf(impl))
+		default:
+			t.Fatalf("unhandled kind %v of %s", f.Kind(), v.Type().Field(i).Name)
+		}
+	}
+
+	c := reflect.ValueOf(s.Clone(true)).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).IsExported() {
+			assert.False(t, c.Field(i).IsZero(), "Clone does not copy %s", v.Type().Field(i).Name)
+		}
+	}
+}
+
+func TestNewFromRequest(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != "abc" || r.Header.Get("X-Tenant") != "t1" || r.URL.Query().Get("sig") != "s" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/obj?sig=s", nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-Tenant", "t1")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	s := NewFromRequest(req)
+	s.MinFetch = 0
+	assert.Equal(t, srv.URL+"/obj?sig=s", s.URL)
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(buf[:n]))
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+
+	// the template is not modified by the requests
+	assert.Empty(t, req.Header.Get("Range"))
+
+	req.Body = io.NopCloser(strings.NewReader("x"))
+	_, err = NewFromRequest(req).ReadAt(buf, 0)
+	assert.ErrorIs(t, err, os.ErrInvalid)
+}
+
+func TestUserAgent(t *testing.T) {
+	var ua atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua.Store(r.Header.Get("User-Agent"))
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	_, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultUserAgent, ua.Load())
+	assert.True(t, strings.HasPrefix(DefaultUserAgent, "seekinghttp/"))
+
+	s = New(srv.URL)
+	s.UserAgent = "app/1.0"
+	_, err = s.ReadAt(make([]byte, 1), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "app/1.0", ua.Load())
+
+	// the User-Agent of a template is kept
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	assert.NoError(t, err)
+	req.Header.Set("User-Agent", "template/2")
+	_, err = NewFromRequest(req).ReadAt(make([]byte, 1), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "template/2", ua.Load())
+}
+
+func TestCacheControl(t *testing.T) {
+	var hdr http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr = r.Header
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.CacheControl = "no-transform"
+	_, err := s.ReadAt(make([]byte, 1), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "no-transform", hdr.Get("Cache-Control"))
+	assert.Empty(t, hdr.Get("Pragma"))
+
+	s = New(srv.URL)
+	s.CacheControl = "no-transform, no-cache"
+	_, err = s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, "no-transform, no-cache", hdr.Get("Cache-Control"))
+	assert.Equal(t, "no-cache", hdr.Get("Pragma"))
+}
+
+func TestAccept(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/octet-stream" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"error":"not raw"}`))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Accept = "application/octet-stream"
+	buf := make([]byte, 4)
+	_, err := s.ReadAt(buf, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "2345", string(buf))
+}
+
+func TestExpectContentType(t *testing.T) {
+	const body = "PK\x03\x04 not really a zip"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = io.WriteString(w, "<html>please log in</html>")
+			return
+		}
+		w.Header().Set("Content-Type", "Application/Zip")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	buf := make([]byte, 4)
+	s := New(srv.URL)
+	s.ExpectContentType = "application/zip"
+	_, err := s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	_, err = s.Size()
+	assert.NoError(t, err)
+
+	s = New(srv.URL + "/login")
+	s.ExpectContentType = "application/zip"
+	_, err = s.ReadAt(buf, 0)
+	assert.ErrorIs(t, err, ErrContentType)
+	assert.ErrorContains(t, err, "text/html")
+	assert.Zero(t, s.cache().Size())
+	_, err = s.Size()
+	assert.ErrorIs(t, err, ErrContentType)
+}
+
+func TestQuery(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "/obj?key=a&shard=1")
+	s.MinFetch = 2
+	var n int
+	s.Query = func(q url.Values) {
+		n++
+		q.Set("key", "b")
+		q.Set("token", strconv.Itoa(n))
+	}
+	_, err := s.ReadAt(make([]byte, 2), 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 2), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key=b&shard=1&token=1", "key=b&shard=1&token=2"}, queries)
+	assert.Equal(t, srv.URL+"/obj?key=a&shard=1", s.URL)
+}
+
+func TestBasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user" || pass != "pa:ss" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	_, err := s.ReadAt(make([]byte, 1), 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+	s.SetBasicAuth("user", "pa:ss")
+	_, err = s.ReadAt(make([]byte, 1), 0)
+	assert.NoError(t, err)
+	size, err := s.Clone(false).Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), size)
+
+	f := NewFactory()
+	f.SetBasicAuth("user", "pa:ss")
+	_, err = f.Open(srv.URL).ReadAt(make([]byte, 1), 0)
+	assert.NoError(t, err)
+}
+
+func TestRequesterPays(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Request-Payer") != "requester" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	_, err := s.ReadAt(make([]byte, 1), 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+	s.SetRequesterPays()
+	_, err = s.ReadAt(make([]byte, 1), 0)
+	assert.NoError(t, err)
+
+	f := NewFactory()
+	f.SetRequesterPays()
+	_, err = f.Open(srv.URL).ReadAt(make([]byte, 1), 0)
+	assert.NoError(t, err)
+}
+
+func TestMethod(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var numReq atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq.Add(1)
+		q, _ := io.ReadAll(r.Body)
+		if r.Method != "POST" || string(q) != `{"id":1}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// ServeContent only serves ranges of GET requests
+		r.Method = "GET"
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 20
+	s.Method = "POST"
+	s.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`{"id":1}`)), nil
+	}
+
+	// the size is learned from the first range, not a HEAD request
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), size)
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "34567", string(buf[:n]))
+	n, err = s.ReadAt(buf, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(buf[:n]))
+	assert.Equal(t, int32(2), numReq.Load())
+}
+
+func TestETagChanged(t *testing.T) {
+	const body = "0123456789abcdefghij"
+	etag := `"v1"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Logger = &logger{t: t}
+	s.MinFetch = 0
+	buf := make([]byte, 5)
+	_, err := s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+
+	etag = `"v2"`
+	_, err = s.ReadAt(buf, 10)
+	assert.ErrorIs(t, err, ErrChanged)
+}
+
+func TestReaderAtConcurrent(t *testing.T) {
+	body := strings.Repeat("0123456789abcdefghij", 100)
+	var numReq atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq.Add(1)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 100
+	s.Cache = NewCache(int64(len(body)))
+	r := s.ReaderAt()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 10)
+			for off := int64(0); off < int64(len(body)); off += 10 {
+				n, err := r.ReadAt(buf, off)
+				assert.NoError(t, err)
+				assert.Equal(t, body[off:off+10], string(buf[:n]))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the cache is shared, most ranges are loaded once
+	assert.Less(t, int(numReq.Load()), 8*len(body)/100)
+	// and hits are served without cloning
+	assert.NotZero(t, r.(*readerAt).tmpl.Stats().CacheHits)
+	_, err := s.ReadAt(make([]byte, 10), 500)
+	assert.NoError(t, err)
+}
+
+func TestSection(t *testing.T) {
+	body := strings.Repeat("0123456789abcdefghij", 100)
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 200
+	sec := s.Section(105, 30)
+	assert.Equal(t, int64(30), sec.Size())
+
+	data, err := io.ReadAll(sec)
+	assert.NoError(t, err)
+	assert.Equal(t, body[105:135], string(data))
+
+	// the cache of s serves the section and the section does not move s
+	buf := make([]byte, 10)
+	n, err := sec.ReadAt(buf, 25)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, body[130:135], string(buf[:n]))
+	_, err = s.ReadAt(buf, 150)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numReq)
+	assert.Equal(t, int64(0), s.offset)
+}
+
+func TestSizeReaderAt(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, err := zw.Create("hello.txt")
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "hello world")
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(zipped.Bytes()))
+	}))
+	defer srv.Close()
+
+	r := NewSizeReaderAt(New(srv.URL))
+	assert.Equal(t, int64(zipped.Len()), r.Size())
+	assert.NoError(t, r.Err())
+	zr, err := zip.NewReader(r, r.Size())
+	if assert.NoError(t, err) && assert.Len(t, zr.File, 1) {
+		f, err := zr.File[0].Open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello world", string(data))
+	}
+
+	r = NewSizeReaderAt(New(srv.URL + "/missing"))
+	assert.Equal(t, int64(-1), r.Size())
+	assert.ErrorIs(t, r.Err(), ErrNotFound)
+}
+
+func TestDeduplicateLoads(t *testing.T) {
+	body := strings.Repeat("0123456789abcdefghij", 100)
+	var numReq atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq.Add(1)
+		<-release
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 100
+	s.Cache = NewCache(int64(len(body)))
+	r := s.ReaderAt()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 10)
+			n, err := r.ReadAt(buf, 500)
+			assert.NoError(t, err)
+			assert.Equal(t, body[500:510], string(buf[:n]))
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), numReq.Load())
+
+	// a canceled load does not fail the readers waiting for it
+	var stall atomic.Bool
+	stall.Store(true)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stall.CompareAndSwap(true, false) {
+			<-r.Context().Done()
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+	s = New(srv.URL)
+	s.Cache = NewCache(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := s.Clone(true)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := leader.ReadAtContext(ctx, make([]byte, 10), 0)
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	n, err := s.Clone(true).ReadAt(make([]byte, 10), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	assert.ErrorIs(t, <-errc, context.Canceled)
+}
+
+// panicFetcher panics once release is closed.
+type panicFetcher struct {
+	release chan struct{}
+}
+
+func (f *panicFetcher) FetchRange(ctx context.Context, off, length int64) ([]byte, int64, string, error) {
+	<-f.release
+	panic("fetcher bug")
+}
+
+func TestDeduplicateLoadsPanic(t *testing.T) {
+	// a load panicking fails the readers waiting for it
+	f := &panicFetcher{release: make(chan struct{})}
+	cache := NewCache(0)
+	open := func() *SeekingHTTP {
+		s := NewFromFetcher("test://obj", f)
+		s.Cache = cache
+		return s
+	}
+	recovered := make(chan any, 1)
+	go func() {
+		defer func() { recovered <- recover() }()
+		_, _ = open().ReadAt(make([]byte, 10), 0)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := open().ReadAt(make([]byte, 10), 0)
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(f.release)
+	assert.Equal(t, "fetcher bug", <-recovered)
+	assert.ErrorIs(t, <-errc, errLoadPanicked)
 }
@@ -0,0 +1,149 @@
+// Package sftpfetch implements a seekinghttp.RangeFetcher reading files from
+// SFTP servers, so the caching and seeking of seekinghttp can be used for
+// files on SFTP servers alongside HTTP sources.
+package sftpfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// File is the part of the remote file used by the Fetcher, implemented by
+// *sftp.File.
+type File interface {
+	io.ReaderAt
+	Stat() (os.FileInfo, error)
+}
+
+// Fetcher fetches ranges of a remote file with ReadAt. The reads are not
+// aborted by the context, the SFTP protocol has no cancellation.
+type Fetcher struct {
+	File File
+
+	size      int64
+	validator string
+}
+
+// _ is a type assertion
+var _ seekinghttp.RangeFetcher = (*Fetcher)(nil)
+
+// New initializes a Fetcher for f, learning its size and modification time.
+func New(f File) (*Fetcher, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return &Fetcher{
+		File:      f,
+		size:      fi.Size(),
+		validator: strconv.FormatInt(fi.ModTime().UnixNano(), 10) + "-" + strconv.FormatInt(fi.Size(), 10),
+	}, nil
+}
+
+// Open opens the file at path with client and returns a reader for it. The
+// file is closed with the client.
+func Open(client *sftp.Client, path string) (*seekinghttp.SeekingHTTP, error) {
+	f, err := client.Open(path)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	fetcher, err := New(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	u := url.URL{Scheme: "sftp", Path: path}
+	return seekinghttp.NewFromFetcher(u.String(), fetcher), nil
+}
+
+// Dial connects to the server of the sftp://[user@]host[:port]/path URL and
+// returns a reader for the file. The returned closer closes the connection.
+func Dial(rawURL string, config *ssh.ClientConfig) (*seekinghttp.SeekingHTTP, io.Closer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "sftp" || u.Host == "" || u.Path == "" {
+		return nil, nil, fmt.Errorf("invalid sftp url %q: expected sftp://host/path", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	if u.User != nil && config.User == "" {
+		cfg := *config
+		cfg.User = u.User.Username()
+		config = &cfg
+	}
+
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	s, err := Open(client, u.Path)
+	if err != nil {
+		_ = client.Close()
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	s.URL = rawURL
+	return s, closers{client, conn}, nil
+}
+
+// closers closes all of its closers in order.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var err error
+	for _, cl := range c {
+		if cErr := cl.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// FetchRange reads a range of the file.
+func (f *Fetcher) FetchRange(ctx context.Context, off, length int64) ([]byte, int64, string, error) {
+	if off >= f.size {
+		return nil, f.size, f.validator, io.EOF
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, f.size, f.validator, err
+	}
+	data := make([]byte, min(length, f.size-off))
+	n, err := f.File.ReadAt(data, off)
+	if err == io.EOF {
+		// the file was truncated, the next fetch returns io.EOF
+		err = nil
+	}
+	return data[:n], f.size, f.validator, mapErr(err)
+}
+
+// mapErr maps the errors of the server to the errors of seekinghttp.
+func mapErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%w: %w", err, seekinghttp.ErrNotFound)
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("%w: %w", err, seekinghttp.ErrForbidden)
+	}
+	return err
+}
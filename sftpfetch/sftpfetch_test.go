@@ -0,0 +1,47 @@
+package sftpfetch
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetcher(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	path := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+
+	// serve the local file system over a pipe
+	srvConn, clientConn := net.Pipe()
+	srv, err := sftp.NewServer(srvConn)
+	assert.NoError(t, err)
+	go func() { _ = srv.Serve() }()
+	defer srv.Close()
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	s, err := Open(client, filepath.ToSlash(path))
+	assert.NoError(t, err)
+	s.MinFetch = 0
+	buf := make([]byte, 5)
+	n, err := s.ReadAt(buf, 77)
+	assert.NoError(t, err)
+	assert.Equal(t, "78901", string(buf[:n]))
+	size, err := s.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+	n, err = s.ReadAt(buf, 97)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "789", string(buf[:n]))
+
+	_, err = Open(client, filepath.ToSlash(path)+".missing")
+	assert.ErrorIs(t, err, seekinghttp.ErrNotFound)
+}
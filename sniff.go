@@ -0,0 +1,33 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// sniffLen is the most bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// SniffContentType returns the content type of the object detected from its
+// first 512 bytes by http.DetectContentType, such as to pick a parser. The
+// bytes are read through the cache without moving the offset, and the
+// result is remembered.
+func (s *SeekingHTTP) SniffContentType() (string, error) {
+	return s.SniffContentTypeContext(context.Background())
+}
+
+// SniffContentTypeContext is like SniffContentType but aborts the
+// underlying requests when ctx is done.
+func (s *SeekingHTTP) SniffContentTypeContext(ctx context.Context) (string, error) {
+	if s.sniffed != "" {
+		return s.sniffed, nil
+	}
+	buf := make([]byte, sniffLen)
+	n, err := s.ReadAtContext(ctx, buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	s.sniffed = http.DetectContentType(buf[:n])
+	return s.sniffed, nil
+}
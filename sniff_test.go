@@ -0,0 +1,54 @@
+package seekinghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniffContentType(t *testing.T) {
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		// no Content-Type is sent
+		var body string
+		switch r.URL.Path {
+		case "/zip":
+			body = "PK\x03\x04" + strings.Repeat("\x00", 1000)
+		case "/empty":
+		default:
+			body = "%PDF-1.7\n" + strings.Repeat("x", 1000)
+		}
+		w.Header()["Content-Type"] = nil
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL + "/doc")
+	_, err := s.Seek(100, 0)
+	assert.NoError(t, err)
+	ct, err := s.SniffContentType()
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", ct)
+	assert.Equal(t, int64(100), s.offset)
+
+	// the result is remembered and the bytes are cached
+	ct, err = s.SniffContentType()
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", ct)
+	_, err = s.ReadAt(make([]byte, 10), 200)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numReq)
+
+	ct, err = New(srv.URL + "/zip").SniffContentType()
+	assert.NoError(t, err)
+	assert.Equal(t, "application/zip", ct)
+
+	ct, err = New(srv.URL + "/empty").SniffContentType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", ct)
+}
@@ -0,0 +1,73 @@
+package seekinghttp
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// DumpState writes the state of the reader in a human-readable form to w,
+// for bug reports and support bundles: the offset, the size and validators
+// learned, the readahead and the loads in flight, the counters, and the
+// segments of the cache. It may be called concurrently with a read, which
+// holds the lock of the reader guarding its state, so only the counters, the
+// loads in flight and the cache are written until the read returns.
+func (s *SeekingHTTP) DumpState(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "url: %s\n", s.URL)
+	if s.mu.TryLock() {
+		fmt.Fprintf(&b, "offset: %d\n", s.offset)
+		if s.KnownSize != nil {
+			fmt.Fprintf(&b, "size: %d\n", *s.KnownSize)
+		} else {
+			b.WriteString("size: unknown\n")
+		}
+		fmt.Fprintf(&b, "etag: %s\n", s.etag)
+		fmt.Fprintf(&b, "last-modified: %s\n", s.lastModified)
+		if s.closed {
+			b.WriteString("closed\n")
+		}
+		if ra := s.ahead; ra != nil {
+			fmt.Fprintf(&b, "readahead: (%v-%v)\n", ra.off, ra.off+ra.length)
+		}
+		s.mu.Unlock()
+	} else {
+		b.WriteString("read in progress\n")
+	}
+
+	st := s.Stats()
+	fmt.Fprintf(&b, "requests: %d, retries: %d, anomalies: %d\n", st.Requests, st.Retries, st.Anomalies)
+	fmt.Fprintf(&b, "downloaded: %d bytes, from cache: %d bytes, from network: %d bytes\n", st.BytesDownloaded, st.BytesFromCache, st.BytesFromNetwork)
+	fmt.Fprintf(&b, "cache hits: %d, misses: %d\n", st.CacheHits, st.CacheMisses)
+
+	cache := s.stats.cache.Load()
+	if cache == nil {
+		cache = s.Cache
+	}
+	if cache != nil {
+		flights := cache.flights.keys()
+		fmt.Fprintf(&b, "in flight: %d\n", len(flights))
+		for _, k := range flights {
+			fmt.Fprintf(&b, "  %s (%v-%v), %d wanted\n", k.key, k.off, k.off+k.length, k.want)
+		}
+		fmt.Fprintf(&b, "cache: %s\n", cache.segmentMap())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// keys returns the keys of the loads in flight, sorted.
+func (g *flightGroup) keys() []flightKey {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	keys := make([]flightKey, 0, len(g.calls))
+	for k := range g.calls {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b flightKey) int {
+		return cmp.Or(strings.Compare(a.key, b.key), cmp.Compare(a.off, b.off), cmp.Compare(a.length, b.length))
+	})
+	return keys
+}
@@ -0,0 +1,66 @@
+package seekinghttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpState(t *testing.T) {
+	arrived, release := make(chan struct{}, 1), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=50-59" {
+			arrived <- struct{}{}
+			<-release
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 10
+	s.Cache = NewCache(1024)
+	_, err := s.Read(make([]byte, 4))
+	assert.NoError(t, err)
+
+	var b strings.Builder
+	assert.NoError(t, s.DumpState(&b))
+	assert.Equal(t, "url: "+srv.URL+"\n"+
+		"offset: 4\n"+
+		"size: 100\n"+
+		"etag: \"v1\"\n"+
+		"last-modified: \n"+
+		"requests: 1, retries: 0, anomalies: 0\n"+
+		"downloaded: 10 bytes, from cache: 0 bytes, from network: 4 bytes\n"+
+		"cache hits: 0, misses: 1\n"+
+		"in flight: 0\n"+
+		"cache: 1 segments, 10 of 1024 bytes\n"+
+		"  "+srv.URL+" (0-10) 10 bytes, etag \"v1\"\n", b.String())
+
+	// during a read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := s.Seek(50, io.SeekStart)
+		assert.NoError(t, err)
+		_, err = s.Read(make([]byte, 4))
+		assert.NoError(t, err)
+	}()
+	<-arrived
+	b.Reset()
+	assert.NoError(t, s.DumpState(&b))
+	assert.Contains(t, b.String(), "read in progress\n")
+	assert.Contains(t, b.String(), "in flight: 1\n  "+srv.URL+" (50-60), 4 wanted\n")
+	assert.NotContains(t, b.String(), "offset")
+	close(release)
+	<-done
+	b.Reset()
+	assert.NoError(t, s.DumpState(&b))
+	assert.Contains(t, b.String(), "offset: 54\n")
+}
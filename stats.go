@@ -0,0 +1,139 @@
+package seekinghttp
+
+import "sync/atomic"
+
+// Stats is a snapshot of the counters of a reader.
+type Stats struct {
+	// Requests is the number of range requests issued, including retries.
+	Requests int64
+	// Retries is the number of retried requests.
+	Retries int64
+	// BytesDownloaded is the number of bytes loaded by the requests.
+	BytesDownloaded int64
+	// BytesFromCache is the number of bytes of reads served from the cache
+	// and BytesFromNetwork the number of bytes of reads that loaded their
+	// range, which BytesDownloaded exceeds by the bytes fetched ahead.
+	BytesFromCache   int64
+	BytesFromNetwork int64
+	// CacheHits and CacheMisses count the reads served from the cache and
+	// the reads that loaded their range.
+	CacheHits   int64
+	CacheMisses int64
+	// HitRatio is CacheHits / (CacheHits + CacheMisses), or zero without
+	// any reads.
+	HitRatio float64
+	// CacheSize is the current size of the cache used by the reader, which
+	// may be shared with other readers.
+	CacheSize int64
+	// Anomalies is the number of unexpected responses, see OnAnomaly.
+	Anomalies int64
+}
+
+// stats are the counters of a reader, which may be read concurrently.
+type stats struct {
+	requests   atomic.Int64
+	retries    atomic.Int64
+	downloaded atomic.Int64
+	fromCache  atomic.Int64
+	fromNet    atomic.Int64
+	hits       atomic.Int64
+	misses     atomic.Int64
+	anomalies  atomic.Int64
+
+	// egress is the total downloaded, which is not reset.
+	egress atomic.Int64
+
+	// cache is the cache last used.
+	cache atomic.Pointer[Cache]
+}
+
+// Stats returns a snapshot of the counters of s since it was created or
+// ResetStats was called. It may be called concurrently with reads.
+func (s *SeekingHTTP) Stats() Stats {
+	st := Stats{
+		Requests:         s.stats.requests.Load(),
+		Retries:          s.stats.retries.Load(),
+		BytesDownloaded:  s.stats.downloaded.Load(),
+		BytesFromCache:   s.stats.fromCache.Load(),
+		BytesFromNetwork: s.stats.fromNet.Load(),
+		CacheHits:        s.stats.hits.Load(),
+		CacheMisses:      s.stats.misses.Load(),
+		Anomalies:        s.stats.anomalies.Load(),
+	}
+	if reads := st.CacheHits + st.CacheMisses; reads != 0 {
+		st.HitRatio = float64(st.CacheHits) / float64(reads)
+	}
+	if c := s.stats.cache.Load(); c != nil {
+		st.CacheSize = c.Size()
+	}
+	return st
+}
+
+// ResetStats resets the counters returned by Stats to zero.
+func (s *SeekingHTTP) ResetStats() {
+	s.stats.requests.Store(0)
+	s.stats.retries.Store(0)
+	s.stats.downloaded.Store(0)
+	s.stats.fromCache.Store(0)
+	s.stats.fromNet.Store(0)
+	s.stats.hits.Store(0)
+	s.stats.misses.Store(0)
+	s.stats.anomalies.Store(0)
+}
+
+// CacheStats is a snapshot of the counters of a Cache, aggregating the reads
+// of all readers using it.
+type CacheStats struct {
+	// BytesFromCache and BytesFromNetwork are the sums of the Stats of the
+	// readers since the cache was created.
+	BytesFromCache   int64
+	BytesFromNetwork int64
+	// Size is the current size, see Cache.Size.
+	Size int64
+}
+
+// Stats returns a snapshot of the counters of c. It may be called
+// concurrently with reads.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		BytesFromCache:   c.fromCache.Load(),
+		BytesFromNetwork: c.fromNet.Load(),
+		Size:             c.Size(),
+	}
+}
+
+// served counts n bytes of a read served from the cache if cached, or else
+// by loading its range.
+func (s *SeekingHTTP) served(n int64, cached bool) {
+	c := s.stats.cache.Load()
+	if cached {
+		s.stats.fromCache.Add(n)
+		if c != nil {
+			c.fromCache.Add(n)
+		}
+		return
+	}
+	s.stats.fromNet.Add(n)
+	if c != nil {
+		c.fromNet.Add(n)
+	}
+}
+
+// Egress returns the total bytes downloaded by s since it was created.
+// Unlike Stats it is not reset by ResetStats.
+func (s *SeekingHTTP) Egress() int64 {
+	return s.stats.egress.Load()
+}
+
+// countEgress counts n bytes downloaded and calls OnEgress if the total
+// crossed a multiple of EgressThreshold.
+func (s *SeekingHTTP) countEgress(n int64) {
+	if n <= 0 {
+		return
+	}
+	s.stats.downloaded.Add(n)
+	total := s.stats.egress.Add(n)
+	if t := s.EgressThreshold; t > 0 && s.OnEgress != nil && total/t != (total-n)/t {
+		s.OnEgress(total)
+	}
+}
@@ -0,0 +1,84 @@
+package seekinghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	var truncate atomic.Bool
+	truncate.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if truncate.CompareAndSwap(true, false) {
+			w.Header().Set("Content-Range", "bytes 0-19/100")
+			w.Header().Set("Content-Length", "20")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(body[:10]))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 20
+	s.Cache = NewCache(1024)
+	_, err := s.ReadAt(make([]byte, 20), 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 5), 10)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 5), 3)
+	assert.NoError(t, err)
+
+	assert.Equal(t, Stats{
+		Requests:         2,
+		Retries:          1,
+		BytesDownloaded:  20,
+		BytesFromCache:   10,
+		BytesFromNetwork: 20,
+		CacheHits:        2,
+		CacheMisses:      1,
+		HitRatio:         2.0 / 3.0,
+		CacheSize:        20,
+	}, s.Stats())
+
+	// readers sharing the cache are aggregated
+	c := s.Clone(true)
+	_, err = c.ReadAt(make([]byte, 4), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, CacheStats{BytesFromCache: 14, BytesFromNetwork: 20, Size: 20}, s.Cache.Stats())
+
+	s.ResetStats()
+	assert.Equal(t, Stats{CacheSize: 20}, s.Stats())
+}
+
+func TestEgress(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.MinFetch = 15
+	s.EgressThreshold = 20
+	var totals []int64
+	s.OnEgress = func(total int64) { totals = append(totals, total) }
+	for off := int64(0); off < 90; off += 15 {
+		_, err := s.ReadAt(make([]byte, 15), off)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []int64{30, 45, 60, 90}, totals)
+	assert.EqualValues(t, 90, s.Egress())
+
+	s.ResetStats()
+	assert.EqualValues(t, 90, s.Egress())
+	assert.EqualValues(t, 0, s.Stats().BytesDownloaded)
+}
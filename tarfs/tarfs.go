@@ -0,0 +1,237 @@
+// Package tarfs implements an fs.FS for remote uncompressed tar archives. The
+// headers are scanned once into an index of the entries, skipping their data
+// with seeks, after which every file is read by targeted range requests.
+package tarfs
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/paralin/seekinghttp"
+)
+
+// Entry is an entry of the index of an archive.
+type Entry struct {
+	Header *tar.Header
+	// Offset is the offset of the data of the entry in the archive.
+	Offset int64
+}
+
+// BuildIndex scans the headers of the tar archive read by s, returning its
+// entries in order. The data of the entries is skipped by seeking, so only
+// the ranges around the headers are loaded; a small MinFetch, such as
+// 64 KiB, suits archives of large files. The offset of s is moved.
+func BuildIndex(ctx context.Context, s *seekinghttp.SeekingHTTP) ([]Entry, error) {
+	r := s.WithContext(ctx)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(r)
+	var entries []Entry
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar header: %w", err)
+		}
+		off, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Header: hdr, Offset: off})
+	}
+}
+
+// FS is a tar archive indexed by BuildIndex. The files are read with
+// s.ReaderAt, so they share the cache of s and may be read concurrently.
+//
+// Hard links read the data of their target, links to missing targets are
+// skipped. Symbolic links are listed but
+// read as empty files, and sparse files cannot be opened.
+type FS struct {
+	r     io.ReaderAt
+	nodes map[string]*node
+}
+
+// node is a file or directory of the archive.
+type node struct {
+	// entry holds the data of a file, the target of a hard link.
+	entry *Entry
+	info  fs.FileInfo
+	// children are the entries of a directory by name.
+	children map[string]fs.DirEntry
+}
+
+// _ is a type assertion
+var (
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New indexes the tar archive read by s with BuildIndex and returns its FS.
+func New(ctx context.Context, s *seekinghttp.SeekingHTTP) (*FS, error) {
+	entries, err := BuildIndex(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromIndex(s, entries), nil
+}
+
+// NewFromIndex returns the FS of the tar archive read by s from its index,
+// such as one built by an earlier process and stored, without reading the
+// headers again. Entries with invalid names are skipped and later entries
+// replace earlier ones of the same name like on extraction. Directories
+// missing from the archive are implied by the entries within.
+func NewFromIndex(s *seekinghttp.SeekingHTTP, entries []Entry) *FS {
+	f := &FS{r: s.ReaderAt(), nodes: make(map[string]*node)}
+	f.nodes["."] = &node{info: dirHeader(".").FileInfo(), children: make(map[string]fs.DirEntry)}
+	for i := range entries {
+		e := &entries[i]
+		name := cleanName(e.Header.Name)
+		if name == "" {
+			continue
+		}
+		n := &node{entry: e, info: e.Header.FileInfo()}
+		switch e.Header.Typeflag {
+		case tar.TypeLink:
+			// the target precedes the link
+			target := f.nodes[cleanName(e.Header.Linkname)]
+			if target == nil || target.entry == nil {
+				continue
+			}
+			hdr := *e.Header
+			hdr.Typeflag, hdr.Size = target.entry.Header.Typeflag, target.entry.Header.Size
+			n.entry, n.info = target.entry, hdr.FileInfo()
+		case tar.TypeDir:
+			n.entry = nil
+			n.children = make(map[string]fs.DirEntry)
+			if old := f.nodes[name]; old != nil && old.children != nil {
+				n.children = old.children
+			}
+		}
+		f.nodes[name] = n
+		f.parent(name).children[path.Base(name)] = fs.FileInfoToDirEntry(n.info)
+	}
+	return f
+}
+
+// parent returns the directory holding name, creating it and its parents if
+// missing.
+func (f *FS) parent(name string) *node {
+	dir := path.Dir(name)
+	if n := f.nodes[dir]; n != nil && n.children != nil {
+		return n
+	}
+	n := &node{info: dirHeader(dir).FileInfo(), children: make(map[string]fs.DirEntry)}
+	f.nodes[dir] = n
+	f.parent(dir).children[path.Base(dir)] = fs.FileInfoToDirEntry(n.info)
+	return n
+}
+
+// dirHeader returns the header of a directory missing from the archive.
+func dirHeader(name string) *tar.Header {
+	return &tar.Header{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0o555}
+}
+
+// cleanName returns the name of an entry as a valid fs.FS path, or an empty
+// string if it has none.
+func cleanName(name string) string {
+	name = strings.TrimSuffix(path.Clean("/"+name), "/")
+	name = strings.TrimPrefix(name, "/")
+	if !fs.ValidPath(name) || name == "." {
+		return ""
+	}
+	return name
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	n, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.children != nil {
+		entries, _ := f.ReadDir(name)
+		return seekinghttp.NewDir(n.info, entries), nil
+	}
+
+	e := n.entry
+	if sparse(e.Header) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+	var size int64
+	if e.Header.Typeflag == tar.TypeReg {
+		size = e.Header.Size
+	}
+	return &file{SectionReader: io.NewSectionReader(f.r, e.Offset, size), info: n.info}, nil
+}
+
+// sparse reports whether hdr is of a sparse file, whose data is not stored
+// contiguously.
+func sparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// Stat returns the info of the named file from its header.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info, nil
+}
+
+// ReadDir lists the named directory, sorted by file name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, e := range n.children {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// lookup returns the node of the named file for the operation op.
+func (f *FS) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n := f.nodes[name]
+	if n == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+// file is an open file of the archive.
+type file struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
@@ -0,0 +1,104 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/paralin/seekinghttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarFS(t *testing.T) {
+	large := strings.Repeat("0123456789abcdef", 64<<10)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range []struct {
+		hdr  tar.Header
+		body string
+	}{
+		{tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}, ""},
+		{tar.Header{Name: "dir/large.bin", Mode: 0o644}, large},
+		{tar.Header{Name: "./small.txt", Mode: 0o644}, "small"},
+		{tar.Header{Name: "implied/sub/file.txt", Mode: 0o644}, "implied"},
+		{tar.Header{Name: "link.txt", Typeflag: tar.TypeLink, Linkname: "small.txt"}, ""},
+		{tar.Header{Name: "../escape.txt", Mode: 0o644}, "escape"},
+		{tar.Header{Name: "/", Typeflag: tar.TypeDir}, ""},
+	} {
+		e.hdr.Size = int64(len(e.body))
+		e.hdr.ModTime = time.Unix(1700000000, 0)
+		assert.NoError(t, tw.WriteHeader(&e.hdr))
+		_, err := io.WriteString(tw, e.body)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	archive := buf.Bytes()
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+	}))
+	defer srv.Close()
+
+	s := seekinghttp.New(srv.URL)
+	s.MinFetch = 4096
+	s.Cache = seekinghttp.NewCache(1 << 20)
+	fsys, err := New(context.Background(), s)
+	assert.NoError(t, err)
+	// the header of the large file, its last byte with the following headers
+	// and the end of the archive, without its data
+	assert.Len(t, ranges, 3)
+
+	assert.NoError(t, fstest.TestFS(fsys, "dir/large.bin", "small.txt", "implied/sub/file.txt", "link.txt", "escape.txt"))
+
+	ranges = nil
+	data, err := fs.ReadFile(fsys, "dir/large.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, large, string(data))
+	data, err = fs.ReadFile(fsys, "link.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "small", string(data))
+	info, err := fs.Stat(fsys, "link.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size())
+
+	f, err := fsys.Open("dir/large.bin")
+	assert.NoError(t, err)
+	p := make([]byte, 16)
+	_, err = f.(io.ReaderAt).ReadAt(p, 512<<10)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(p))
+	_, err = f.(io.Seeker).Seek(-4, io.SeekEnd)
+	assert.NoError(t, err)
+	_, err = io.ReadFull(f, p[:4])
+	assert.NoError(t, err)
+	assert.Equal(t, "cdef", string(p[:4]))
+	assert.NoError(t, f.Close())
+
+	// the index may be stored and reused without scanning again
+	entries, err := BuildIndex(context.Background(), s)
+	assert.NoError(t, err)
+	stored, err := json.Marshal(entries)
+	assert.NoError(t, err)
+	var loaded []Entry
+	assert.NoError(t, json.Unmarshal(stored, &loaded))
+	ranges = nil
+	fsys = NewFromIndex(seekinghttp.New(srv.URL), loaded)
+	data, err = fs.ReadFile(fsys, "implied/sub/file.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "implied", string(data))
+	assert.Len(t, ranges, 1)
+
+	_, err = fsys.Open("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,109 @@
+package seekinghttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Request stages reported by a timing trace.
+const (
+	StageDNS       = "dns"
+	StageConnect   = "connect"
+	StageTLS       = "tls"
+	StageFirstByte = "first-byte"
+	// StageRequest is the whole request including reading the body.
+	StageRequest = "request"
+)
+
+// Operations of the spans started by StartSpan.
+const (
+	SpanRead   = "read"
+	SpanReadAt = "read-at"
+	SpanSeek   = "seek"
+	SpanFetch  = "fetch"
+)
+
+// startSpan starts the span of op with StartSpan, if set.
+func (s *SeekingHTTP) startSpan(ctx context.Context, op string, off, length int64) (context.Context, func(n int64, err error)) {
+	if s.StartSpan == nil {
+		return ctx, func(int64, error) {}
+	}
+	return s.StartSpan(ctx, op, off, length)
+}
+
+// traceContext returns ctx with the traces of the request of the range at
+// off attached.
+func (s *SeekingHTTP) traceContext(ctx context.Context, off, length int64) context.Context {
+	if s.Trace != nil {
+		if t := s.Trace(off, length); t != nil {
+			ctx = httptrace.WithClientTrace(ctx, t)
+		}
+	}
+	if s.ObserveLatency != nil {
+		ctx = httptrace.WithClientTrace(ctx, timingTrace(s.ObserveLatency))
+	}
+	if s.TraceTimings && s.Logger != nil {
+		logger := s.Logger
+		ctx = httptrace.WithClientTrace(ctx, timingTrace(func(stage string, d time.Duration) {
+			logger.Debugf("range (%v-%v): %s took %v", off, off+length, stage, d)
+		}))
+	}
+	return ctx
+}
+
+// timingTrace returns a trace calling report with the duration of every
+// stage of a request. The time to the first byte is measured from the start
+// of the request, the others from the start of their stage. The connections
+// of a dual-stack dial are raced in parallel, so the starts are kept by
+// address under a lock.
+func timingTrace(report func(stage string, d time.Duration)) *httptrace.ClientTrace {
+	start := time.Now()
+	var mu sync.Mutex
+	var dnsStart, tlsStart time.Time
+	connectStart := make(map[string]time.Time)
+	since := func(t *time.Time) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Since(*t)
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			report(StageDNS, since(&dnsStart))
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connectStart[network+" "+addr] = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			t := connectStart[network+" "+addr]
+			delete(connectStart, network+" "+addr)
+			mu.Unlock()
+			if err == nil {
+				report(StageConnect, time.Since(t))
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				report(StageTLS, since(&tlsStart))
+			}
+		},
+		GotFirstResponseByte: func() {
+			report(StageFirstByte, time.Since(start))
+		},
+	}
+}
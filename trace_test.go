@@ -0,0 +1,146 @@
+package seekinghttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var ranges []int64
+	var stages []string
+	s := NewWithClient(srv.URL, &http.Client{Transport: &http.Transport{}})
+	s.MinFetch = 0
+	s.Trace = func(off, length int64) *httptrace.ClientTrace {
+		mu.Lock()
+		ranges = append(ranges, off, length)
+		mu.Unlock()
+		return timingTrace(func(stage string, d time.Duration) {
+			mu.Lock()
+			stages = append(stages, stage)
+			mu.Unlock()
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+		})
+	}
+	s.TraceTimings = true
+	s.Logger = &logger{t: t}
+
+	_, err := s.ReadAt(make([]byte, 3), 2)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 3), 6)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int64{2, 3, 6, 3}, ranges)
+	// the second request reuses the connection
+	assert.Equal(t, []string{StageConnect, StageFirstByte, StageFirstByte}, stages)
+}
+
+func TestTimingTraceParallelDials(t *testing.T) {
+	var mu sync.Mutex
+	var connects []time.Duration
+	trace := timingTrace(func(stage string, d time.Duration) {
+		mu.Lock()
+		connects = append(connects, d)
+		mu.Unlock()
+	})
+
+	// the dials of both address families overlap
+	trace.ConnectStart("tcp", "[::1]:80")
+	time.Sleep(20 * time.Millisecond)
+	var wg sync.WaitGroup
+	wg.Go(func() { trace.ConnectStart("tcp", "127.0.0.1:80") })
+	wg.Go(func() { trace.ConnectDone("tcp", "[::1]:80", nil) })
+	wg.Wait()
+	trace.ConnectDone("tcp", "127.0.0.1:80", nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// each measured from the start of its own dial
+	if assert.Len(t, connects, 2) {
+		assert.GreaterOrEqual(t, connects[0], 20*time.Millisecond)
+		assert.Less(t, connects[1], 20*time.Millisecond)
+	}
+}
+
+func TestObserveLatency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	stages := make(map[string]int)
+	s := NewWithClient(srv.URL, &http.Client{Transport: &http.Transport{}})
+	s.MinFetch = 0
+	s.ObserveLatency = func(stage string, d time.Duration) {
+		mu.Lock()
+		stages[stage]++
+		mu.Unlock()
+	}
+	_, err := s.ReadAt(make([]byte, 3), 2)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 3), 6)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{StageConnect: 1, StageFirstByte: 2, StageRequest: 2}, stages)
+}
+
+func TestStartSpan(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	type parentKey struct{}
+	var spans []string
+	s := New(srv.URL)
+	s.MinFetch = 4
+	s.StartSpan = func(ctx context.Context, op string, off, length int64) (context.Context, func(int64, error)) {
+		name := fmt.Sprintf("%s(%d,%d)", op, off, length)
+		if parent, ok := ctx.Value(parentKey{}).(string); ok {
+			name = parent + "/" + name
+		}
+		return context.WithValue(ctx, parentKey{}, name), func(n int64, err error) {
+			spans = append(spans, fmt.Sprintf("%s=%d,%v", name, n, err))
+		}
+	}
+
+	buf := make([]byte, 2)
+	_, err := s.Read(buf)
+	assert.NoError(t, err)
+	_, err = s.Read(buf)
+	assert.NoError(t, err)
+	_, err = s.Seek(-2, io.SeekEnd)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(buf, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"read(0,2)/fetch(0,4)=4,<nil>",
+		"read(0,2)=2,<nil>",
+		"read(2,2)=2,<nil>",
+		"seek(-2,0)=8,<nil>",
+		"read-at(8,2)/fetch(8,2)=2,<nil>",
+		"read-at(8,2)=2,<nil>",
+	}, spans)
+}
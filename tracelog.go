@@ -0,0 +1,71 @@
+package seekinghttp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TraceLevel is the verbosity of the traces logged to the Logger, see
+// SetTraceLevel.
+type TraceLevel int32
+
+const (
+	// TraceOff logs no traces.
+	TraceOff TraceLevel = iota
+	// TracePlan logs the decisions planning the range loaded by every miss:
+	// the fetch length, alignment, hints and readahead.
+	TracePlan
+	// TraceCache also logs the map of all segments of the cache on every
+	// read.
+	TraceCache
+)
+
+// SetTraceLevel sets the verbosity of the traces logged at debug level to the
+// Logger, for debugging pathological access patterns. Unlike the fields of
+// the reader it may be called at any time from any goroutine, such as from a
+// debug endpoint of a running service, and takes effect with the next read.
+func (s *SeekingHTTP) SetTraceLevel(level TraceLevel) {
+	s.traceLevel.Store(int32(level))
+}
+
+// tracing returns whether traces of level are logged.
+func (s *SeekingHTTP) tracing(level TraceLevel) bool {
+	return s.Logger != nil && TraceLevel(s.traceLevel.Load()) >= level
+}
+
+// tracef logs a trace of level.
+func (s *SeekingHTTP) tracef(level TraceLevel, format string, args ...any) {
+	if s.tracing(level) {
+		s.Logger.Debugf("trace: "+format, args...)
+	}
+}
+
+// segmentMap describes the segments of c in least to most recently used
+// order, one per line.
+func (c *Cache) segmentMap() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d segments, %d of %d bytes", len(c.segments), c.size, c.maxBytes)
+	now := time.Now()
+	for _, g := range c.segments {
+		fmt.Fprintf(&b, "\n  %s (%v-%v) %d bytes", g.key, g.off, g.end(), g.len())
+		if g.packed != nil {
+			fmt.Fprintf(&b, ", %d stored", g.stored())
+		}
+		if g.tag != "" {
+			fmt.Fprintf(&b, ", tag %s", g.tag)
+		}
+		if g.etag != "" {
+			fmt.Fprintf(&b, ", etag %s", g.etag)
+		}
+		if g.pinned {
+			b.WriteString(", pinned")
+		}
+		if g.stale(now) {
+			b.WriteString(", expired")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package seekinghttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// traceLogger records the traces logged.
+type traceLogger struct {
+	traces []string
+}
+
+func (l *traceLogger) Infof(format string, args ...interface{}) {}
+
+func (l *traceLogger) Debugf(format string, args ...interface{}) {
+	if msg := fmt.Sprintf(format, args...); strings.HasPrefix(msg, "trace: ") {
+		l.traces = append(l.traces, strings.TrimPrefix(msg, "trace: "))
+	}
+}
+
+func TestSetTraceLevel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+
+	l := &traceLogger{}
+	s := New(srv.URL)
+	s.Logger = l
+	s.MinFetch = 10
+	s.AlignFetch = 8
+	s.Cache = NewCache(1024)
+	buf := make([]byte, 2)
+	_, err := s.ReadAt(buf, 4)
+	assert.NoError(t, err)
+	assert.Empty(t, l.traces)
+
+	s.SetTraceLevel(TracePlan)
+	_, err = s.ReadAt(buf, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"miss (50-52): fetch length 10, sequential false",
+		"aligned to 8: (48-64)",
+		"loading (48-64)",
+	}, l.traces)
+
+	l.traces = nil
+	s.SetTraceLevel(TraceCache)
+	_, err = s.ReadAt(buf, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"read (50-52), cache 2 segments, 32 of 1024 bytes\n" +
+			"  " + srv.URL + ` (0-16) 16 bytes, etag "v1"` + "\n" +
+			"  " + srv.URL + ` (48-64) 16 bytes, etag "v1"`,
+	}, l.traces)
+
+	l.traces = nil
+	s.SetTraceLevel(TraceOff)
+	_, err = s.ReadAt(buf, 80)
+	assert.NoError(t, err)
+	assert.Empty(t, l.traces)
+}
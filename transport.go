@@ -0,0 +1,158 @@
+package seekinghttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// unixScheme is the scheme of URLs of HTTP servers listening on a unix
+// socket. The host is the escaped path of the socket, as in
+// http+unix://%2Frun%2Fapp.sock/path.
+const unixScheme = "http+unix"
+
+// NewWithTLSConfig initializes a SeekingHTTP for the given URL with a client
+// using cfg for TLS connections, such as for custom CAs, client certificates
+// or a ServerName override. The TLS config is cloned.
+func NewWithTLSConfig(url string, cfg *tls.Config) *SeekingHTTP {
+	return NewWithClient(url, &http.Client{Transport: newTransport(cfg)})
+}
+
+// TransportOptions are the knobs of a transport for many small range
+// requests, see DefaultTransportOptions. Zero durations and limits disable
+// the timeout or limit.
+type TransportOptions struct {
+	// TLSConfig is cloned for the TLS connections if set.
+	TLSConfig *tls.Config
+	// DialTimeout and KeepAlive configure the TCP connections.
+	DialTimeout time.Duration
+	KeepAlive   time.Duration
+	// TLSHandshakeTimeout limits the TLS handshakes.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits the wait for the response headers.
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout closes idle connections after this long.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is the most idle connections kept per host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per host.
+	MaxConnsPerHost int
+	// TLSSessionCacheSize is the number of TLS sessions cached for
+	// resumption, unless TLSConfig has a ClientSessionCache. Zero disables
+	// resumption.
+	TLSSessionCacheSize int
+	// ForceAttemptHTTP2 attempts HTTP/2, which multiplexes the requests on
+	// a single connection.
+	ForceAttemptHTTP2 bool
+}
+
+// DefaultTransportOptions returns the options of NewWithTransportOptions
+// tuned for range requests.
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		DialTimeout:         10 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConnsPerHost: 16,
+		TLSSessionCacheSize: 64,
+		ForceAttemptHTTP2:   true,
+	}
+}
+
+// NewWithTransportOptions initializes a SeekingHTTP for the given URL with a
+// client configured by opts, see NewClient.
+func NewWithTransportOptions(url string, opts TransportOptions) *SeekingHTTP {
+	return NewWithClient(url, NewClient(opts))
+}
+
+// NewClient returns a client with a transport configured by opts, which may
+// be shared by readers, such as in a Factory.
+func NewClient(opts TransportOptions) *http.Client {
+	t := newTransport(nil)
+	d := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: opts.KeepAlive}
+	t.DialContext = d.DialContext
+	t.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	t.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	t.IdleConnTimeout = opts.IdleConnTimeout
+	t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = opts.MaxConnsPerHost
+	t.ForceAttemptHTTP2 = opts.ForceAttemptHTTP2
+
+	cfg := &tls.Config{}
+	if opts.TLSConfig != nil {
+		cfg = opts.TLSConfig.Clone()
+	}
+	if cfg.ClientSessionCache == nil && opts.TLSSessionCacheSize > 0 {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(opts.TLSSessionCacheSize)
+	}
+	t.TLSClientConfig = cfg
+	return &http.Client{Transport: t}
+}
+
+// newTransport returns a transport with the defaults of http.DefaultTransport
+// and the TLS config cfg.
+func newTransport(cfg *tls.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg != nil {
+		t.TLSClientConfig = cfg.Clone()
+	}
+	// readers issue many requests to the same host
+	t.MaxIdleConnsPerHost = 16
+	return t
+}
+
+// newUnixTransport returns a transport connecting to the unix socket at path
+// for every request.
+func newUnixTransport(path string) *http.Transport {
+	t := newTransport(nil)
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+	return t
+}
+
+// splitUnixURL splits a http+unix URL into the path of the socket and the URL
+// of the request sent over it. ok is false if rawURL is not a http+unix URL.
+func splitUnixURL(rawURL string) (socket, reqURL string, ok bool, err error) {
+	rest, ok := strings.CutPrefix(rawURL, unixScheme+"://")
+	if !ok {
+		return "", "", false, nil
+	}
+	host, path, _ := strings.Cut(rest, "/")
+	socket, err = url.PathUnescape(host)
+	if err == nil && socket == "" {
+		err = fmt.Errorf("missing socket path in %q", rawURL)
+	}
+	// the socket connection is dialed by the transport
+	return socket, "http://localhost/" + path, true, err
+}
+
+// parseURL parses the URL of the requests.
+func parseURL(rawURL string) (*url.URL, error) {
+	_, reqURL, ok, err := splitUnixURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		rawURL = reqURL
+	}
+	return url.Parse(rawURL)
+}
+
+// CloseIdleConnections closes the idle connections of the Client if it
+// supports it, such as *http.Client, to release the sockets after a batch of
+// reads. A client pools the connections to all hosts, so the idle
+// connections of a shared client to other hosts are closed too.
+func (s *SeekingHTTP) CloseIdleConnections() {
+	if c, ok := s.Client.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,173 @@
+package seekinghttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWithTLSConfig(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	// the handshake of the untrusted request fails
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	// the certificate of the test server is not trusted by default
+	_, err := New(srv.URL).ReadAt(make([]byte, 1), 0)
+	assert.Error(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	cfg := &tls.Config{RootCAs: pool}
+	s := NewWithTLSConfig(srv.URL, cfg)
+	buf := make([]byte, 4)
+	n, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "3456", string(buf[:n]))
+	assert.NoError(t, s.Close())
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "srv.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files/a" || r.URL.Query().Get("v") != "2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	s := New("http+unix://" + url.PathEscape(socket) + "/files/a?v=2")
+	buf := make([]byte, 4)
+	n, err := s.ReadAt(buf, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "5678", string(buf[:n]))
+	size, err := s.Clone(false).Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), size)
+	assert.NoError(t, s.Close())
+
+	_, err = NewWithClient("http+unix:///files/a", http.DefaultClient).ReadAt(buf, 0)
+	assert.ErrorContains(t, err, "missing socket path")
+}
+
+func TestConnect(t *testing.T) {
+	var methods []string
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	s := NewWithClient(srv.URL, srv.Client())
+	assert.NoError(t, s.Connect(context.Background()))
+	assert.Equal(t, []string{"HEAD"}, methods)
+	if assert.NotNil(t, s.KnownSize) {
+		assert.EqualValues(t, 10, *s.KnownSize)
+	}
+
+	// the read reuses the connection
+	_, err := s.ReadAt(make([]byte, 5), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"HEAD", "GET"}, methods)
+	assert.EqualValues(t, 1, conns.Load())
+
+	assert.NoError(t, s.Close())
+	assert.ErrorIs(t, s.Connect(context.Background()), os.ErrClosed)
+}
+
+func TestNewWithTransportOptions(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	opts := DefaultTransportOptions()
+	opts.TLSConfig = &tls.Config{RootCAs: pool}
+	opts.MaxConnsPerHost = 4
+	s := NewWithTransportOptions(srv.URL, opts)
+	tr := s.Client.(*http.Client).Transport.(*http.Transport)
+	assert.Equal(t, 4, tr.MaxConnsPerHost)
+	assert.Equal(t, 16, tr.MaxIdleConnsPerHost)
+	assert.NotNil(t, tr.TLSClientConfig.ClientSessionCache)
+	assert.Nil(t, opts.TLSConfig.ClientSessionCache)
+
+	var proto string
+	s.Trace = func(_, _ int64) *httptrace.ClientTrace {
+		return &httptrace.ClientTrace{TLSHandshakeDone: func(cs tls.ConnectionState, _ error) {
+			proto = cs.NegotiatedProtocol
+		}}
+	}
+	buf := make([]byte, 4)
+	_, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "3456", string(buf))
+	assert.Equal(t, "h2", proto)
+}
+
+func TestCloseIdleConnections(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	s := NewWithClient(srv.URL, &http.Client{Transport: newTransport(nil)})
+	s.MinFetch = 2
+	_, err := s.ReadAt(make([]byte, 2), 0)
+	assert.NoError(t, err)
+	_, err = s.ReadAt(make([]byte, 2), 4)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, conns.Load())
+
+	s.CloseIdleConnections()
+	_, err = s.ReadAt(make([]byte, 2), 8)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, conns.Load())
+
+	// clients without idle connections are ignored
+	NewWithClient(srv.URL, newFileClient()).CloseIdleConnections()
+}
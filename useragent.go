@@ -0,0 +1,41 @@
+package seekinghttp
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// modulePath is the path of this module in the build info.
+const modulePath = "github.com/paralin/seekinghttp"
+
+// DefaultUserAgent is the User-Agent sent when UserAgent is empty. It
+// identifies the library and the version of the module in the build. Under
+// GOOS=js it is empty, so the browser sends its own and requests are not
+// preflighted for a custom header.
+var DefaultUserAgent = defaultUserAgent()
+
+func defaultUserAgent() string {
+	if runtime.GOOS == "js" {
+		return ""
+	}
+	return "seekinghttp/" + moduleVersion()
+}
+
+// moduleVersion returns the version of this module in the build, or "devel".
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	mod := &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			mod = dep
+			break
+		}
+	}
+	if mod.Path != modulePath || mod.Version == "" || mod.Version == "(devel)" {
+		return "devel"
+	}
+	return mod.Version
+}
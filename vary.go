@@ -0,0 +1,164 @@
+package seekinghttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// credentialHeaders are the request headers that always select the cached
+// bytes, so readers with different credentials never share them.
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// learnVary learns the request headers named by the Vary header of resp.
+func (s *SeekingHTTP) learnVary(resp *http.Response) {
+	var vary []string
+	for _, v := range resp.Header.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(vary)
+	// not nil once learned, see varyKnown
+	s.vary = append([]string{}, slices.Compact(vary)...)
+
+	cache := s.cache()
+	cache.mu.Lock()
+	if cache.vary == nil {
+		cache.vary = make(map[string][]string)
+	}
+	cache.vary[s.URL] = s.vary
+	cache.mu.Unlock()
+}
+
+// cacheKey returns the key of the bytes of the object in the cache. It is the
+// URL, followed by a hash of the credential headers and the headers named by
+// the Vary header of the responses if the requests send any of them, so a
+// shared Cache serves readers only the representation they would be sent. A
+// Vary of "*" selects by all headers of the requests. The hash is computed
+// again only once the headers or the Vary header change.
+func (s *SeekingHTTP) cacheKey() string {
+	vary, _ := s.learnedVary()
+	return s.keyFor(vary)
+}
+
+// flightKey returns the key of the loads of the object in flight. Until a
+// response named the Vary header for the URL, readers sending other headers
+// may be sent other representations, so they join only the loads of readers
+// sending the same headers.
+func (s *SeekingHTTP) flightKey() string {
+	if vary, ok := s.learnedVary(); ok || s.Fetcher != nil {
+		return s.keyFor(vary)
+	}
+	return s.keyFor([]string{"*"})
+}
+
+// learnedVary returns the headers named by the Vary header of the object,
+// and whether a response taught them to s or to the cache.
+func (s *SeekingHTTP) learnedVary() ([]string, bool) {
+	if s.vary != nil {
+		return s.vary, true
+	}
+	cache := s.cache()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	vary, ok := cache.vary[s.URL]
+	return vary, ok
+}
+
+// keyFor returns the key of the bytes of the object selected by the headers
+// named by vary, see cacheKey.
+func (s *SeekingHTTP) keyFor(vary []string) string {
+	if len(vary) == 0 && !s.sendsCredentials() {
+		return s.URL
+	}
+	if k := s.key; k != nil && k.matches(s, vary) {
+		return k.key
+	}
+	s.key = newCacheKey(s, vary)
+	return s.key.key
+}
+
+// cacheKey is a key returned by SeekingHTTP.cacheKey and what it was computed
+// from.
+type cacheKey struct {
+	key  string
+	url  string
+	vary []string
+	// header and reqHeader are copies of the Header and the header of the
+	// Request, the other fields those setting request headers.
+	header, reqHeader       http.Header
+	userAgent, defaultAgent string
+	accept, cacheControl    string
+}
+
+// newCacheKey computes the key of the headers of s.
+func newCacheKey(s *SeekingHTTP, vary []string) *cacheKey {
+	k := &cacheKey{
+		url:          s.URL,
+		vary:         vary,
+		header:       s.Header.Clone(),
+		userAgent:    s.UserAgent,
+		defaultAgent: DefaultUserAgent,
+		accept:       s.Accept,
+		cacheControl: s.CacheControl,
+	}
+	h := make(http.Header)
+	if s.Request != nil && s.Request.Header != nil {
+		k.reqHeader = s.Request.Header.Clone()
+		h = s.Request.Header.Clone()
+	}
+	s.setHeader(h)
+	names := append(slices.Clone(credentialHeaders), vary...)
+	if slices.Contains(vary, "*") {
+		names = slices.Collect(maps.Keys(h))
+	}
+	slices.Sort(names)
+
+	sum := sha256.New()
+	var keyed bool
+	for _, name := range slices.Compact(names) {
+		if v, ok := h[name]; ok {
+			keyed = true
+			fmt.Fprintf(sum, "%s: %q\n", name, v)
+		}
+	}
+	k.key = s.URL
+	if keyed {
+		k.key += " " + hex.EncodeToString(sum.Sum(nil)[:16])
+	}
+	return k
+}
+
+// matches returns whether k is the key of the headers of s.
+func (k *cacheKey) matches(s *SeekingHTTP, vary []string) bool {
+	var reqHeader http.Header
+	if s.Request != nil {
+		reqHeader = s.Request.Header
+	}
+	return k.url == s.URL && slices.Equal(k.vary, vary) &&
+		k.userAgent == s.UserAgent && k.defaultAgent == DefaultUserAgent &&
+		k.accept == s.Accept && k.cacheControl == s.CacheControl &&
+		headerEqual(k.header, s.Header) && headerEqual(k.reqHeader, reqHeader)
+}
+
+// headerEqual returns whether a and b hold the same values.
+func headerEqual(a, b http.Header) bool {
+	return maps.EqualFunc(a, b, slices.Equal)
+}
+
+// sendsCredentials returns whether the requests send credentialHeaders.
+func (s *SeekingHTTP) sendsCredentials() bool {
+	for _, name := range credentialHeaders {
+		if s.Header.Get(name) != "" || s.Request != nil && s.Request.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package seekinghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheKeyVary(t *testing.T) {
+	var numReq int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numReq++
+		body := strings.Repeat(r.Header.Get("Authorization")+r.Header.Get("Accept")+"-", 100)
+		w.Header().Set("Vary", "accept, Accept-Language")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	cache := NewCache(1 << 20)
+	read := func(auth, accept string) string {
+		s := New(srv.URL)
+		s.Cache = cache
+		s.Accept = accept
+		if auth != "" {
+			s.Header = http.Header{"Authorization": {auth}}
+		}
+		buf := make([]byte, 4)
+		_, err := s.ReadAt(buf, 0)
+		assert.NoError(t, err)
+		return string(buf)
+	}
+
+	assert.Equal(t, "a1x-", read("a1", "x"))
+	assert.Equal(t, "a1x-", read("a1", "x"))
+	assert.Equal(t, 1, numReq)
+
+	// other credentials or representations are not served from the cache
+	assert.Equal(t, "a2x-", read("a2", "x"))
+	assert.Equal(t, "a1y-", read("a1", "y"))
+	assert.Equal(t, "x-x-", read("", "x"))
+	assert.Equal(t, 4, numReq)
+
+	s := New(srv.URL)
+	assert.Equal(t, srv.URL, s.cacheKey())
+	s.SetBasicAuth("user", "pass")
+	key := s.cacheKey()
+	assert.True(t, strings.HasPrefix(key, srv.URL+" "))
+	assert.NotContains(t, key, "pass")
+	// computed once for the same headers
+	computed := s.key
+	assert.Equal(t, key, s.cacheKey())
+	assert.Same(t, computed, s.key)
+	s.SetBasicAuth("user", "other")
+	assert.NotEqual(t, key, s.cacheKey())
+	s.vary = []string{"*"}
+	s.UserAgent = "agent"
+	key = s.cacheKey()
+	s.UserAgent = "other"
+	assert.NotEqual(t, key, s.cacheKey())
+}
+
+func TestCacheKeyVaryConcurrent(t *testing.T) {
+	// the first loads of two representations are in flight together, before
+	// the Vary header is known
+	var numReq atomic.Int32
+	both := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if numReq.Add(1) == 2 {
+			close(both)
+		}
+		select {
+		case <-both:
+		case <-time.After(time.Second):
+		}
+		w.Header().Set("Vary", "Accept")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(strings.Repeat(r.Header.Get("Accept"), 100)))
+	}))
+	defer srv.Close()
+
+	cache := NewCache(1 << 20)
+	read := func(accept string) string {
+		s := New(srv.URL)
+		s.Cache = cache
+		s.Accept = accept
+		buf := make([]byte, 4)
+		_, err := s.ReadAt(buf, 0)
+		assert.NoError(t, err)
+		return string(buf)
+	}
+	var wg sync.WaitGroup
+	var x, y string
+	wg.Go(func() { x = read("x") })
+	wg.Go(func() { y = read("y") })
+	wg.Wait()
+	assert.Equal(t, "xxxx", x)
+	assert.Equal(t, "yyyy", y)
+
+	// and each is cached under its own key
+	assert.Equal(t, "xxxx", read("x"))
+	assert.Equal(t, "yyyy", read("y"))
+	assert.Equal(t, int32(2), numReq.Load())
+}
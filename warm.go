@@ -0,0 +1,96 @@
+package seekinghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrTooLarge is returned by WarmAll when the object does not fit in the
+// cache.
+var ErrTooLarge = errors.New("seekinghttp: object larger than the cache")
+
+// WarmAll downloads the whole object into the cache in a single streaming
+// request, or in requests of MaxFetch bytes if set. It suits callers that
+// will read most of the object in random order, which is served from the
+// cache afterwards instead of by many range requests.
+//
+// The object has to fit in the budget of s.Cache, otherwise ErrTooLarge is
+// returned without loading anything. The private cache of a reader without a
+// Cache holds a single range, so set a Cache to warm.
+func (s *SeekingHTTP) WarmAll(ctx context.Context) error {
+	size, err := s.SizeContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if size == 0 {
+		return nil
+	}
+	cache := s.cache()
+	if size > cache.maxBytes {
+		return fmt.Errorf("size %d exceeds the cache of %d bytes: %w", size, cache.maxBytes, ErrTooLarge)
+	}
+
+	if s.Logger != nil {
+		s.Logger.Debugf("warming the cache with the whole object of %d bytes", size)
+	}
+	s.stats.cache.Store(cache)
+	_, err = s.loadShared(ctx, cache, 0, size, size)
+	return s.ctxErr(ctx, err)
+}
+
+// Pin loads the range of length bytes at off into the cache unless it is
+// held already and pins it there, so it is not evicted by later loads, such
+// as the index of an archive read between scans of its entries. The pinned
+// ranges are kept on top of the budget of the cache until Unpin.
+func (s *SeekingHTTP) Pin(ctx context.Context, off, length int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if off < 0 || length < 0 {
+		return fmt.Errorf("invalid range (%d-%d): %w", off, off+length, os.ErrInvalid)
+	}
+	if s.KnownSize != nil {
+		length = min(length, *s.KnownSize-off)
+	}
+	if length <= 0 {
+		return nil
+	}
+	cache := s.cache()
+	s.stats.cache.Store(cache)
+	if cache.pin(s.cacheKey(), off, length) {
+		return nil
+	}
+
+	if s.Logger != nil {
+		s.Logger.Debugf("pinning range (%v-%v)", off, off+length)
+	}
+	_, err := s.loadShared(ctx, cache, off, length, length)
+	cache.pin(s.cacheKey(), off, length)
+	return s.ctxErr(ctx, err)
+}
+
+// pinHeld pins the cached parts of the range of length bytes at off and
+// reports whether all of it is held, without loading anything.
+func (s *SeekingHTTP) pinHeld(off, length int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cache().pin(s.cacheKey(), off, length)
+}
+
+// Unpin unpins the ranges of the object pinned by Pin, which are then
+// evicted like any other.
+func (s *SeekingHTTP) Unpin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache().unpin(s.cacheKey())
+}
@@ -0,0 +1,44 @@
+package seekinghttp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmAll(t *testing.T) {
+	srv, lengths := rangeServer(t, 64<<10)
+
+	s := New(srv.URL)
+	s.Cache = NewCache(1 << 20)
+	s.MinFetch = 1024
+	assert.NoError(t, s.WarmAll(context.Background()))
+	assert.Equal(t, []int64{64 << 10}, *lengths)
+
+	// random reads are served from the cache
+	buf := make([]byte, 16)
+	for _, off := range []int64{40000, 16, 65520, 1008} {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789abcdef", string(buf))
+	}
+	assert.Len(t, *lengths, 1)
+	assert.Equal(t, int64(64<<10), s.Cache.Size())
+
+	// MaxFetch splits the download
+	*lengths = nil
+	s = New(srv.URL)
+	s.Cache = NewCache(1 << 20)
+	s.MaxFetch = 32 << 10
+	assert.NoError(t, s.WarmAll(context.Background()))
+	assert.Equal(t, []int64{32 << 10, 32 << 10}, *lengths)
+
+	// objects larger than the cache are not loaded
+	*lengths = nil
+	s = New(srv.URL)
+	s.Cache = NewCache(32 << 10)
+	assert.ErrorIs(t, s.WarmAll(context.Background()), ErrTooLarge)
+	assert.ErrorIs(t, New(srv.URL).WarmAll(context.Background()), ErrTooLarge)
+	assert.Empty(t, *lengths)
+}
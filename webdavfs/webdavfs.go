@@ -0,0 +1,210 @@
+// Package webdavfs implements an fs.FS for WebDAV shares, listing directories
+// with PROPFIND and reading files with seekable ranged GETs.
+package webdavfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paralin/seekinghttp"
+)
+
+// FS is a WebDAV share. The files are opened by the Factory, so they share
+// its client, headers and cache.
+type FS struct {
+	// Root is the URL of the root collection of the share.
+	Root    *url.URL
+	Factory *seekinghttp.Factory
+}
+
+// _ is a type assertion
+var (
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New initializes a FS for the share at root with a default Factory.
+func New(root string) (*FS, error) {
+	u, err := url.Parse(root)
+	if err != nil {
+		return nil, err
+	}
+	return &FS{Root: u, Factory: seekinghttp.NewFactory()}, nil
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := f.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return seekinghttp.NewDir(info, entries), nil
+	}
+	return seekinghttp.NewFile(f.Factory.Open(seekinghttp.FileURL(f.Root, name, false).String()), info), nil
+}
+
+// Stat returns the info of the named file with a PROPFIND of depth 0.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := f.propfind(name, "0")
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	for _, info := range infos {
+		if info.self {
+			info.name = path.Base(name)
+			return info, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir lists the named directory with a PROPFIND of depth 1, sorted by
+// file name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := f.propfind(name, "1")
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	var entries []fs.DirEntry
+	for _, info := range infos {
+		if info.self {
+			if !info.IsDir() {
+				return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+			}
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// propfindBody requests the properties used for the file info.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop>
+<D:resourcetype/><D:getcontentlength/><D:getlastmodified/>
+</D:prop></D:propfind>`
+
+// multistatus is the body of a PROPFIND response.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// propfind lists the properties of the named file and, for depth 1, of its
+// children.
+func (f *FS) propfind(name, depth string) ([]*fileInfo, error) {
+	u := seekinghttp.FileURL(f.Root, name, depth == "1")
+	req, err := f.Factory.NewRequest(context.Background(), "PROPFIND", u.String(), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := f.Factory.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusNotFound:
+		return nil, fs.ErrNotExist
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return nil, fs.ErrPermission
+	default:
+		return nil, &seekinghttp.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var ms multistatus
+	if err := xml.NewDecoder(bytes.NewReader(body)).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("invalid propfind response: %w", err)
+	}
+
+	self := strings.TrimSuffix(u.Path, "/")
+	infos := make([]*fileInfo, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		p := strings.TrimSuffix(href.Path, "/")
+		info := &fileInfo{name: path.Base(p), self: p == self}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			info.dir = info.dir || ps.Prop.ResourceType.Collection != nil
+			if n, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err == nil {
+				info.size = n
+			}
+			if t, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+				info.modTime = t
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// fileInfo is the info of a file from its properties.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	// self is set for the file the PROPFIND was for.
+	self bool
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+func (i *fileInfo) Sys() any           { return nil }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
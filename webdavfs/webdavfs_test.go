@@ -0,0 +1,67 @@
+package webdavfs
+
+import (
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestFS(t *testing.T) {
+	root := t.TempDir()
+	body := strings.Repeat("0123456789", 10)
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "dir", "sub"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "dir", "a.txt"), []byte(body), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "dir", "sub", "b.txt"), []byte("b"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "top.txt"), nil, 0o644))
+
+	srv := httptest.NewServer(&webdav.Handler{
+		Prefix:     "/share",
+		FileSystem: webdav.Dir(root),
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer srv.Close()
+
+	fsys, err := New(srv.URL + "/share/")
+	assert.NoError(t, err)
+	fsys.Factory.MinFetch = 0
+
+	entries, err := fsys.ReadDir("dir")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "a.txt", entries[0].Name())
+		assert.False(t, entries[0].IsDir())
+		assert.Equal(t, "sub", entries[1].Name())
+		assert.True(t, entries[1].IsDir())
+	}
+
+	f, err := fsys.Open("dir/a.txt")
+	assert.NoError(t, err)
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(body)), info.Size())
+	buf := make([]byte, 5)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(buf[:n]))
+	assert.NoError(t, f.Close())
+
+	_, err = fsys.Open("missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	var walked []string
+	assert.NoError(t, fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	}))
+	assert.Equal(t, []string{".", "dir", "dir/a.txt", "dir/sub", "dir/sub/b.txt", "top.txt"}, walked)
+
+	assert.NoError(t, fstest.TestFS(fsys, "dir/a.txt", "dir/sub/b.txt", "top.txt"))
+}
@@ -0,0 +1,54 @@
+package seekinghttp
+
+import (
+	"context"
+	"io"
+)
+
+// ContextReader reads from a SeekingHTTP with every request bound to a
+// context. It shares the offset and cache of the SeekingHTTP it was created
+// from.
+//
+// Use it to pass a SeekingHTTP to APIs that only accept io.ReaderAt or
+// io.ReadSeeker, such as zip.NewReader, while the caller's context deadline
+// and cancellation still apply to every request.
+type ContextReader struct {
+	s   *SeekingHTTP
+	ctx context.Context
+}
+
+// _ is a type assertion
+var (
+	_ io.ReadSeeker = (*ContextReader)(nil)
+	_ io.ReaderAt   = (*ContextReader)(nil)
+)
+
+// WithContext returns a view of s that issues all requests with ctx.
+func (s *SeekingHTTP) WithContext(ctx context.Context) *ContextReader {
+	return &ContextReader{s: s, ctx: ctx}
+}
+
+// Context returns the context of the reader.
+func (r *ContextReader) Context() context.Context {
+	return r.ctx
+}
+
+// Read calls ReadContext with the context of the reader.
+func (r *ContextReader) Read(buf []byte) (int, error) {
+	return r.s.ReadContext(r.ctx, buf)
+}
+
+// ReadAt calls ReadAtContext with the context of the reader.
+func (r *ContextReader) ReadAt(buf []byte, off int64) (int, error) {
+	return r.s.ReadAtContext(r.ctx, buf, off)
+}
+
+// Seek calls SeekContext with the context of the reader.
+func (r *ContextReader) Seek(offset int64, whence int) (int64, error) {
+	return r.s.SeekContext(r.ctx, offset, whence)
+}
+
+// Size calls SizeContext with the context of the reader.
+func (r *ContextReader) Size() (int64, error) {
+	return r.s.SizeContext(r.ctx)
+}